@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
@@ -21,17 +23,23 @@ type Auth struct {
 }
 
 type jwtUser struct {
-	ID        int    `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
+	ID        int      `json:"id"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Roles     []string `json:"roles"`
 }
 
 type TokenPairs struct {
 	Token        string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+
+	// the jti and expiry of the refresh token, for the server-side session
+	RefreshTokenID     string    `json:"-"`
+	RefreshTokenExpiry time.Time `json:"-"`
 }
 
 type Claims struct {
+	Roles []string `json:"roles,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -47,6 +55,7 @@ func (j *Auth) GenerateTokenPair(user *jwtUser) (TokenPairs, error) {
 	claims["iss"] = j.Issuer
 	claims["iat"] = time.Now().UTC().Unix()
 	claims["typ"] = "JWT"
+	claims["roles"] = user.Roles
 
 	// set the expiry for jwt
 	claims["exp"] = time.Now().UTC().Add(j.TokenExpiry).Unix()
@@ -58,13 +67,20 @@ func (j *Auth) GenerateTokenPair(user *jwtUser) (TokenPairs, error) {
 	}
 
 	// create a refresh token and set claims
+	refreshTokenID, err := newTokenID()
+	if err != nil {
+		return TokenPairs{}, err
+	}
+	refreshTokenExpiry := time.Now().UTC().Add(j.RefreshExpiry)
+
 	refreshToken := jwt.New(jwt.SigningMethodHS256)
 	refreshTokenClaims := refreshToken.Claims.(jwt.MapClaims)
 	refreshTokenClaims["sub"] = fmt.Sprint(user.ID)
+	refreshTokenClaims["jti"] = refreshTokenID
 	refreshTokenClaims["iat"] = time.Now().UTC().Unix()
 
 	// set the expiry for the refresh token
-	refreshTokenClaims["exp"] = time.Now().UTC().Add(j.RefreshExpiry).Unix()
+	refreshTokenClaims["exp"] = refreshTokenExpiry.Unix()
 
 	// create a signed refresh token
 	signedRefreshToken, err := refreshToken.SignedString([]byte(j.Secret))
@@ -73,14 +89,42 @@ func (j *Auth) GenerateTokenPair(user *jwtUser) (TokenPairs, error) {
 	}
 	// create TokenPairs and populate with signed tokens
 	var tokenPairs = TokenPairs{
-		Token:        signedAccessToken,
-		RefreshToken: signedRefreshToken,
+		Token:              signedAccessToken,
+		RefreshToken:       signedRefreshToken,
+		RefreshTokenID:     refreshTokenID,
+		RefreshTokenExpiry: refreshTokenExpiry,
 	}
 
 	// return TokenPairs
 	return tokenPairs, nil
 }
 
+// ParseRefreshToken verifies the signature of a refresh token and returns its
+// claims. Expired tokens are rejected unless allowExpired is set, which logout
+// uses so that it can still revoke the session behind an old cookie.
+func (j *Auth) ParseRefreshToken(refreshToken string, allowExpired bool) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := jwt.ParseWithClaims(refreshToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(j.Secret), nil
+	})
+	if err != nil {
+		var vErr *jwt.ValidationError
+		if !allowExpired || !errors.As(err, &vErr) || vErr.Errors != jwt.ValidationErrorExpired {
+			return nil, err
+		}
+	}
+
+	if claims.ID == "" {
+		return nil, errors.New("refresh token has no id")
+	}
+
+	return claims, nil
+}
+
 func (j *Auth) GetRefreshCookie(refreshToken string) *http.Cookie {
 	return &http.Cookie{
 		Name:     j.CookieName,
@@ -157,3 +201,14 @@ func (j *Auth) GetTokenFromHeaderAndVerify(w http.ResponseWriter, r *http.Reques
 
 	return token, claims, nil
 }
+
+// newTokenID returns a random identifier for use as a jti or session family.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
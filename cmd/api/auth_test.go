@@ -0,0 +1,296 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"watch-a-movie/internal/password"
+)
+
+func TestAuthenticate(t *testing.T) {
+	app := newTestApp(t)
+
+	body := `{"email": "` + editorEmail + `", "password": "` + testPassword + `"}`
+	rr := request(app, "POST", "/authenticate", body, "")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var tokens TokenPairs
+	decode(t, rr, &tokens)
+	if tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("expected a token pair but got %s", rr.Body)
+	}
+
+	cookie := refreshCookie(app, rr)
+	if cookie == nil || cookie.Value != tokens.RefreshToken {
+		t.Fatalf("expected the refresh token in a cookie but got %v", cookie)
+	}
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("refresh cookie is not locked down: %+v", cookie)
+	}
+
+	// the access token carries the roles
+	rr = request(app, "PUT", "/admin/movies/1", `{"title": "The Godfather"}`, tokens.Token)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("expected editor token to update a movie but got %d", rr.Code)
+	}
+}
+
+func TestAuthenticateFailures(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"wrong password", `{"email": "` + editorEmail + `", "password": "nope"}`},
+		{"unknown email", `{"email": "nobody@example.com", "password": "` + testPassword + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(app, "POST", "/authenticate", tt.body, "")
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400 but got %d", rr.Code)
+			}
+
+			// both failures look the same to the client
+			var resp JSONResponse
+			decode(t, rr, &resp)
+			if resp.Message != "invalid credentials" {
+				t.Errorf("unexpected message %q", resp.Message)
+			}
+			if refreshCookie(app, rr) != nil {
+				t.Error("failed login set a refresh cookie")
+			}
+		})
+	}
+}
+
+func TestAuthenticateRehashesLegacyPassword(t *testing.T) {
+	app := newTestApp(t)
+
+	body := `{"email": "` + adminEmail + `", "password": "` + testPassword + `"}`
+	rr := request(app, "POST", "/authenticate", body, "")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d", rr.Code)
+	}
+
+	user, _ := app.DB.GetUserByEmail(t.Context(), adminEmail)
+	if password.IsLegacy(user.Password) {
+		t.Fatal("cleartext password was not upgraded")
+	}
+
+	// and the upgraded hash still works
+	rr = request(app, "POST", "/authenticate", body, "")
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("expected 202 after rehash but got %d", rr.Code)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	app := newTestApp(t)
+
+	body := `{"first_name": "New", "last_name": "Person", "email": " New@Example.com ", "password": "correct horse 1"}`
+	rr := request(app, "POST", "/register", body, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var tokens TokenPairs
+	decode(t, rr, &tokens)
+	if tokens.Token == "" || refreshCookie(app, rr) == nil {
+		t.Fatal("expected the new user to be logged in")
+	}
+
+	user, err := app.DB.GetUserByEmail(t.Context(), "new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password == "correct horse 1" || password.IsLegacy(user.Password) {
+		t.Error("password was stored in cleartext")
+	}
+	if len(user.Roles) != 0 {
+		t.Errorf("new users should have no roles but got %v", user.Roles)
+	}
+
+	// new users can log in but not into the admin api
+	rr = request(app, "GET", "/admin/movies", "", tokens.Token)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 but got %d", rr.Code)
+	}
+}
+
+func TestRegisterThenLoginMixedCase(t *testing.T) {
+	app := newTestApp(t)
+
+	body := `{"first_name": "Mixed", "last_name": "Case", "email": "Mixed@Example.com", "password": "correct horse 1"}`
+	rr := request(app, "POST", "/register", body, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("register: expected 201 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	for _, email := range []string{"Mixed@Example.com", "mixed@example.com", " MIXED@example.com"} {
+		rr = request(app, "POST", "/authenticate", `{"email": "`+email+`", "password": "correct horse 1"}`, "")
+		if rr.Code != http.StatusAccepted {
+			t.Errorf("login as %q: expected 202 but got %d", email, rr.Code)
+		}
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"taken email", `{"first_name": "A", "last_name": "B", "email": "` + editorEmail + `", "password": "abcdefg1"}`, http.StatusConflict},
+		{"bad email", `{"first_name": "A", "last_name": "B", "email": "not-an-email", "password": "abcdefg1"}`, http.StatusBadRequest},
+		{"email without tld", `{"first_name": "A", "last_name": "B", "email": "a@localhost", "password": "abcdefg1"}`, http.StatusBadRequest},
+		{"short password", `{"first_name": "A", "last_name": "B", "email": "a@b.com", "password": "abc1"}`, http.StatusBadRequest},
+		{"letters only", `{"first_name": "A", "last_name": "B", "email": "a@b.com", "password": "abcdefghij"}`, http.StatusBadRequest},
+		{"long password", `{"first_name": "A", "last_name": "B", "email": "a@b.com", "password": "1` + strings.Repeat("a", 72) + `"}`, http.StatusBadRequest},
+		{"missing name", `{"first_name": "", "last_name": "B", "email": "a@b.com", "password": "abcdefg1"}`, http.StatusBadRequest},
+		{"unknown field", `{"email": "a@b.com", "role": "admin"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(app, "POST", "/register", tt.body, "")
+			if rr.Code != tt.status {
+				t.Errorf("expected %d but got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+}
+
+// login authenticates the seeded user and returns its refresh cookie.
+func login(t *testing.T, app *application, email string) *http.Cookie {
+	t.Helper()
+
+	body := `{"email": "` + email + `", "password": "` + testPassword + `"}`
+	rr := request(app, "POST", "/authenticate", body, "")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("login: expected 202 but got %d", rr.Code)
+	}
+
+	return refreshCookie(app, rr)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	app := newTestApp(t)
+	first := login(t, app, viewerEmail)
+
+	rr := request(app, "GET", "/refresh", "", "", first)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	second := refreshCookie(app, rr)
+	if second == nil || second.Value == first.Value {
+		t.Fatal("expected a new refresh token")
+	}
+
+	rr = request(app, "GET", "/refresh", "", "", second)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the rotated token to work but got %d", rr.Code)
+	}
+	third := refreshCookie(app, rr)
+
+	// replaying an old token kills the whole family, including the newest one
+	rr = request(app, "GET", "/refresh", "", "", first)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on reuse but got %d", rr.Code)
+	}
+
+	rr = request(app, "GET", "/refresh", "", "", third)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after reuse detection but got %d", rr.Code)
+	}
+
+	// other logins are unaffected
+	other := login(t, app, viewerEmail)
+	rr = request(app, "GET", "/refresh", "", "", other)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected a separate login to keep working but got %d", rr.Code)
+	}
+}
+
+func TestRefreshTokenRejectsBadCookies(t *testing.T) {
+	app := newTestApp(t)
+
+	rr := request(app, "GET", "/refresh", "", "")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("no cookie: expected 401 but got %d", rr.Code)
+	}
+
+	cookie := &http.Cookie{Name: app.auth.CookieName, Value: "garbage"}
+	rr = request(app, "GET", "/refresh", "", "", cookie)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("bad token: expected 401 but got %d", rr.Code)
+	}
+
+	// a validly signed token that was never stored as a session
+	tokens, _ := app.auth.GenerateTokenPair(&jwtUser{ID: 1})
+	cookie = &http.Cookie{Name: app.auth.CookieName, Value: tokens.RefreshToken}
+	rr = request(app, "GET", "/refresh", "", "", cookie)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("unknown session: expected 401 but got %d", rr.Code)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	app := newTestApp(t)
+	cookie := login(t, app, viewerEmail)
+
+	rr := request(app, "GET", "/logout", "", "", cookie)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d", rr.Code)
+	}
+
+	expired := refreshCookie(app, rr)
+	if expired == nil || expired.MaxAge >= 0 || expired.Value != "" {
+		t.Errorf("expected an expired cookie but got %+v", expired)
+	}
+
+	// the old refresh token is dead server-side too
+	rr = request(app, "GET", "/refresh", "", "", cookie)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after logout but got %d", rr.Code)
+	}
+}
+
+func TestDisabledUser(t *testing.T) {
+	app := newTestApp(t)
+	cookie := login(t, app, viewerEmail)
+
+	err := app.DB.SetUserDisabled(t.Context(), 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"email": "` + viewerEmail + `", "password": "` + testPassword + `"}`
+	rr := request(app, "POST", "/authenticate", body, "")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("login: expected 403 but got %d", rr.Code)
+	}
+
+	// disabling revoked the existing session
+	rr = request(app, "GET", "/refresh", "", "", cookie)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("refresh: expected 401 but got %d", rr.Code)
+	}
+
+	err = app.DB.SetUserDisabled(t.Context(), 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = request(app, "POST", "/authenticate", body, "")
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("after enabling: expected 202 but got %d", rr.Code)
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxCommentBody caps the length of a comment, in characters.
+	maxCommentBody = 2000
+	// maxModerationText caps report reasons and moderation notes.
+	maxModerationText = 1000
+)
+
+// commentPage is a page of comments, as returned by MovieComments and
+// CommentQueue.
+type commentPage struct {
+	Items    []*models.Comment `json:"items"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+	Next     string            `json:"next,omitempty"`
+	Prev     string            `json:"prev,omitempty"`
+}
+
+func (app *application) writeCommentPage(w http.ResponseWriter, r *http.Request, comments []*models.Comment, total int, filter models.CommentFilter) {
+	payload := commentPage{
+		Items:    comments,
+		Total:    total,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+	}
+
+	if filter.Offset()+len(comments) < total {
+		payload.Next = pageLink(r.URL, filter.Page+1)
+	}
+	if filter.Page > 1 {
+		payload.Prev = pageLink(r.URL, filter.Page-1)
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// MovieComments returns one page of a movie's approved comments, newest
+// first. It takes the page and page_size query parameters.
+func (app *application) MovieComments(w http.ResponseWriter, r *http.Request) {
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	filter := models.CommentFilter{MovieID: movieID, Statuses: []string{models.CommentApproved}}
+	filter.Page, filter.PageSize, err = readPage(r.URL.Query())
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	_, err = app.DB.GetMovieByID(r.Context(), movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	comments, total, err := app.DB.ListComments(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeCommentPage(w, r, comments, total, filter)
+}
+
+// InsertComment adds the logged in user's comment on a movie. It waits for
+// moderation before it is shown, flagged if it matches the pre-filter.
+func (app *application) InsertComment(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var payload struct {
+		Body string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	body := strings.TrimSpace(payload.Body)
+	if body == "" {
+		app.errorJSON(w, errors.New("body is required"))
+		return
+	}
+	if utf8.RuneCountInString(body) > maxCommentBody {
+		app.errorJSON(w, fmt.Errorf("body can be at most %d characters", maxCommentBody))
+		return
+	}
+
+	_, err = app.DB.GetMovieByID(r.Context(), movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	comment := models.Comment{
+		MovieID:   movieID,
+		UserID:    userID,
+		Body:      body,
+		Status:    models.CommentPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if rule, ok := app.moderationFilter.Match(body); ok {
+		comment.Status = models.CommentFlagged
+		comment.FlagReason = "matched " + rule
+	}
+
+	id, err := app.DB.InsertComment(r.Context(), comment)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	saved, err := app.DB.GetComment(r.Context(), id)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	// the rule that matched is for moderators, not for the author to
+	// work around
+	saved.FlagReason = ""
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "comment awaiting moderation",
+		Data:    saved,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// ReportComment records the logged in user's report of an abusive comment,
+// with an optional reason. The comment is flagged, and so hidden until a
+// moderator looks at it again. Only approved comments can be reported, since
+// no others are shown.
+func (app *application) ReportComment(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	reason := strings.TrimSpace(payload.Reason)
+	if utf8.RuneCountInString(reason) > maxModerationText {
+		app.errorJSON(w, fmt.Errorf("reason can be at most %d characters", maxModerationText))
+		return
+	}
+
+	comment, err := app.DB.GetComment(r.Context(), commentID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && comment.Status != models.CommentApproved) {
+		app.errorJSON(w, errors.New("comment not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	report := models.CommentReport{
+		CommentID: commentID,
+		UserID:    userID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+
+	err = app.DB.ReportComment(r.Context(), report)
+	if errors.Is(err, repository.ErrDuplicate) {
+		app.errorJSON(w, errors.New("you already reported this comment"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "comment reported",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// CommentQueue returns one page of comments for moderators, oldest first. The
+// status query parameter is a comma separated list of states and defaults to
+// the queue, pending and flagged; movie_id keeps one movie's comments.
+func (app *application) CommentQueue(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	filter := models.CommentFilter{
+		Statuses:    []string{models.CommentPending, models.CommentFlagged},
+		OldestFirst: true,
+	}
+
+	var err error
+	filter.Page, filter.PageSize, err = readPage(qs)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if v := qs.Get("status"); v != "" {
+		filter.Statuses, err = readStatuses(v)
+		if err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+	}
+
+	if v := qs.Get("movie_id"); v != "" {
+		filter.MovieID, err = strconv.Atoi(v)
+		if err != nil || filter.MovieID < 1 {
+			app.errorJSON(w, errors.New("movie_id must be a positive integer"))
+			return
+		}
+	}
+
+	comments, total, err := app.DB.ListComments(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeCommentPage(w, r, comments, total, filter)
+}
+
+// CommentForModeration returns a comment with its reports and the moderation
+// actions taken on it, newest first.
+func (app *application) CommentForModeration(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	comment, err := app.DB.GetComment(r.Context(), commentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("comment not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	reports, err := app.DB.CommentReports(r.Context(), commentID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	actions, _, err := app.DB.ModerationActions(r.Context(), commentID, 1, models.MaxPageSize)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var payload = struct {
+		Comment *models.Comment            `json:"comment"`
+		Reports []*models.CommentReport    `json:"reports"`
+		Actions []*models.ModerationAction `json:"actions"`
+	}{
+		Comment: comment,
+		Reports: reports,
+		Actions: actions,
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// ModerateComment moves a comment to another state, with an optional note,
+// and records which admin did it.
+func (app *application) ModerateComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	action, ok := app.readModeration(w, r)
+	if !ok {
+		return
+	}
+	action.CommentID = commentID
+
+	err = app.DB.ModerateComment(r.Context(), action)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("comment not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	comment, err := app.DB.GetComment(r.Context(), commentID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "comment " + action.ToStatus,
+		Data:    comment,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// ModerationLog returns one page of the moderation actions on every comment,
+// newest first.
+func (app *application) ModerationLog(w http.ResponseWriter, r *http.Request) {
+	page, pageSize, err := readPage(r.URL.Query())
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	actions, total, err := app.DB.ModerationActions(r.Context(), 0, page, pageSize)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var payload = struct {
+		Items    []*models.ModerationAction `json:"items"`
+		Total    int                        `json:"total"`
+		Page     int                        `json:"page"`
+		PageSize int                        `json:"page_size"`
+		Next     string                     `json:"next,omitempty"`
+		Prev     string                     `json:"prev,omitempty"`
+	}{
+		Items:    actions,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	if (page-1)*pageSize+len(actions) < total {
+		payload.Next = pageLink(r.URL, page+1)
+	}
+	if page > 1 {
+		payload.Prev = pageLink(r.URL, page-1)
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// readStatuses reads a comma separated list of moderation states.
+func readStatuses(v string) ([]string, error) {
+	var statuses []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if !models.ValidCommentStatus(s) {
+			return nil, fmt.Errorf("unknown status %q", s)
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// readModeration reads the state a moderator moves a comment or review to,
+// with an optional note, into an action by the logged in admin. It writes the
+// error response and returns false if the request is no good.
+func (app *application) readModeration(w http.ResponseWriter, r *http.Request) (models.ModerationAction, bool) {
+	var action models.ModerationAction
+
+	claims := claimsFromContext(r.Context())
+	if claims == nil {
+		app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return action, false
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return action, false
+	}
+
+	if !models.ValidCommentStatus(payload.Status) {
+		app.errorJSON(w, fmt.Errorf("unknown status %q", payload.Status))
+		return action, false
+	}
+	note := strings.TrimSpace(payload.Note)
+	if utf8.RuneCountInString(note) > maxModerationText {
+		app.errorJSON(w, fmt.Errorf("note can be at most %d characters", maxModerationText))
+		return action, false
+	}
+
+	action = models.ModerationAction{
+		Admin:     claims.Subject,
+		ToStatus:  payload.Status,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+	return action, true
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/moderation"
+)
+
+type commentResponse struct {
+	Message string         `json:"message"`
+	Data    models.Comment `json:"data"`
+}
+
+func postComment(t *testing.T, app *application, token, body string) models.Comment {
+	t.Helper()
+
+	rr := request(app, "POST", "/movies/2/comments", fmt.Sprintf(`{"body": %q}`, body), token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp commentResponse
+	decode(t, rr, &resp)
+	return resp.Data
+}
+
+func commentIDs(t *testing.T, app *application, target, token string) []int {
+	t.Helper()
+
+	rr := request(app, "GET", target, "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s: expected 200 but got %d: %s", target, rr.Code, rr.Body)
+	}
+
+	var page commentPage
+	decode(t, rr, &page)
+	ids := []int{}
+	for _, c := range page.Items {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestCommentModeration(t *testing.T) {
+	app := newTestApp(t)
+	filter, err := moderation.ParseFilter(strings.NewReader("spoiler\n/https?://\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.moderationFilter = filter
+
+	user := accessToken(t, app, userEmail)
+	viewer := accessToken(t, app, viewerEmail)
+	admin := accessToken(t, app, adminEmail)
+
+	fine := postComment(t, app, user, "  The diner scene!  ")
+	if fine.Status != models.CommentPending || fine.Body != "The diner scene!" || fine.Author != "Uma U." {
+		t.Errorf("unexpected comment %+v", fine)
+	}
+	spoiler := postComment(t, app, viewer, "SPOILER: it ends at the airport")
+	if spoiler.Status != models.CommentFlagged || spoiler.FlagReason != "" {
+		t.Errorf("expected the comment to be flagged quietly but got %+v", spoiler)
+	}
+
+	// nothing is public before a moderator approves it
+	if got := commentIDs(t, app, "/movies/2/comments", ""); len(got) != 0 {
+		t.Errorf("expected no public comments but got %v", got)
+	}
+	if got := commentIDs(t, app, "/admin/comments", admin); len(got) != 2 || got[0] != fine.ID || got[1] != spoiler.ID {
+		t.Errorf("expected the queue to be %d, %d but got %v", fine.ID, spoiler.ID, got)
+	}
+
+	var detail struct {
+		Comment models.Comment `json:"comment"`
+	}
+	decode(t, request(app, "GET", fmt.Sprintf("/admin/comments/%d", spoiler.ID), "", admin), &detail)
+	if detail.Comment.FlagReason != "matched spoiler" {
+		t.Errorf("expected moderators to see the rule but got %+v", detail.Comment)
+	}
+
+	var resp commentResponse
+	rr := request(app, "PUT", fmt.Sprintf("/admin/comments/%d", fine.ID), `{"status": "approved"}`, admin)
+	decode(t, rr, &resp)
+	if rr.Code != http.StatusAccepted || resp.Data.Status != models.CommentApproved || resp.Message != "comment approved" {
+		t.Fatalf("expected the comment to be approved but got %d: %+v", rr.Code, resp)
+	}
+	request(app, "PUT", fmt.Sprintf("/admin/comments/%d", spoiler.ID), `{"status": "rejected", "note": "spoils the ending"}`, admin)
+
+	if got := commentIDs(t, app, "/movies/2/comments", ""); len(got) != 1 || got[0] != fine.ID {
+		t.Errorf("expected comment %d to be public but got %v", fine.ID, got)
+	}
+	if got := commentIDs(t, app, "/admin/comments", admin); len(got) != 0 {
+		t.Errorf("expected an empty queue but got %v", got)
+	}
+	if got := commentIDs(t, app, "/admin/comments?status=approved,rejected", admin); len(got) != 2 {
+		t.Errorf("expected both comments but got %v", got)
+	}
+
+	// a report sends it back to the queue
+	target := fmt.Sprintf("/comments/%d/reports", fine.ID)
+	rr = request(app, "POST", target, `{"reason": "off topic"}`, viewer)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	// and out of sight, so there's nothing left to report
+	rr = request(app, "POST", target, `{}`, user)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+	if got := commentIDs(t, app, "/movies/2/comments", ""); len(got) != 0 {
+		t.Errorf("expected the reported comment to be hidden but got %v", got)
+	}
+	if got := commentIDs(t, app, "/admin/comments?status=flagged", admin); len(got) != 1 || got[0] != fine.ID {
+		t.Errorf("expected comment %d to be flagged but got %v", fine.ID, got)
+	}
+
+	var log struct {
+		Items []models.ModerationAction `json:"items"`
+		Total int                       `json:"total"`
+	}
+	decode(t, request(app, "GET", "/admin/comments/actions", "", admin), &log)
+	if log.Total != 2 || log.Items[0].CommentID != spoiler.ID || log.Items[0].Admin != "1" ||
+		log.Items[0].FromStatus != models.CommentFlagged || log.Items[0].Note != "spoils the ending" {
+		t.Errorf("unexpected moderation log %+v", log)
+	}
+}
+
+func TestCommentsReject(t *testing.T) {
+	app := newTestApp(t)
+	user := accessToken(t, app, userEmail)
+	admin := accessToken(t, app, adminEmail)
+
+	pending := postComment(t, app, user, "hm")
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		token  string
+		status int
+	}{
+		{"anonymous", "POST", "/movies/2/comments", `{"body": "x"}`, "", http.StatusUnauthorized},
+		{"empty", "POST", "/movies/2/comments", `{"body": "  "}`, user, http.StatusBadRequest},
+		{"too long", "POST", "/movies/2/comments", fmt.Sprintf(`{"body": %q}`, strings.Repeat("x", maxCommentBody+1)), user, http.StatusBadRequest},
+		{"unknown movie", "POST", "/movies/99/comments", `{"body": "x"}`, user, http.StatusNotFound},
+		{"report pending", "POST", fmt.Sprintf("/comments/%d/reports", pending.ID), `{}`, user, http.StatusNotFound},
+		{"report unknown", "POST", "/comments/99/reports", `{}`, user, http.StatusNotFound},
+		{"queue as user", "GET", "/admin/comments", "", user, http.StatusForbidden},
+		{"queue as editor", "GET", "/admin/comments", "", accessToken(t, app, editorEmail), http.StatusForbidden},
+		{"bad status filter", "GET", "/admin/comments?status=spam", "", admin, http.StatusBadRequest},
+		{"bad status", "PUT", fmt.Sprintf("/admin/comments/%d", pending.ID), `{"status": "spam"}`, admin, http.StatusBadRequest},
+		{"moderate unknown", "PUT", "/admin/comments/99", `{"status": "approved"}`, admin, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(app, tt.method, tt.target, tt.body, tt.token)
+			if rr.Code != tt.status {
+				t.Errorf("expected %d but got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+}
@@ -1,33 +1,125 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/jackc/pgconn"
-	_ "github.com/jackc/pgx/v4"
-	_ "github.com/jackc/pgx/v4/stdlib"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"time"
+	"watch-a-movie/internal/migrations"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+	"watch-a-movie/internal/repository/dbrepo"
 )
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("pgx", dsn)
+// migrate brings the schema up to date before the server starts.
+func migrate(db *sql.DB) error {
+	migrator, err := migrations.New(db)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, err
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
 	}
+	return err
+}
+
+func (app *application) connectToDB() (repository.DatabaseRepo, error) {
+	switch app.DBDriver {
+	case "postgres":
+		repo, err := dbrepo.NewPostgresDBRepo(app.DSN, app.DBTimeout)
+		if err != nil {
+			return nil, err
+		}
+		repo.PosterBaseURL = app.PosterBaseURL
+		repo.PosterVariantsURL = app.PosterVariantsURL
+
+		log.Println("Connected to database")
+
+		if app.Migrate {
+			err = migrate(repo.DB)
+			if err != nil {
+				repo.DB.Close()
+				return nil, err
+			}
+		}
 
-	return db, nil
+		return repo, nil
+
+	case "sqlite":
+		repo, err := dbrepo.NewSQLiteDBRepo(app.DSN, app.DBTimeout)
+		if err != nil {
+			return nil, err
+		}
+		repo.PosterBaseURL = app.PosterBaseURL
+		repo.PosterVariantsURL = app.PosterVariantsURL
+
+		log.Printf("Connected to sqlite database %s", app.DSN)
+		if app.Migrate {
+			log.Println("-migrate has no effect with sqlite, the schema is created on open")
+		}
+		return repo, nil
+
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", app.DBDriver)
+	}
 }
 
-func (app *application) connectToDB() (*sql.DB, error) {
-	connection, err := openDB(app.DSN)
+// bootstrapAdmin makes the user with the given email an admin if nobody is
+// one yet, so that a fresh install has a way into the admin api.
+func (app *application) bootstrapAdmin(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	users, err := app.DB.AllUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if slices.Contains(u.Roles, models.RoleAdmin) {
+			return nil
+		}
+	}
+
+	user, err := app.DB.GetUserByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("admin email: no user with email %s, register first", email)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = app.DB.SetUserRoles(ctx, user.ID, append(user.Roles, models.RoleAdmin))
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	log.Printf("Granted the admin role to %s", user.Email)
+	return nil
+}
+
+// seedDemo fills an empty catalog with demo data. The admin login it creates
+// is public, so say so loudly.
+func (app *application) seedDemo() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	seeded, err := dbrepo.SeedDemo(ctx, app.DB)
+	if err != nil || !seeded {
+		return err
 	}
 
-	log.Println("Connected to database")
-	return connection, nil
+	log.Println("************************************************************")
+	log.Println("Seeded demo data with an admin login anyone can use:")
+	log.Printf("    %s / %s", dbrepo.DemoAdminEmail, dbrepo.DemoAdminPassword)
+	log.Println("Do not expose this database; change or delete that user.")
+	log.Println("************************************************************")
+	return nil
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"watch-a-movie/internal/models"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	app := newTestApp(t)
+
+	// there is already an admin, so nothing changes
+	err := app.bootstrapAdmin(editorEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor, _ := app.DB.GetUserByEmail(t.Context(), editorEmail)
+	if slices.Contains(editor.Roles, models.RoleAdmin) {
+		t.Fatal("granted admin although an admin exists")
+	}
+
+	// without one, the named user is promoted and keeps their other roles
+	err = app.DB.SetUserRoles(t.Context(), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.bootstrapAdmin(" Editor@Example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor, _ = app.DB.GetUserByEmail(t.Context(), editorEmail)
+	if !slices.Contains(editor.Roles, models.RoleAdmin) || !slices.Contains(editor.Roles, models.RoleEditor) {
+		t.Errorf("unexpected roles %v", editor.Roles)
+	}
+
+	err = app.DB.SetUserRoles(t.Context(), editor.ID, []string{models.RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.bootstrapAdmin("nobody@example.com")
+	if err == nil {
+		t.Error("expected an error for an unknown email")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"watch-a-movie/internal/enrichment"
+	"watch-a-movie/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// posterClient downloads the posters metadata providers point at.
+var posterClient = &http.Client{Timeout: 10 * time.Second}
+
+// metadataClient makes the requests to the metadata provider.
+var metadataClient = &http.Client{Timeout: 10 * time.Second}
+
+// enrichOnInsertTimeout caps how long inserting a movie waits on the metadata
+// provider, poster included, before answering without it.
+var enrichOnInsertTimeout = 15 * time.Second
+
+// EnrichMovie fills in what a movie is missing from the metadata provider,
+// looked up by the movie's IMDb ID: title, release year, runtime, rating,
+// MPAA rating, description and poster. Nothing already set is changed.
+func (app *application) EnrichMovie(w http.ResponseWriter, r *http.Request) {
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if app.metadata == nil {
+		app.errorJSON(w, errors.New("no metadata provider is configured"), http.StatusNotImplemented)
+		return
+	}
+
+	movie, err := app.DB.GetMovieByID(r.Context(), movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if movie.IMDbID == "" {
+		app.errorJSON(w, errors.New("the movie has no IMDb ID to look it up by"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	md, err := app.metadata.Lookup(r.Context(), movie.IMDbID)
+	if errors.Is(err, enrichment.ErrNotFound) {
+		app.errorJSON(w, fmt.Errorf("the metadata provider has no movie with IMDb ID %s", movie.IMDbID), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		log.Printf("looking up %s: %s", movie.IMDbID, err)
+		app.errorJSON(w, errors.New("the metadata provider is unavailable"), http.StatusBadGateway)
+		return
+	}
+
+	filled, err := app.applyMetadata(r.Context(), movie, md)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	movie, err = app.DB.GetMovieByID(r.Context(), movieID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "movie enriched",
+		Data: map[string]interface{}{
+			"movie":  movie,
+			"filled": filled,
+		},
+	}
+	if len(filled) == 0 {
+		resp.Message = "nothing to fill in"
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// enrichNewMovie fills in what a movie that was just inserted is missing,
+// giving up after enrichOnInsertTimeout. Failures are only logged, as the
+// movie itself was saved.
+func (app *application) enrichNewMovie(ctx context.Context, movieID int) {
+	ctx, cancel := context.WithTimeout(ctx, enrichOnInsertTimeout)
+	defer cancel()
+
+	movie, err := app.DB.GetMovieByID(ctx, movieID)
+	if err != nil {
+		log.Printf("enriching movie %d: %s", movieID, err)
+		return
+	}
+
+	md, err := app.metadata.Lookup(ctx, movie.IMDbID)
+	if err != nil {
+		log.Printf("enriching movie %d: looking up %s: %s", movieID, movie.IMDbID, err)
+		return
+	}
+
+	_, err = app.applyMetadata(ctx, movie, md)
+	if err != nil {
+		log.Printf("enriching movie %d: %s", movieID, err)
+	}
+}
+
+// applyMetadata saves what md adds to movie and returns the json names of the
+// fields it filled in. A poster that can't be downloaded or isn't usable is
+// skipped, rather than failing the rest.
+func (app *application) applyMetadata(ctx context.Context, movie *models.Movie, md *enrichment.Metadata) ([]string, error) {
+	filled := enrichment.Fill(movie, md)
+
+	if len(filled) > 0 {
+		movie.UpdatedAt = time.Now()
+		err := app.DB.UpdateMovie(ctx, *movie)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if movie.Poster == "" && md.PosterURL != "" {
+		err := app.fetchMetadataPoster(ctx, movie, md.PosterURL)
+		if err != nil {
+			log.Printf("poster for movie %d from %s: %s", movie.ID, md.PosterURL, err)
+		} else {
+			filled = append(filled, "poster")
+		}
+	}
+
+	return filled, nil
+}
+
+// fetchMetadataPoster downloads the poster at url and makes it the poster of
+// movie, with the same checks as an upload.
+func (app *application) fetchMetadataPoster(ctx context.Context, movie *models.Movie, url string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return errors.New("not an http url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := posterClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPosterBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxPosterBytes {
+		return errPosterTooBig
+	}
+
+	_, contentType, err := checkPoster(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = app.savePoster(ctx, movie, data, contentType)
+	return err
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"watch-a-movie/internal/enrichment"
+	"watch-a-movie/internal/models"
+)
+
+// omdbStub answers OMDb lookups for Pulp Fiction and serves its poster.
+func omdbStub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	poster := pngImage(t, 300, 450)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pulp.png" {
+			w.Write(poster)
+			return
+		}
+
+		switch r.URL.Query().Get("i") {
+		case "tt0110912":
+			w.Write([]byte(`{"Title":"Pulp Fiction","Year":"1994","Rated":"R","Runtime":"154 min",
+				"Plot":"The lives of two mob hitmen, a boxer and a pair of diner bandits intertwine.",
+				"Poster":"` + srv.URL + `/pulp.png","imdbRating":"8.9","Response":"True"}`))
+		case "tt0000001":
+			w.Write([]byte(`{"Title":"Gone","Year":"1900","Poster":"` + srv.URL + `/missing.png","Response":"True"}`))
+		default:
+			w.Write([]byte(`{"Response":"False","Error":"Incorrect IMDb ID."}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newEnrichTestApp(t *testing.T) *application {
+	app := newTestApp(t)
+	app.metadata = enrichment.OMDbClient{BaseURL: omdbStub(t).URL, APIKey: "key"}
+	return app
+}
+
+func TestInsertMovieEnriched(t *testing.T) {
+	app := newEnrichTestApp(t)
+	app.EnrichOnInsert = true
+	token := accessToken(t, app, editorEmail)
+
+	rr := request(app, "PUT", "/admin/movies/0", `{"imdbId": "https://www.imdb.com/title/tt0110912/", "genres_array": [2]}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	movie, err := app.DB.GetMovieByID(t.Context(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "Pulp Fiction" || movie.Release != 1994 || movie.Runtime() != 154 || movie.MPAA != "R" || movie.IMDb != 8.9 {
+		t.Errorf("expected the movie to be filled in but got %+v", movie)
+	}
+	if !strings.HasPrefix(movie.Poster, app.PosterBaseURL+"4-") || !strings.HasSuffix(movie.Poster, ".png") {
+		t.Errorf("expected the poster to be downloaded but got %q", movie.Poster)
+	}
+
+	// and it can be turned off for one insert
+	rr = request(app, "PUT", "/admin/movies/0?enrich=false", `{"title": "Pulp", "imdbId": "tt0110912"}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	movie, _ = app.DB.GetMovieByID(t.Context(), 5)
+	if movie.Title != "Pulp" || movie.Release != 0 || movie.Poster != "" {
+		t.Errorf("expected the movie as sent but got %+v", movie)
+	}
+}
+
+func TestInsertMovieEnrichTimesOut(t *testing.T) {
+	// a provider that never answers
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	app := newTestApp(t)
+	app.metadata = enrichment.OMDbClient{BaseURL: srv.URL, APIKey: "key"}
+	app.EnrichOnInsert = true
+
+	timeout := enrichOnInsertTimeout
+	enrichOnInsertTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { enrichOnInsertTimeout = timeout })
+
+	start := time.Now()
+	rr := request(app, "PUT", "/admin/movies/0", `{"title": "Pulp", "imdbId": "tt0110912"}`, accessToken(t, app, editorEmail))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the insert not to wait on the provider but it took %s", elapsed)
+	}
+
+	movie, err := app.DB.GetMovieByID(t.Context(), 4)
+	if err != nil || movie.Title != "Pulp" {
+		t.Errorf("expected the movie as sent but got %+v, %v", movie, err)
+	}
+}
+
+func TestEnrichMovie(t *testing.T) {
+	app := newEnrichTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	// a movie of which only the title and IMDb ID are known
+	rr := request(app, "PUT", "/admin/movies/0", `{"title": "Pulp", "imdbId": "tt0110912"}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = request(app, "POST", "/admin/movies/4/enrich", "", token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			Movie  models.Movie `json:"movie"`
+			Filled []string     `json:"filled"`
+		} `json:"data"`
+	}
+	decode(t, rr, &resp)
+
+	if got := strings.Join(resp.Data.Filled, ","); got != "release,runtime,imdb,mpaa,description,poster" {
+		t.Errorf("unexpected fields filled %s", got)
+	}
+	if resp.Data.Movie.Title != "Pulp" || resp.Data.Movie.Release != 1994 || resp.Data.Movie.PosterVariants == nil {
+		t.Errorf("unexpected movie %+v", resp.Data.Movie)
+	}
+
+	// a second time there is nothing left to do
+	rr = request(app, "POST", "/admin/movies/4/enrich", "", token)
+	var again JSONResponse
+	decode(t, rr, &again)
+	if rr.Code != http.StatusAccepted || again.Message != "nothing to fill in" {
+		t.Errorf("expected nothing to fill in but got %d: %+v", rr.Code, again)
+	}
+
+	// a poster that can't be downloaded doesn't stop the rest
+	request(app, "PUT", "/admin/movies/0", `{"imdbId": "tt0000001"}`, token)
+	rr = request(app, "POST", "/admin/movies/5/enrich", "", token)
+	decode(t, rr, &resp)
+	if rr.Code != http.StatusAccepted || strings.Join(resp.Data.Filled, ",") != "title,release" {
+		t.Errorf("expected the title and release to be filled but got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestEnrichMovieErrors(t *testing.T) {
+	app := newEnrichTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	request(app, "PUT", "/admin/movies/0", `{"title": "No ID"}`, token)
+	request(app, "PUT", "/admin/movies/0", `{"title": "Unknown", "imdbId": "tt9999999"}`, token)
+
+	tests := []struct {
+		name   string
+		movie  string
+		token  string
+		status int
+	}{
+		{"unknown movie", "99", token, http.StatusNotFound},
+		{"no imdb id", "4", token, http.StatusUnprocessableEntity},
+		{"unknown imdb id", "5", token, http.StatusUnprocessableEntity},
+		{"viewer", "4", accessToken(t, app, viewerEmail), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(app, "POST", "/admin/movies/"+tt.movie+"/enrich", "", tt.token)
+			if rr.Code != tt.status {
+				t.Errorf("expected %d but got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	// the provider is down
+	app.metadata = enrichment.OMDbClient{BaseURL: "http://127.0.0.1:1/", APIKey: "key"}
+	rr := request(app, "POST", "/admin/movies/1/enrich", "", token)
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	// or not configured at all
+	app.metadata = nil
+	rr = request(app, "POST", "/admin/movies/1/enrich", "", token)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 but got %d: %s", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"watch-a-movie/internal/models"
+)
+
+// exportFormats maps the format query parameter of an export to its
+// Content-Type.
+var exportFormats = map[string]string{
+	"csv":   "text/csv; charset=utf-8",
+	"jsonl": "application/x-ndjson",
+	"xml":   "application/xml; charset=utf-8",
+}
+
+// exportXMLMovie is a movie as written to an XML export.
+type exportXMLMovie struct {
+	XMLName     xml.Name `xml:"movie"`
+	ID          int      `xml:"id,attr"`
+	Title       string   `xml:"title"`
+	Release     int      `xml:"release"`
+	Runtime     int      `xml:"runtime"`
+	MPAA        string   `xml:"mpaa"`
+	IMDb        float32  `xml:"imdb"`
+	IMDbID      string   `xml:"imdb_id"`
+	Description string   `xml:"description"`
+	Poster      string   `xml:"poster"`
+	Genres      []string `xml:"genres>genre"`
+}
+
+// ExportMovies streams the catalog as a file download, a movie at a time, in
+// the format given by the format query parameter: csv (the default), jsonl or
+// xml. CSV and JSON Lines exports use the columns of ImportMovies, so they can
+// be imported again as they are. The genre, mpaa, year_from and year_to
+// filters work as in readMovieFilter.
+func (app *application) ExportMovies(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	format := qs.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportFormats[format]
+	if !ok {
+		app.errorJSON(w, errors.New("format must be csv, jsonl or xml"))
+		return
+	}
+
+	filter, err := readMovieFilter(qs)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	buf := bufio.NewWriter(w)
+	out := newMovieWriter(format, buf)
+
+	// headers go out with the first bytes of the file; until then an error
+	// can still be sent as JSON
+	started := false
+	begin := func() error {
+		started = true
+
+		name := fmt.Sprintf("movies-%s.%s", time.Now().Format("20060102"), format)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.WriteHeader(http.StatusOK)
+
+		return out.begin()
+	}
+
+	count := 0
+	err = app.DB.EachMovie(r.Context(), filter, func(movie *models.Movie) error {
+		if !started {
+			err := begin()
+			if err != nil {
+				return err
+			}
+		}
+
+		count++
+		return out.write(movie)
+	})
+	if err != nil && !started {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// an empty export is still a well-formed file
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = out.end()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		// too late for a status code; the client gets a truncated file
+		log.Printf("export failed after %d movies: %s", count, err)
+	}
+}
+
+// movieWriter writes movies to an export file: begin before the first movie
+// and end after the last.
+type movieWriter struct {
+	begin func() error
+	write func(movie *models.Movie) error
+	end   func() error
+}
+
+// newMovieWriter returns a movieWriter for one of the exportFormats.
+func newMovieWriter(format string, w io.Writer) movieWriter {
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		return movieWriter{
+			begin: func() error { return nil },
+			write: func(movie *models.Movie) error { return enc.Encode(exportRecord(movie)) },
+			end:   func() error { return nil },
+		}
+
+	case "xml":
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		movies := xml.StartElement{Name: xml.Name{Local: "movies"}}
+
+		return movieWriter{
+			begin: func() error {
+				_, err := io.WriteString(w, xml.Header)
+				if err != nil {
+					return err
+				}
+				return enc.EncodeToken(movies)
+			},
+			write: func(movie *models.Movie) error {
+				record := exportRecord(movie)
+				return enc.Encode(exportXMLMovie{
+					ID:          movie.ID,
+					Title:       record.Title,
+					Release:     record.Release,
+					Runtime:     record.Runtime,
+					MPAA:        record.MPAA,
+					IMDb:        record.IMDb,
+					IMDbID:      record.IMDbID,
+					Description: record.Description,
+					Poster:      record.Poster,
+					Genres:      record.Genres,
+				})
+			},
+			end: func() error {
+				err := enc.EncodeToken(movies.End())
+				if err != nil {
+					return err
+				}
+				return enc.Flush()
+			},
+		}
+
+	default:
+		cw := csv.NewWriter(w)
+		return movieWriter{
+			begin: func() error { return cw.Write(importColumns) },
+			write: func(movie *models.Movie) error { return cw.Write(csvRecord(movie)) },
+			end: func() error {
+				cw.Flush()
+				return cw.Error()
+			},
+		}
+	}
+}
+
+// exportRecord is movie in the shape ImportMovies reads. Posters are written
+// as bare file names, as they are stored.
+func exportRecord(movie *models.Movie) importRecord {
+	record := importRecord{
+		Title:       movie.Title,
+		Release:     movie.Release,
+		Runtime:     movie.Runtime(),
+		MPAA:        movie.MPAA,
+		IMDb:        movie.IMDb,
+		IMDbID:      movie.IMDbID,
+		Description: movie.Description,
+		Poster:      movie.Poster[strings.LastIndex(movie.Poster, "/")+1:],
+		Genres:      []string{},
+	}
+	for _, g := range movie.Genres {
+		record.Genres = append(record.Genres, g.Genre)
+	}
+
+	return record
+}
+
+// csvRecord is movie as a row under importColumns.
+func csvRecord(movie *models.Movie) []string {
+	record := exportRecord(movie)
+
+	imdb := ""
+	if record.IMDb != 0 {
+		imdb = strconv.FormatFloat(float64(record.IMDb), 'f', -1, 32)
+	}
+
+	return []string{
+		record.Title,
+		strconv.Itoa(record.Release),
+		strconv.Itoa(record.Runtime),
+		record.MPAA,
+		imdb,
+		record.IMDbID,
+		record.Description,
+		record.Poster,
+		strings.Join(record.Genres, "|"),
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportMoviesCSV(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, viewerEmail)
+
+	rr := request(app, "GET", "/admin/movies/export", "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected a CSV content type but got %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="movies-`) || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("expected an attachment but got %q", cd)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(rr.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || strings.Join(records[0], ",") != strings.Join(importColumns, ",") {
+		t.Fatalf("expected a header and 3 movies but got %q", records)
+	}
+
+	// in title order
+	godfather := records[3]
+	expected := []string{"The Godfather", "1972", "175", "R", "9.2", "tt0068646", godfather[6], "godfather.jpg", "Crime|Drama"}
+	if strings.Join(godfather, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %q but got %q", expected, godfather)
+	}
+
+	// an export imports back as updates of the same movies
+	rr = importRequest(app, "", "text/csv", rr.Body.String(), accessToken(t, app, editorEmail))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("re-import: expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp importResponse
+	decode(t, rr, &resp)
+	if resp.Data.Updated != 3 || resp.Data.Created != 0 {
+		t.Errorf("re-import: expected 3 updates but got %+v", resp.Data)
+	}
+
+	movie, _ := app.DB.OneMovie(t.Context(), 1)
+	if movie.Runtime() != 175 || len(movie.Genres) != 2 {
+		t.Errorf("re-import changed the movie: %+v", movie)
+	}
+}
+
+func TestExportMoviesFormatsAndFilters(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, viewerEmail)
+
+	rr := request(app, "GET", "/admin/movies/export?format=jsonl&genre=2&year_from=1990", "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"title":"Heat"`) {
+		t.Errorf("expected only Heat but got %q", rr.Body)
+	}
+
+	rr = request(app, "GET", "/admin/movies/export?format=xml&mpaa=R", "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var doc struct {
+		Movies []exportXMLMovie `xml:"movie"`
+	}
+	err := xml.Unmarshal(rr.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatalf("decoding %q: %s", rr.Body, err)
+	}
+	if len(doc.Movies) != 2 || doc.Movies[0].Title != "Heat" || len(doc.Movies[1].Genres) != 2 {
+		t.Errorf("unexpected movies %+v", doc.Movies)
+	}
+
+	// nothing matches, but the file is still well formed
+	rr = request(app, "GET", "/admin/movies/export?format=xml&year_from=2100", "", token)
+	doc.Movies = nil
+	err = xml.Unmarshal(rr.Body.Bytes(), &doc)
+	if rr.Code != http.StatusOK || err != nil || len(doc.Movies) != 0 {
+		t.Errorf("expected an empty document but got %d %q", rr.Code, rr.Body)
+	}
+
+	rr = request(app, "GET", "/admin/movies/export?format=xlsx", "", token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: expected 400 but got %d", rr.Code)
+	}
+
+	rr = request(app, "GET", "/admin/movies/export?year_from=soon", "", token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("bad filter: expected 400 but got %d", rr.Code)
+	}
+
+	rr = request(app, "GET", "/admin/movies/export", "", "")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: expected 401 but got %d", rr.Code)
+	}
+}
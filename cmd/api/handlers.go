@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/golang-jwt/jwt/v4"
 	"log"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
 )
 
+var errInvalidCredentials = errors.New("invalid credentials")
+
 func (app *application) Home(w http.ResponseWriter, r *http.Request) {
 	var payLoad = struct {
 		Status  string `json:"status"`
@@ -30,19 +39,100 @@ func (app *application) Home(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// AllMovies returns one page of the catalog. See readMovieFilter for the
+// query parameters it understands.
 func (app *application) AllMovies(w http.ResponseWriter, r *http.Request) {
-	movies, err := app.DB.AllMovies()
+	filter, err := readMovieFilter(r.URL.Query())
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, movies)
+	movies, total, err := app.DB.ListMovies(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var payload = struct {
+		Items    []*models.Movie `json:"items"`
+		Total    int             `json:"total"`
+		Page     int             `json:"page"`
+		PageSize int             `json:"page_size"`
+		Next     string          `json:"next,omitempty"`
+		Prev     string          `json:"prev,omitempty"`
+	}{
+		Items:    movies,
+		Total:    total,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+	}
+
+	app.markWatchlist(w, r, movies...)
+
+	err = app.addCommunityRatings(r.Context(), movies...)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if filter.Offset()+len(movies) < total {
+		payload.Next = pageLink(r.URL, filter.Page+1)
+	}
+	if filter.Page > 1 {
+		payload.Prev = pageLink(r.URL, filter.Page-1)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, payload)
 	if err != nil {
 		log.Println(err)
 	}
 }
 
+func (app *application) SearchMovies(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		app.errorJSON(w, errors.New("q is required"))
+		return
+	}
+	if len(q) > 200 {
+		app.errorJSON(w, errors.New("q is too long"))
+		return
+	}
+
+	limit := models.DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			app.errorJSON(w, fmt.Errorf("limit must be between 1 and %d", models.MaxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	results, err := app.DB.SearchMovies(r.Context(), q, limit)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	movies := make([]*models.Movie, 0, len(results))
+	for _, result := range results {
+		movies = append(movies, &result.Movie)
+	}
+	app.markWatchlist(w, r, movies...)
+
+	var payload = struct {
+		Query   string                      `json:"query"`
+		Results []*models.MovieSearchResult `json:"results"`
+	}{
+		Query:   q,
+		Results: results,
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
 func (app *application) authenticate(w http.ResponseWriter, r *http.Request) {
 	// read json payload
 	var requestPayload struct {
@@ -53,44 +143,64 @@ func (app *application) authenticate(w http.ResponseWriter, r *http.Request) {
 	err := app.readJSON(w, r, &requestPayload)
 	if err != nil {
 		app.errorJSON(w, err, http.StatusBadRequest)
-		log.Println(1)
 		return
 	}
 
 	// validate user against database
-	user, err := app.DB.GetUserByEmail(requestPayload.Email)
+	user, err := app.DB.GetUserByEmail(r.Context(), normalizeEmail(requestPayload.Email))
 	if err != nil {
-		app.errorJSON(w, errors.New("invalid credentials"), http.StatusBadRequest)
-		log.Println(2)
+		// spend the same time as a real check so the response doesn't
+		// reveal whether the email exists
+		_, _ = app.hasher.Verify(requestPayload.Password, app.dummyHash)
+		app.errorJSON(w, errInvalidCredentials, http.StatusBadRequest)
 		return
 	}
 
 	// check password
 	valid, err := user.ValidatePassword(requestPayload.Password)
-	if err != nil {
-		app.errorJSON(w, err, http.StatusBadRequest)
-		log.Println(3)
+	if err != nil || !valid {
+		if err != nil {
+			log.Println(err)
+		}
+		app.errorJSON(w, errInvalidCredentials, http.StatusBadRequest)
 		return
 	}
 
-	if !valid {
-		app.errorJSON(w, errors.New("invalid credentials"), http.StatusBadRequest)
-		log.Println(4)
+	// only said once the password checked out, so it leaks nothing
+	if user.Disabled {
+		app.errorJSON(w, errors.New("account disabled"), http.StatusForbidden)
 		return
 	}
 
+	// upgrade cleartext or weaker hashes now that we know the password
+	if app.hasher.NeedsRehash(user.Password) {
+		hash, err := app.hasher.Hash(requestPayload.Password)
+		if err == nil {
+			err = app.DB.UpdateUserPassword(r.Context(), user.ID, hash)
+		}
+		if err != nil {
+			log.Println("rehash password:", err)
+		}
+	}
+
 	// create a jwt user
 	u := jwtUser{
 		ID:        user.ID,        // Use actual user ID
 		FirstName: user.FirstName, // Use actual user data
 		LastName:  user.LastName,  // Use actual user data
+		Roles:     user.Roles,
 	}
 
 	// generate tokens
 	tokens, err := app.auth.GenerateTokenPair(&u)
 	if err != nil {
 		app.errorJSON(w, err)
-		log.Println(4)
+		return
+	}
+
+	err = app.DB.InsertSession(r.Context(), newSession(tokens, user.ID, ""))
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -100,51 +210,175 @@ func (app *application) authenticate(w http.ResponseWriter, r *http.Request) {
 	app.writeJSON(w, http.StatusAccepted, tokens)
 }
 
+func (app *application) register(w http.ResponseWriter, r *http.Request) {
+	var requestPayload struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Password  string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	requestPayload.FirstName = strings.TrimSpace(requestPayload.FirstName)
+	requestPayload.LastName = strings.TrimSpace(requestPayload.LastName)
+	requestPayload.Email = normalizeEmail(requestPayload.Email)
+
+	if requestPayload.FirstName == "" || requestPayload.LastName == "" {
+		app.errorJSON(w, errors.New("first and last name are required"), http.StatusBadRequest)
+		return
+	}
+
+	err = validateEmail(requestPayload.Email)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = validatePassword(requestPayload.Password)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// make sure the email isn't taken
+	_, err = app.DB.GetUserByEmail(r.Context(), requestPayload.Email)
+	if err == nil {
+		app.errorJSON(w, errors.New("email is already registered"), http.StatusConflict)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	hash, err := app.hasher.Hash(requestPayload.Password)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	user := models.User{
+		FirstName: requestPayload.FirstName,
+		LastName:  requestPayload.LastName,
+		Email:     requestPayload.Email,
+		Password:  hash,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	user.ID, err = app.DB.InsertUser(r.Context(), user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// lost a race with another registration for the same email
+		app.errorJSON(w, errors.New("email is already registered"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// log the new user straight in
+	u := jwtUser{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Roles:     user.Roles,
+	}
+
+	tokens, err := app.auth.GenerateTokenPair(&u)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.InsertSession(r.Context(), newSession(tokens, user.ID, ""))
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, app.auth.GetRefreshCookie(tokens.RefreshToken))
+
+	app.writeJSON(w, http.StatusCreated, tokens)
+}
+
 func (app *application) refreshToken(w http.ResponseWriter, r *http.Request) {
-	for _, cookie := range r.Cookies() {
-		if cookie.Name == app.auth.CookieName {
-			claims := &Claims{}
-			refreshToken := cookie.Value
-
-			// parse token to get the claims
-			_, err := jwt.ParseWithClaims(refreshToken, claims, func(token *jwt.Token) (interface{}, error) {
-				return []byte(app.JWTSecret), nil
-			})
-			if err != nil {
-				app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
-				return
-			}
+	cookie, err := r.Cookie(app.auth.CookieName)
+	if err != nil {
+		app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
 
-			// get the user id from the token claims
-			userID, err := strconv.Atoi(claims.Subject)
-			if err != nil {
-				app.errorJSON(w, errors.New("unknown user"), http.StatusUnauthorized)
-				return
-			}
+	// parse token to get the claims
+	claims, err := app.auth.ParseRefreshToken(cookie.Value, false)
+	if err != nil {
+		app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
 
-			user, err := app.DB.GetUserByID(userID)
-			if err != nil {
-				app.errorJSON(w, errors.New("unknown user"), http.StatusUnauthorized)
-				return
-			}
+	session, err := app.DB.GetSession(r.Context(), claims.ID)
+	if err != nil {
+		app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
 
-			u := jwtUser{
-				ID:        user.ID,
-				FirstName: user.FirstName,
-				LastName:  user.LastName,
-			}
+	// a refresh token that was already exchanged is being replayed, so
+	// assume it was stolen and kill every token descended from the login
+	if session.Rotated() {
+		app.revokeSessionFamily(r.Context(), session)
+		app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
 
-			tokenPairs, err := app.auth.GenerateTokenPair(&u)
-			if err != nil {
-				app.errorJSON(w, errors.New("unknown user"), http.StatusUnauthorized)
-				return
-			}
+	if !session.Active() {
+		app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
+	}
 
-			http.SetCookie(w, app.auth.GetRefreshCookie(tokenPairs.RefreshToken))
+	// get the user id from the token claims
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID != session.UserID {
+		app.errorJSON(w, errors.New("unknown user"), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := app.DB.GetUserByID(r.Context(), userID)
+	if err != nil || user.Disabled {
+		app.errorJSON(w, errors.New("unknown user"), http.StatusUnauthorized)
+		return
+	}
+
+	u := jwtUser{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Roles:     user.Roles,
+	}
 
-			app.writeJSON(w, http.StatusOK, user)
+	tokenPairs, err := app.auth.GenerateTokenPair(&u)
+	if err != nil {
+		app.errorJSON(w, errors.New("unknown user"), http.StatusUnauthorized)
+		return
+	}
+
+	err = app.DB.RotateSession(r.Context(), session.ID, newSession(tokenPairs, user.ID, session.FamilyID))
+	if err != nil {
+		// lost a race with another request using the same token
+		if errors.Is(err, repository.ErrSessionRotated) {
+			app.revokeSessionFamily(r.Context(), session)
 		}
+		app.errorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+		return
 	}
+
+	http.SetCookie(w, app.auth.GetRefreshCookie(tokenPairs.RefreshToken))
+
+	app.writeJSON(w, http.StatusOK, user)
 }
 
 func (app *application) displayMovie(w http.ResponseWriter, r *http.Request) {
@@ -158,7 +392,7 @@ func (app *application) displayMovie(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	movie, err := app.DB.GetMovieByID(requestPayload.ID)
+	movie, err := app.DB.GetMovieByID(r.Context(), requestPayload.ID)
 	if err != nil {
 		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
 		return
@@ -168,12 +402,24 @@ func (app *application) displayMovie(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) logout(w http.ResponseWriter, r *http.Request) {
+	// revoke the session server-side so the refresh token can't be replayed
+	cookie, err := r.Cookie(app.auth.CookieName)
+	if err == nil {
+		claims, err := app.auth.ParseRefreshToken(cookie.Value, true)
+		if err == nil {
+			session, err := app.DB.GetSession(r.Context(), claims.ID)
+			if err == nil {
+				app.revokeSessionFamily(r.Context(), session)
+			}
+		}
+	}
+
 	http.SetCookie(w, app.auth.GetExpiredRefreshCookie())
 	w.WriteHeader(http.StatusAccepted)
 }
 
 func (app *application) MovieCatalog(w http.ResponseWriter, r *http.Request) {
-	movies, err := app.DB.AllMovies()
+	movies, err := app.DB.AllMovies(r.Context())
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -193,12 +439,26 @@ func (app *application) GetMovie(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	movie, err := app.DB.OneMovie(movieID)
+	movie, err := app.DB.OneMovie(r.Context(), movieID)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
 
+	app.markWatchlist(w, r, movie)
+
+	err = app.addCommunityRatings(r.Context(), movie)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = app.addCast(r.Context(), movie)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
 	_ = app.writeJSON(w, http.StatusOK, movie)
 }
 
@@ -210,7 +470,7 @@ func (app *application) MovieForEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	movie, genres, err := app.DB.OneMovieForEdit(movieID)
+	movie, genres, err := app.DB.OneMovieForEdit(r.Context(), movieID)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -228,7 +488,7 @@ func (app *application) MovieForEdit(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) AllGenres(w http.ResponseWriter, r *http.Request) {
-	genres, err := app.DB.AllGenres()
+	genres, err := app.DB.AllGenres(r.Context())
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -254,20 +514,99 @@ func (app *application) InsertMovie(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	// try to get an image
+
+	// the enrich query parameter overrides -enrich-on-insert
+	enrich := app.EnrichOnInsert
+	if v := r.URL.Query().Get("enrich"); v != "" {
+		enrich, err = boolParam(v, "enrich")
+		if err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+	}
+
+	if !app.checkGenreIDs(w, r, movie.GenresArray) {
+		return
+	}
 
 	movie.CreatedAt = time.Now()
 	movie.UpdatedAt = time.Now()
 
-	newID, err := app.DB.InsertMovie(movie)
+	// the movie and its genres are saved together or not at all
+	var newID int
+	err = app.DB.WithTx(r.Context(), func(repo repository.DatabaseRepo) error {
+		newID, err = repo.InsertMovie(r.Context(), movie)
+		if err != nil {
+			return err
+		}
+
+		// now handle genres
+		return repo.UpdateMovieGenres(r.Context(), newID, movie.GenresArray)
+	})
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
 
-	// now handle genres
-	err = app.DB.UpdateMovieGenres(newID, movie.GenresArray)
+	// fill in the rest from the metadata provider, including a poster
+	if enrich && movie.IMDbID != "" && app.metadata != nil {
+		app.enrichNewMovie(r.Context(), newID)
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "movie updated",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (app *application) UpdateMovie(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	movieID, err := strconv.Atoi(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var payload models.Movie
+
+	err = app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if payload.IMDbID != "" {
+		payload.IMDbID = extractIMDbIdFromLink(payload.IMDbID)
+
+		if payload.IMDbID == "" {
+			app.errorJSON(w, errors.New("invalid IMDb ID format"))
+			return
+		}
+	}
+
+	if !app.checkGenreIDs(w, r, payload.GenresArray) {
+		return
+	}
+
+	// the id in the url wins over whatever came in the body
+	payload.ID = movieID
+	payload.UpdatedAt = time.Now()
+
+	err = app.DB.WithTx(r.Context(), func(repo repository.DatabaseRepo) error {
+		err := repo.UpdateMovie(r.Context(), payload)
+		if err != nil {
+			return err
+		}
+
+		return repo.UpdateMovieGenres(r.Context(), movieID, payload.GenresArray)
+	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+			return
+		}
 		app.errorJSON(w, err)
 		return
 	}
@@ -280,6 +619,380 @@ func (app *application) InsertMovie(w http.ResponseWriter, r *http.Request) {
 	app.writeJSON(w, http.StatusAccepted, resp)
 }
 
+func (app *application) DeleteMovie(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	movieID, err := strconv.Atoi(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.DeleteMovie(r.Context(), movieID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+			return
+		}
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "movie deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (app *application) InsertGenre(w http.ResponseWriter, r *http.Request) {
+	var genre models.Genre
+
+	err := app.readJSON(w, r, &genre)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	genre.Genre = strings.TrimSpace(genre.Genre)
+	if !app.checkGenreName(w, r, genre) {
+		return
+	}
+
+	genre.CreatedAt = time.Now()
+	genre.UpdatedAt = time.Now()
+
+	genre.ID, err = app.DB.InsertGenre(r.Context(), genre)
+	if errors.Is(err, repository.ErrDuplicate) {
+		app.errorJSON(w, fmt.Errorf("genre %q already exists", genre.Genre), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "genre created",
+		Data:    genre,
+	}
+
+	app.writeJSON(w, http.StatusCreated, resp)
+}
+
+func (app *application) UpdateGenre(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	genreID, err := strconv.Atoi(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var genre models.Genre
+
+	err = app.readJSON(w, r, &genre)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	genre.ID = genreID
+	genre.Genre = strings.TrimSpace(genre.Genre)
+	if !app.checkGenreName(w, r, genre) {
+		return
+	}
+
+	genre.UpdatedAt = time.Now()
+
+	err = app.DB.UpdateGenre(r.Context(), genre)
+	if errors.Is(err, repository.ErrDuplicate) {
+		app.errorJSON(w, fmt.Errorf("genre %q already exists", genre.Genre), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.errorJSON(w, errors.New("genre not found"), http.StatusNotFound)
+			return
+		}
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "genre updated",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (app *application) DeleteGenre(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	genreID, err := strconv.Atoi(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.DeleteGenre(r.Context(), genreID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.errorJSON(w, errors.New("genre not found"), http.StatusNotFound)
+			return
+		}
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "genre deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// MergeGenres folds the genre in the url into the one named in the body.
+func (app *application) MergeGenres(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	fromID, err := strconv.Atoi(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var requestPayload struct {
+		Into int `json:"into"`
+	}
+
+	err = app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if requestPayload.Into == fromID {
+		app.errorJSON(w, errors.New("cannot merge a genre into itself"))
+		return
+	}
+
+	if !app.checkGenreIDs(w, r, []int{requestPayload.Into}) {
+		return
+	}
+
+	err = app.DB.MergeGenres(r.Context(), fromID, requestPayload.Into)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.errorJSON(w, errors.New("genre not found"), http.StatusNotFound)
+			return
+		}
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "genres merged",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// checkGenreName makes sure genre has a name no other genre is using. It
+// writes the error response itself and returns false if not.
+func (app *application) checkGenreName(w http.ResponseWriter, r *http.Request, genre models.Genre) bool {
+	if genre.Genre == "" {
+		app.errorJSON(w, errors.New("genre name is required"))
+		return false
+	}
+
+	genres, err := app.DB.AllGenres(r.Context())
+	if err != nil {
+		app.errorJSON(w, err)
+		return false
+	}
+
+	for _, g := range genres {
+		if g.ID != genre.ID && strings.EqualFold(g.Genre, genre.Genre) {
+			app.errorJSON(w, fmt.Errorf("genre %q already exists", g.Genre), http.StatusConflict)
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkGenreIDs makes sure every id in ids is a genre. It writes a 422 listing
+// the unknown ids and returns false if not.
+func (app *application) checkGenreIDs(w http.ResponseWriter, r *http.Request, ids []int) bool {
+	unknown, err := app.DB.UnknownGenreIDs(r.Context(), ids)
+	if err != nil {
+		app.errorJSON(w, err)
+		return false
+	}
+
+	if len(unknown) > 0 {
+		resp := JSONResponse{
+			Error:   true,
+			Message: "unknown genre ids",
+			Data:    map[string][]int{"unknown_genre_ids": unknown},
+		}
+		app.writeJSON(w, http.StatusUnprocessableEntity, resp)
+		return false
+	}
+
+	return true
+}
+
+func (app *application) AllUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := app.DB.AllUsers(r.Context())
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, users)
+}
+
+func (app *application) UpdateUserRoles(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var requestPayload struct {
+		Roles []string `json:"roles"`
+	}
+
+	err = app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	for _, role := range requestPayload.Roles {
+		if !models.ValidRole(role) {
+			app.errorJSON(w, fmt.Errorf("unknown role %q", role))
+			return
+		}
+	}
+
+	_, err = app.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		app.errorJSON(w, errors.New("user not found"), http.StatusNotFound)
+		return
+	}
+
+	// an admin dropping their own admin role could leave nobody able to
+	// hand it back
+	claims := claimsFromContext(r.Context())
+	if claims != nil && claims.Subject == strconv.Itoa(userID) && !slices.Contains(requestPayload.Roles, models.RoleAdmin) {
+		app.errorJSON(w, errors.New("you can't remove your own admin role"), http.StatusConflict)
+		return
+	}
+
+	err = app.DB.SetUserRoles(r.Context(), userID, requestPayload.Roles)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "roles updated",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// readMovieFilter builds a models.MovieFilter from the query string:
+//
+//	page, page_size                    pagination, 1-based
+//	sort                               title, release, imdb, runtime or created_at; prefix with - for descending
+//	genre                              genre id
+//	mpaa                               comma separated ratings, e.g. PG,PG-13
+//	year_from, year_to                 release year range, inclusive
+//	min_imdb                           minimum IMDb rating
+//	runtime_min, runtime_max           runtime range in minutes, inclusive
+func readMovieFilter(qs url.Values) (models.MovieFilter, error) {
+	filter := models.MovieFilter{
+		Page:     1,
+		PageSize: models.DefaultPageSize,
+	}
+
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"page", &filter.Page},
+		{"page_size", &filter.PageSize},
+		{"genre", &filter.GenreID},
+		{"year_from", &filter.YearFrom},
+		{"year_to", &filter.YearTo},
+		{"runtime_min", &filter.RuntimeMin},
+		{"runtime_max", &filter.RuntimeMax},
+	}
+
+	for _, i := range ints {
+		v := qs.Get(i.key)
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("%s must be a positive integer", i.key)
+		}
+		*i.dst = n
+	}
+
+	if filter.Page < 1 {
+		return filter, errors.New("page must be a positive integer")
+	}
+	if filter.PageSize < 1 || filter.PageSize > models.MaxPageSize {
+		return filter, fmt.Errorf("page_size must be between 1 and %d", models.MaxPageSize)
+	}
+
+	if v := qs.Get("min_imdb"); v != "" {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil || f < 0 {
+			return filter, errors.New("min_imdb must be a positive number")
+		}
+		filter.MinIMDb = float32(f)
+	}
+
+	if v := qs.Get("mpaa"); v != "" {
+		for _, rating := range strings.Split(v, ",") {
+			rating = strings.TrimSpace(rating)
+			if rating != "" {
+				filter.MPAA = append(filter.MPAA, rating)
+			}
+		}
+	}
+
+	if v := qs.Get("sort"); v != "" {
+		filter.Desc = strings.HasPrefix(v, "-")
+		filter.Sort = strings.TrimPrefix(v, "-")
+
+		if _, ok := models.MovieSortColumns[filter.Sort]; !ok {
+			return filter, fmt.Errorf("cannot sort by %q", filter.Sort)
+		}
+	}
+
+	return filter, nil
+}
+
+// pageLink returns the path and query of u with the page parameter replaced.
+func pageLink(u *url.URL, page int) string {
+	qs := u.Query()
+	qs.Set("page", strconv.Itoa(page))
+
+	return u.Path + "?" + qs.Encode()
+}
+
 func extractIMDbIdFromLink(imdbLink string) string {
 	imdbLink = strings.TrimSpace(imdbLink)
 
@@ -292,3 +1005,72 @@ func extractIMDbIdFromLink(imdbLink string) string {
 
 	return match
 }
+
+// newSession builds the session record for the refresh token in tokens. An
+// empty familyID starts a new family, as on login.
+func newSession(tokens TokenPairs, userID int, familyID string) models.Session {
+	if familyID == "" {
+		familyID = tokens.RefreshTokenID
+	}
+
+	return models.Session{
+		ID:        tokens.RefreshTokenID,
+		FamilyID:  familyID,
+		UserID:    userID,
+		ExpiresAt: tokens.RefreshTokenExpiry,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (app *application) revokeSessionFamily(ctx context.Context, session *models.Session) {
+	err := app.DB.RevokeSessionFamily(ctx, session.FamilyID)
+	if err != nil {
+		log.Println("revoke session family:", err)
+	}
+}
+
+// normalizeEmail is how emails are stored and looked up, so that logging in
+// doesn't depend on how the address was capitalised at registration.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func validateEmail(email string) error {
+	if len(email) > 254 {
+		return errors.New("email is too long")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return errors.New("invalid email address")
+	}
+
+	return nil
+}
+
+// validatePassword requires at least 8 characters mixing letters and digits.
+// bcrypt ignores anything past 72 bytes, so longer passwords are refused.
+func validatePassword(password string) error {
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+
+	if !hasLetter || !hasDigit {
+		return errors.New("password must contain both letters and numbers")
+	}
+
+	return nil
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"watch-a-movie/internal/models"
+)
+
+func TestHome(t *testing.T) {
+	app := newTestApp(t)
+
+	rr := request(app, "GET", "/", "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+	decode(t, rr, &payload)
+
+	if payload.Status != "active" {
+		t.Errorf("expected status active but got %q", payload.Status)
+	}
+}
+
+type moviePage struct {
+	Items    []*models.Movie `json:"items"`
+	Total    int             `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+	Next     string          `json:"next"`
+	Prev     string          `json:"prev"`
+}
+
+func titles(movies []*models.Movie) string {
+	var s []string
+	for _, m := range movies {
+		s = append(s, m.Title)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestAllMovies(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		titles string
+		total  int
+	}{
+		{"default", "", "Barbie,Heat,The Godfather", 3},
+		{"sort desc", "?sort=-release", "Barbie,Heat,The Godfather", 3},
+		{"sort imdb", "?sort=imdb", "Barbie,Heat,The Godfather", 3},
+		{"sort runtime desc", "?sort=-runtime", "The Godfather,Heat,Barbie", 3},
+		{"genre", "?genre=2", "Heat,The Godfather", 2},
+		{"mpaa", "?mpaa=PG-13,PG", "Barbie", 1},
+		{"years", "?year_from=1990&year_to=2000", "Heat", 1},
+		{"min imdb", "?min_imdb=8.5", "The Godfather", 1},
+		{"runtime", "?runtime_min=120&runtime_max=172", "Heat", 1},
+		{"page 2", "?page=2&page_size=2", "The Godfather", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(app, "GET", "/movies"+tt.query, "", "")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+			}
+
+			var page moviePage
+			decode(t, rr, &page)
+
+			if got := titles(page.Items); got != tt.titles {
+				t.Errorf("expected %s but got %s", tt.titles, got)
+			}
+			if page.Total != tt.total {
+				t.Errorf("expected total %d but got %d", tt.total, page.Total)
+			}
+		})
+	}
+}
+
+func TestAllMoviesLinks(t *testing.T) {
+	app := newTestApp(t)
+
+	rr := request(app, "GET", "/movies?page_size=1&page=2&genre=0", "", "")
+
+	var page moviePage
+	decode(t, rr, &page)
+
+	if page.Next != "/movies?genre=0&page=3&page_size=1" {
+		t.Errorf("unexpected next link %q", page.Next)
+	}
+	if page.Prev != "/movies?genre=0&page=1&page_size=1" {
+		t.Errorf("unexpected prev link %q", page.Prev)
+	}
+
+	// runtime is split and the poster turned into a url
+	m := page.Items[0]
+	if m.Title != "Heat" || m.RuntimeHours != 2 || m.RuntimeMinutes != 50 {
+		t.Errorf("unexpected movie %+v", m)
+	}
+	if m.Poster != "http://localhost:8080/static/images/heat.jpg" {
+		t.Errorf("unexpected poster %q", m.Poster)
+	}
+}
+
+func TestAllMoviesBadQuery(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, query := range []string{"page=0", "page_size=1000", "sort=poster", "genre=abc", "min_imdb=x"} {
+		rr := request(app, "GET", "/movies?"+query, "", "")
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 but got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestSearchMovies(t *testing.T) {
+	app := newTestApp(t)
+
+	rr := request(app, "GET", "/movies/search?q=heat", "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	var payload struct {
+		Results []*models.MovieSearchResult `json:"results"`
+	}
+	decode(t, rr, &payload)
+
+	if len(payload.Results) != 1 || payload.Results[0].Title != "Heat" {
+		t.Fatalf("unexpected results %+v", payload.Results)
+	}
+	if payload.Results[0].TitleHighlight != "<mark>Heat</mark>" {
+		t.Errorf("unexpected highlight %q", payload.Results[0].TitleHighlight)
+	}
+
+	rr = request(app, "GET", "/movies/search", "", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without q but got %d", rr.Code)
+	}
+}
+
+func TestSearchMoviesEscapesHighlights(t *testing.T) {
+	app := newTestApp(t)
+
+	_, err := app.DB.InsertMovie(t.Context(), models.Movie{
+		Title:       `Heat <script>alert("x")</script>`,
+		Description: "Cops & robbers <b>in LA</b>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := request(app, "GET", "/movies/search?q=heat", "", "")
+	var payload struct {
+		Results []*models.MovieSearchResult `json:"results"`
+	}
+	decode(t, rr, &payload)
+
+	var found bool
+	for _, r := range payload.Results {
+		if !strings.Contains(r.Title, "<script>") {
+			continue
+		}
+		found = true
+
+		if r.TitleHighlight != `<mark>Heat</mark> &lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;` {
+			t.Errorf("title not escaped: %q", r.TitleHighlight)
+		}
+		if r.Snippet != `Cops &amp; robbers &lt;b&gt;in LA&lt;/b&gt;` {
+			t.Errorf("snippet not escaped: %q", r.Snippet)
+		}
+	}
+	if !found {
+		t.Fatalf("expected the new movie in %+v", payload.Results)
+	}
+}
+
+func TestGetMovie(t *testing.T) {
+	app := newTestApp(t)
+
+	rr := request(app, "GET", "/movies/1", "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	var movie models.Movie
+	decode(t, rr, &movie)
+
+	if movie.Title != "The Godfather" || len(movie.Genres) != 2 {
+		t.Errorf("unexpected movie %+v", movie)
+	}
+
+	rr = request(app, "GET", "/movies/99", "", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing movie but got %d", rr.Code)
+	}
+}
+
+func TestDisplayMovie(t *testing.T) {
+	app := newTestApp(t)
+
+	rr := request(app, "POST", "/movie", `{"id": 2}`, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	rr = request(app, "POST", "/movie", `{"id": 99}`, "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+}
+
+func TestAllGenres(t *testing.T) {
+	app := newTestApp(t)
+
+	rr := request(app, "GET", "/genres", "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	var genres []*models.GenreCount
+	decode(t, rr, &genres)
+
+	counts := map[string]int{}
+	for _, g := range genres {
+		counts[g.Genre] = g.Movies
+	}
+	if counts["Crime"] != 2 || counts["Comedy"] != 1 || counts["Drama"] != 1 {
+		t.Errorf("unexpected movie counts %v", counts)
+	}
+}
+
+func TestAdminMovieCatalog(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, viewerEmail)
+
+	rr := request(app, "GET", "/admin/movies", "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	rr = request(app, "GET", "/admin/movies/1", "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	var payload struct {
+		Movie  *models.Movie   `json:"movie"`
+		Genres []*models.Genre `json:"genres"`
+	}
+	decode(t, rr, &payload)
+
+	if len(payload.Movie.GenresArray) != 2 || len(payload.Genres) != 4 {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	// only the genre listing counts movies
+	if strings.Contains(rr.Body.String(), "movie_count") {
+		t.Errorf("edit payload carries movie counts: %s", rr.Body)
+	}
+}
+
+func TestAdminInsertUpdateDeleteMovie(t *testing.T) {
+	app := newTestApp(t)
+	editor := accessToken(t, app, editorEmail)
+	admin := accessToken(t, app, adminEmail)
+
+	body := `{"title": "Se7en", "release": 1995, "runtime": 2, "runtime_minutes": 7, "mpaa": "R", "imdb": 8.6,
+		"imdbId": "https://www.imdb.com/title/tt0114369/", "description": "Two detectives hunt a serial killer.",
+		"genres_array": [1, 2]}`
+
+	rr := request(app, "PUT", "/admin/movies/0", body, editor)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("insert: expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = request(app, "GET", "/movies/4", "", "")
+	var movie models.Movie
+	decode(t, rr, &movie)
+	if movie.Title != "Se7en" || movie.IMDbID != "tt0114369" || len(movie.Genres) != 2 {
+		t.Fatalf("unexpected inserted movie %+v", movie)
+	}
+
+	body = `{"title": "Seven", "release": 1995, "runtime": 2, "runtime_minutes": 7, "mpaa": "R", "imdb": 8.6, "genres_array": [2]}`
+	rr = request(app, "PUT", "/admin/movies/4", body, editor)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("update: expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = request(app, "GET", "/movies/4", "", "")
+	movie = models.Movie{}
+	decode(t, rr, &movie)
+	if movie.Title != "Seven" || len(movie.Genres) != 1 {
+		t.Fatalf("unexpected updated movie %+v", movie)
+	}
+
+	rr = request(app, "PUT", "/admin/movies/99", body, editor)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("update missing: expected 404 but got %d", rr.Code)
+	}
+
+	rr = request(app, "DELETE", "/admin/movies/4", "", admin)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("delete: expected 202 but got %d", rr.Code)
+	}
+
+	rr = request(app, "DELETE", "/admin/movies/4", "", admin)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("delete again: expected 404 but got %d", rr.Code)
+	}
+}
+
+func TestAdminEditRoundTrip(t *testing.T) {
+	app := newTestApp(t)
+	editor := accessToken(t, app, editorEmail)
+
+	before := request(app, "GET", "/movies/1", "", "").Body.String()
+
+	// save the edit form exactly as it was loaded
+	rr := request(app, "GET", "/admin/movies/1", "", editor)
+	var payload struct {
+		Movie models.Movie `json:"movie"`
+	}
+	decode(t, rr, &payload)
+
+	body, err := json.Marshal(payload.Movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = request(app, "PUT", "/admin/movies/1", string(body), editor)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	after := request(app, "GET", "/movies/1", "", "").Body.String()
+	if after != before {
+		t.Errorf("round trip changed the movie:\nbefore %s\nafter  %s", before, after)
+	}
+}
+
+func TestAdminInsertMovieUnknownGenres(t *testing.T) {
+	app := newTestApp(t)
+
+	body := `{"title": "Nope", "genres_array": [1, 42, 43]}`
+	rr := request(app, "PUT", "/admin/movies/0", body, accessToken(t, app, adminEmail))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 but got %d", rr.Code)
+	}
+
+	var resp struct {
+		Data struct {
+			UnknownGenreIDs []int `json:"unknown_genre_ids"`
+		} `json:"data"`
+	}
+	decode(t, rr, &resp)
+
+	if len(resp.Data.UnknownGenreIDs) != 2 || resp.Data.UnknownGenreIDs[0] != 42 {
+		t.Errorf("unexpected unknown ids %v", resp.Data.UnknownGenreIDs)
+	}
+
+	// nothing was written
+	rr = request(app, "GET", "/movies", "", "")
+	var page moviePage
+	decode(t, rr, &page)
+	if page.Total != 3 {
+		t.Errorf("expected 3 movies but got %d", page.Total)
+	}
+}
+
+func TestAdminGenres(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, adminEmail)
+
+	rr := request(app, "POST", "/admin/genres", `{"genre": "Thriller"}`, token)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = request(app, "POST", "/admin/genres", `{"genre": "drama"}`, token)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("duplicate: expected 409 but got %d", rr.Code)
+	}
+
+	rr = request(app, "PUT", "/admin/genres/5", `{"genre": "Suspense"}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("rename: expected 202 but got %d", rr.Code)
+	}
+
+	// fold Crime into Drama; The Godfather has both and must not be doubled
+	rr = request(app, "POST", "/admin/genres/2/merge", `{"into": 1}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("merge: expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = request(app, "GET", "/genres", "", "")
+	var genres []*models.GenreCount
+	decode(t, rr, &genres)
+
+	counts := map[string]int{}
+	for _, g := range genres {
+		counts[g.Genre] = g.Movies
+	}
+	if _, ok := counts["Crime"]; ok || counts["Drama"] != 2 || counts["Suspense"] != 0 {
+		t.Errorf("unexpected genres after merge %v", counts)
+	}
+
+	rr = request(app, "DELETE", "/admin/genres/1", "", token)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("delete: expected 202 but got %d", rr.Code)
+	}
+
+	rr = request(app, "DELETE", "/admin/genres/1", "", token)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("delete again: expected 404 but got %d", rr.Code)
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, adminEmail)
+
+	rr := request(app, "GET", "/admin/users", "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "password") {
+		t.Errorf("user list leaks passwords: %s", rr.Body)
+	}
+
+	rr = request(app, "PUT", "/admin/users/4/roles", `{"roles": ["editor"]}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d", rr.Code)
+	}
+
+	user, _ := app.DB.GetUserByID(t.Context(), 4)
+	if len(user.Roles) != 1 || user.Roles[0] != models.RoleEditor {
+		t.Errorf("unexpected roles %v", user.Roles)
+	}
+
+	rr = request(app, "PUT", "/admin/users/4/roles", `{"roles": ["superuser"]}`, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown role: expected 400 but got %d", rr.Code)
+	}
+
+	rr = request(app, "PUT", "/admin/users/99/roles", `{"roles": []}`, token)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown user: expected 404 but got %d", rr.Code)
+	}
+
+	// the admin is user 1 and can't lock themselves out
+	rr = request(app, "PUT", "/admin/users/1/roles", `{"roles": ["editor"]}`, token)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("self demotion: expected 409 but got %d", rr.Code)
+	}
+
+	rr = request(app, "PUT", "/admin/users/1/roles", `{"roles": ["admin", "editor"]}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("keeping admin: expected 202 but got %d", rr.Code)
+	}
+}
+
+func TestStaticFiles(t *testing.T) {
+	app := newTestApp(t)
+
+	// the file server is relative to the repository root
+	t.Chdir("../..")
+
+	rr := request(app, "GET", "/static/images/heat.jpg", "", "")
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 but got %d", rr.Code)
+	}
+
+	rr = request(app, "GET", "/static/images/missing.jpg", "", "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+}
+
+func TestExtractIMDbIdFromLink(t *testing.T) {
+	tests := map[string]string{
+		"tt0068646":                               "tt0068646",
+		" tt0068646 ":                             "tt0068646",
+		"https://www.imdb.com/title/tt0068646/":   "tt0068646",
+		"https://m.imdb.com/title/tt0113277/?ref": "tt0113277",
+		"not a link":                              "",
+	}
+
+	for in, want := range tests {
+		if got := extractIMDbIdFromLink(in); got != want {
+			t.Errorf("extractIMDbIdFromLink(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
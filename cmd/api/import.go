@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+// maxImportBytes caps the body of a bulk import.
+const maxImportBytes = 10 << 20
+
+// importColumns are the fields a bulk import understands, as CSV headers and
+// JSON Lines keys. They are the same as moviesctl movies export writes.
+//
+//	title        required
+//	release      year
+//	runtime      total minutes
+//	mpaa         rating, e.g. PG-13
+//	imdb         IMDb rating, 0 to 10
+//	imdb_id      IMDb id or a link to the IMDb page
+//	description
+//	poster       poster file name
+//	genres       genre names; separated by | in CSV, an array in JSON
+var importColumns = []string{"title", "release", "runtime", "mpaa", "imdb", "imdb_id", "description", "poster", "genres"}
+
+// importRow is one movie of a bulk import.
+type importRow struct {
+	Line   int
+	Movie  models.Movie
+	Genres []string
+
+	// errs are the problems found reading or validating the row
+	errs []string
+}
+
+type importRecord struct {
+	Title       string   `json:"title"`
+	Release     int      `json:"release"`
+	Runtime     int      `json:"runtime"`
+	MPAA        string   `json:"mpaa"`
+	IMDb        float32  `json:"imdb"`
+	IMDbID      string   `json:"imdb_id"`
+	Description string   `json:"description"`
+	Poster      string   `json:"poster"`
+	Genres      []string `json:"genres"`
+}
+
+// importReport is the outcome of a bulk import. In a dry run, Created,
+// Updated and GenresCreated say what the import would have done.
+type importReport struct {
+	DryRun        bool          `json:"dry_run"`
+	Rows          int           `json:"rows"`
+	Created       int           `json:"created"`
+	Updated       int           `json:"updated"`
+	GenresCreated []string      `json:"genres_created"`
+	Errors        []importError `json:"errors"`
+}
+
+type importError struct {
+	Line   int      `json:"line"`
+	Title  string   `json:"title,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// ImportMovies adds movies in bulk from CSV or JSON Lines. The format comes
+// from the format query parameter (csv or jsonl) or else the Content-Type.
+//
+// Movies with an imdb_id that is already in the catalog are updated in place,
+// replacing every field; their genres are only replaced if the row names any.
+// Genres are matched by name, ignoring case, and unknown ones are an error
+// unless create_genres=true.
+//
+// With dry_run=true nothing is written and the report lists every row that
+// would fail. Otherwise the import is all or nothing: if any row fails, the
+// report is returned with a 422 and the catalog is left alone.
+func (app *application) ImportMovies(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	dryRun, err := boolParam(qs.Get("dry_run"), "dry_run")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	createGenres, err := boolParam(qs.Get("create_genres"), "create_genres")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	format := qs.Get("format")
+	if format == "" {
+		format = importFormat(r.Header.Get("Content-Type"))
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var rows []*importRow
+	switch format {
+	case "csv":
+		rows, err = readCSVImport(body)
+	case "jsonl":
+		rows, err = readJSONLImport(body)
+	default:
+		app.errorJSON(w, errors.New("send CSV (text/csv) or JSON Lines (application/x-ndjson), or set format to csv or jsonl"), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	if len(rows) == 0 {
+		app.errorJSON(w, errors.New("no movies to import"))
+		return
+	}
+
+	validateImport(rows)
+
+	report := importReport{DryRun: dryRun, Rows: len(rows), GenresCreated: []string{}, Errors: []importError{}}
+
+	run := func(repo repository.DatabaseRepo) error {
+		return runImport(r.Context(), repo, rows, createGenres, dryRun, &report)
+	}
+
+	// a dry run only reads, so it needs no transaction
+	if dryRun {
+		err = run(app.DB)
+	} else {
+		err = app.DB.WithTx(r.Context(), run)
+	}
+	if errors.Is(err, errImportInvalid) {
+		app.writeJSON(w, http.StatusUnprocessableEntity, JSONResponse{
+			Error:   true,
+			Message: fmt.Sprintf("%d of %d rows have errors; nothing was imported", len(report.Errors), len(rows)),
+			Data:    report,
+		})
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if dryRun {
+		app.writeJSON(w, http.StatusOK, JSONResponse{
+			Message: fmt.Sprintf("dry run: %d of %d rows have errors", len(report.Errors), len(rows)),
+			Data:    report,
+		})
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, JSONResponse{
+		Message: fmt.Sprintf("imported %d movies", report.Created+report.Updated),
+		Data:    report,
+	})
+}
+
+// errImportInvalid rolls back an import that has rows with errors.
+var errImportInvalid = errors.New("import has invalid rows")
+
+// runImport resolves genres and upserts each row into repo, filling in
+// report. In a dry run nothing is written.
+func runImport(ctx context.Context, repo repository.DatabaseRepo, rows []*importRow, createGenres, dryRun bool, report *importReport) error {
+	genres, err := repo.AllGenres(ctx)
+	if err != nil {
+		return err
+	}
+
+	genreIDs := make(map[string]int, len(genres))
+	for _, g := range genres {
+		genreIDs[strings.ToLower(g.Genre)] = g.ID
+	}
+
+	// genres to create, so that each is only reported and created once
+	newGenres := make(map[string]bool)
+	for _, row := range rows {
+		for _, name := range row.Genres {
+			key := strings.ToLower(name)
+			if _, ok := genreIDs[key]; ok || newGenres[key] {
+				continue
+			}
+			if !createGenres {
+				row.errs = append(row.errs, fmt.Sprintf("unknown genre %q", name))
+				continue
+			}
+			newGenres[key] = true
+			report.GenresCreated = append(report.GenresCreated, name)
+		}
+	}
+
+	// which rows update an existing movie
+	existing := make(map[*importRow]int)
+	for _, row := range rows {
+		if row.Movie.IMDbID == "" {
+			continue
+		}
+
+		id, err := repo.MovieIDByIMDbID(ctx, row.Movie.IMDbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		existing[row] = id
+	}
+
+	for _, row := range rows {
+		if len(row.errs) > 0 {
+			report.Errors = append(report.Errors, importError{Line: row.Line, Title: row.Movie.Title, Errors: row.errs})
+			continue
+		}
+		if _, ok := existing[row]; ok {
+			report.Updated++
+		} else {
+			report.Created++
+		}
+	}
+
+	if len(report.Errors) > 0 && !dryRun {
+		return errImportInvalid
+	}
+	if dryRun {
+		return nil
+	}
+
+	now := time.Now()
+	for _, name := range report.GenresCreated {
+		id, err := repo.InsertGenre(ctx, models.Genre{Genre: name, CreatedAt: now, UpdatedAt: now})
+		if err != nil {
+			return fmt.Errorf("creating genre %q: %w", name, err)
+		}
+		genreIDs[strings.ToLower(name)] = id
+	}
+
+	for _, row := range rows {
+		var ids []int
+		for _, name := range row.Genres {
+			ids = append(ids, genreIDs[strings.ToLower(name)])
+		}
+
+		movie := row.Movie
+		movie.UpdatedAt = now
+
+		if id, ok := existing[row]; ok {
+			movie.ID = id
+			err := repo.UpdateMovie(ctx, movie)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+			if len(ids) == 0 {
+				continue
+			}
+		} else {
+			movie.CreatedAt = now
+			movie.ID, err = repo.InsertMovie(ctx, movie)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+		}
+
+		err := repo.UpdateMovieGenres(ctx, movie.ID, ids)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", row.Line, err)
+		}
+	}
+
+	return nil
+}
+
+// validateImport checks each row on its own, and that no two rows share an
+// IMDb id.
+func validateImport(rows []*importRow) {
+	maxYear := time.Now().Year() + 10
+	seen := make(map[string]int)
+
+	for _, row := range rows {
+		m := &row.Movie
+
+		m.Title = strings.TrimSpace(m.Title)
+		if m.Title == "" {
+			row.errs = append(row.errs, "title is required")
+		}
+		if m.Release != 0 && (m.Release < 1888 || m.Release > maxYear) {
+			row.errs = append(row.errs, fmt.Sprintf("release must be a year between 1888 and %d", maxYear))
+		}
+		if m.RuntimeMinutes < 0 {
+			row.errs = append(row.errs, "runtime can't be negative")
+		}
+		if m.IMDb < 0 || m.IMDb > 10 {
+			row.errs = append(row.errs, "imdb must be between 0 and 10")
+		}
+
+		if m.IMDbID != "" {
+			link := m.IMDbID
+			m.IMDbID = extractIMDbIdFromLink(link)
+
+			if m.IMDbID == "" {
+				row.errs = append(row.errs, fmt.Sprintf("invalid IMDb ID %q", link))
+			} else if line, ok := seen[m.IMDbID]; ok {
+				row.errs = append(row.errs, fmt.Sprintf("imdb_id %s is also on line %d", m.IMDbID, line))
+			} else {
+				seen[m.IMDbID] = row.Line
+			}
+		}
+
+		var genres []string
+		named := make(map[string]bool)
+		for _, g := range row.Genres {
+			g = strings.TrimSpace(g)
+			if g != "" && !named[strings.ToLower(g)] {
+				genres = append(genres, g)
+				named[strings.ToLower(g)] = true
+			}
+		}
+		row.Genres = genres
+	}
+}
+
+func readCSVImport(r io.Reader) ([]*importRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(importColumns))
+	for _, c := range importColumns {
+		known[c] = true
+	}
+
+	columns := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !known[h] {
+			return nil, fmt.Errorf("unknown column %q; columns are %s", h, strings.Join(importColumns, ", "))
+		}
+		if _, ok := columns[h]; ok {
+			return nil, fmt.Errorf("column %q appears twice", h)
+		}
+		columns[h] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("a title column is required")
+	}
+
+	var rows []*importRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := &importRow{Line: line}
+		rows = append(rows, row)
+
+		if errors.Is(err, csv.ErrFieldCount) {
+			row.errs = append(row.errs, fmt.Sprintf("expected %d fields but got %d", len(header), len(record)))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		number := func(name string, bits int) float64 {
+			v := field(name)
+			if v == "" {
+				return 0
+			}
+			n, err := strconv.ParseFloat(v, bits)
+			if err != nil {
+				row.errs = append(row.errs, fmt.Sprintf("%s must be a number", name))
+			}
+			return n
+		}
+
+		row.Movie = models.Movie{
+			Title:       field("title"),
+			MPAA:        field("mpaa"),
+			IMDbID:      field("imdb_id"),
+			Description: field("description"),
+			Poster:      field("poster"),
+		}
+
+		release := number("release", 64)
+		runtime := number("runtime", 64)
+		if release != float64(int(release)) || runtime != float64(int(runtime)) {
+			row.errs = append(row.errs, "release and runtime must be whole numbers")
+		}
+		row.Movie.Release = int(release)
+		row.Movie.RuntimeMinutes = int(runtime)
+		row.Movie.IMDb = float32(number("imdb", 32))
+
+		if v := field("genres"); v != "" {
+			row.Genres = strings.Split(v, "|")
+		}
+	}
+
+	return rows, nil
+}
+
+func readJSONLImport(r io.Reader) ([]*importRow, error) {
+	scanner := newLineScanner(r)
+
+	var rows []*importRow
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := &importRow{Line: line}
+		rows = append(rows, row)
+
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+
+		var record importRecord
+		err := dec.Decode(&record)
+		if err != nil {
+			row.errs = append(row.errs, err.Error())
+			continue
+		}
+
+		row.Movie = models.Movie{
+			Title:          record.Title,
+			Release:        record.Release,
+			RuntimeMinutes: record.Runtime,
+			MPAA:           strings.TrimSpace(record.MPAA),
+			IMDb:           record.IMDb,
+			IMDbID:         strings.TrimSpace(record.IMDbID),
+			Description:    strings.TrimSpace(record.Description),
+			Poster:         strings.TrimSpace(record.Poster),
+		}
+		row.Genres = record.Genres
+	}
+
+	return rows, scanner.Err()
+}
+
+// importFormat maps a Content-Type to an import format.
+func importFormat(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return "jsonl"
+	}
+	return ""
+}
+
+// boolParam parses an optional boolean query parameter.
+func boolParam(v, name string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
+
+// newLineScanner reads r a line at a time, allowing lines of up to 1 MB.
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return scanner
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// importRequest posts body to the import endpoint as contentType.
+func importRequest(app *application, query, contentType, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/admin/movies/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	return rr
+}
+
+type importResponse struct {
+	Error   bool         `json:"error"`
+	Message string       `json:"message"`
+	Data    importReport `json:"data"`
+}
+
+func TestImportMoviesCSV(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	body := `title,release,runtime,mpaa,imdb,imdb_id,genres
+Heat (Director's Cut),1995,171,R,8.4,https://www.imdb.com/title/tt0113277/,Crime|Thriller
+Alien,1979,117,R,8.5,tt0078748,Sci-Fi|thriller
+`
+
+	rr := importRequest(app, "?create_genres=true", "text/csv", body, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp importResponse
+	decode(t, rr, &resp)
+	if resp.Data.Created != 1 || resp.Data.Updated != 1 {
+		t.Errorf("expected 1 created and 1 updated but got %+v", resp.Data)
+	}
+	if strings.Join(resp.Data.GenresCreated, ",") != "Thriller,Sci-Fi" {
+		t.Errorf("expected Thriller and Sci-Fi to be created once each but got %v", resp.Data.GenresCreated)
+	}
+
+	// Heat was matched on its IMDb id, so it was updated rather than duplicated
+	heat, err := app.DB.OneMovie(t.Context(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if heat.Title != "Heat (Director's Cut)" || heat.Runtime() != 171 || len(heat.Genres) != 2 {
+		t.Errorf("unexpected update %+v", heat)
+	}
+
+	movies, _ := app.DB.AllMovies(t.Context())
+	if len(movies) != 4 {
+		t.Errorf("expected 4 movies but got %d", len(movies))
+	}
+
+	id, err := app.DB.MovieIDByIMDbID(t.Context(), "tt0078748")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alien, _ := app.DB.OneMovie(t.Context(), id)
+	if alien.Runtime() != 117 || len(alien.Genres) != 2 {
+		t.Errorf("unexpected insert %+v", alien)
+	}
+}
+
+func TestImportMoviesJSONLines(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	body := `{"title": "Alien", "release": 1979, "runtime": 117, "imdb_id": "tt0078748", "genres": ["drama"]}
+
+{"title": "Aliens", "release": 1986, "runtime": 137, "genres": ["Action", "Drama"]}
+`
+
+	rr := importRequest(app, "", "application/x-ndjson", body, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp importResponse
+	decode(t, rr, &resp)
+	if resp.Data.Created != 2 || len(resp.Data.GenresCreated) != 0 {
+		t.Errorf("unexpected report %+v", resp.Data)
+	}
+}
+
+func TestImportMoviesDryRun(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	body := `title,release,imdb,imdb_id,genres
+Alien,1979,8.5,tt0078748,Horror
+,1986,,,
+Aliens,1986,eleven,not-a-link,Action
+Alien 3,1992,6.4,tt0078748,
+`
+
+	rr := importRequest(app, "?dry_run=true", "text/csv", body, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp importResponse
+	decode(t, rr, &resp)
+
+	errs := make(map[int]string)
+	for _, e := range resp.Data.Errors {
+		errs[e.Line] = strings.Join(e.Errors, "; ")
+	}
+
+	expected := map[int]string{
+		2: `unknown genre "Horror"`,
+		3: "title is required",
+		4: "imdb must be a number",
+		5: "also on line 2",
+	}
+	for line, want := range expected {
+		if !strings.Contains(errs[line], want) {
+			t.Errorf("line %d: expected %q in %q", line, want, errs[line])
+		}
+	}
+	if !strings.Contains(errs[4], "invalid IMDb ID") {
+		t.Errorf("line 4: expected the bad link to be reported but got %q", errs[4])
+	}
+
+	movies, _ := app.DB.AllMovies(t.Context())
+	if len(movies) != 3 {
+		t.Errorf("expected a dry run to write nothing but there are %d movies", len(movies))
+	}
+}
+
+func TestImportMoviesAllOrNothing(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	body := `{"title": "Alien", "release": 1979, "genres": ["Horror"]}
+{"title": "Aliens", "release": 1986, "rating": "R"}
+`
+
+	rr := importRequest(app, "?format=jsonl&create_genres=true", "text/plain", body, token)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp importResponse
+	decode(t, rr, &resp)
+	if len(resp.Data.Errors) != 1 || resp.Data.Errors[0].Line != 2 {
+		t.Errorf("expected an unknown field error on line 2 but got %+v", resp.Data.Errors)
+	}
+
+	movies, _ := app.DB.AllMovies(t.Context())
+	genres, _ := app.DB.AllGenres(t.Context())
+	if len(movies) != 3 || len(genres) != 4 {
+		t.Errorf("expected nothing to be written but got %d movies and %d genres", len(movies), len(genres))
+	}
+}
+
+func TestImportMoviesBadRequests(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"unknown format", "", "application/json", `[]`, http.StatusUnsupportedMediaType},
+		{"unknown column", "", "text/csv", "title,director\nAlien,Scott\n", http.StatusBadRequest},
+		{"no title column", "", "text/csv", "release\n1979\n", http.StatusBadRequest},
+		{"empty", "", "text/csv", "title\n", http.StatusBadRequest},
+		{"bad flag", "?dry_run=maybe", "text/csv", "title\nAlien\n", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := importRequest(app, tt.query, tt.contentType, tt.body, token)
+			if rr.Code != tt.status {
+				t.Errorf("expected %d but got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	// viewers can't write to the catalog
+	rr := importRequest(app, "", "text/csv", "title\nAlien\n", accessToken(t, app, viewerEmail))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("viewer: expected 403 but got %d", rr.Code)
+	}
+}
@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
+	"watch-a-movie/internal/enrichment"
+	"watch-a-movie/internal/moderation"
+	"watch-a-movie/internal/password"
+	"watch-a-movie/internal/posters"
+	"watch-a-movie/internal/recommend"
 	"watch-a-movie/internal/repository"
-	"watch-a-movie/internal/repository/dbrepo"
+	"watch-a-movie/internal/storage"
 )
 
 const port = 8080
@@ -16,24 +28,69 @@ const port = 8080
 type application struct {
 	Domain       string
 	DSN          string
+	DBDriver     string
 	DB           repository.DatabaseRepo
 	auth         Auth
 	JWTSecret    string
 	JWTIssuer    string
 	JWTAudience  string
 	CookieDomain string
+	Hasher       string
+	DBTimeout    time.Duration
+	Migrate      bool
+	AdminEmail   string
+	Seed         bool
+	hasher       password.Hasher
+	dummyHash    string
+
+	// posters are uploaded to posters and served from PosterBaseURL; their
+	// resized variants are cached in PosterCacheDir and served by the api
+	// from PosterVariantsURL
+	PosterStorage     string
+	PosterDir         string
+	PosterBaseURL     string
+	PosterCacheDir    string
+	PosterVariantsURL string
+	posters           storage.PosterStore
+	variants          *posters.Cache
+	s3                storage.S3Store
+
+	// metadata fills in movies by IMDb ID; nil unless an api key is set
+	MetadataURL    string
+	MetadataAPIKey string
+	EnrichOnInsert bool
+	metadata       enrichment.MetadataProvider
+
+	// comments and reviews matching moderationFilter, loaded from
+	// ModerationFilter, are flagged for moderation
+	ModerationFilter string
+	moderationFilter *moderation.Filter
+
+	// recommendations are worked out from a snapshot of the catalog and the
+	// ratings, cached until either changes
+	Recommender     string
+	recommender     recommend.Recommender
+	recommendations recommendationCache
+
+	// similar movies are ranked with similarWeights, parsed from
+	// SimilarWeights, and cached until the catalog changes
+	SimilarWeights string
+	similarWeights recommend.Weights
+	similar        similarCache
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// set application config
 	var app application
 
-	// Get DSN from environment variable, fallback to local default
-	dsnFromEnv := os.Getenv("DATABASE_URL")
-	if dsnFromEnv == "" {
-		dsnFromEnv = "host=localhost port=5432 user=snehil password=hello dbname=movies sslmode=disable timezone=UTC connect_timeout=5"
-	}
-
 	// Get JWT secret from environment variable
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
@@ -47,22 +104,101 @@ func main() {
 	}
 
 	// read from the command line (with environment variable defaults)
-	flag.StringVar(&app.DSN, "dsn", dsnFromEnv, "Postgres connection string")
+	flag.StringVar(&app.DBDriver, "db-driver", "postgres", "database backend (postgres or sqlite)")
+	flag.StringVar(&app.DSN, "dsn", defaultDSN(), "Postgres connection string, or the database file for sqlite")
 	flag.StringVar(&app.JWTSecret, "jwt-secret", jwtSecret, "signing secret for JWT")
 	flag.StringVar(&app.JWTIssuer, "jwt-issuer", "example.com", "signing issuer for JWT")
 	flag.StringVar(&app.JWTAudience, "jwt-audience", "example.com", "signing audience for JWT")
 	flag.StringVar(&app.CookieDomain, "cookie-domain", "localhost", "cookie domain for JWT")
 	flag.StringVar(&app.Domain, "domain", "example.com", "domain for JWT")
+	flag.DurationVar(&app.DBTimeout, "db-timeout", 3*time.Second, "timeout for each database query")
+	flag.StringVar(&app.Hasher, "password-hasher", "argon2id", "password hashing algorithm (argon2id or bcrypt)")
+	flag.StringVar(&app.AdminEmail, "admin-email", os.Getenv("ADMIN_EMAIL"), "make this user an admin on startup if there is no admin yet")
+	flag.BoolVar(&app.Seed, "seed", false, "fill an empty catalog with demo data and a demo admin login (development only)")
+	flag.BoolVar(&app.Migrate, "migrate", false, "apply pending schema migrations on startup (postgres only)")
+	flag.StringVar(&app.PosterStorage, "poster-storage", "local", "where uploaded posters are kept (local or s3)")
+	flag.StringVar(&app.PosterDir, "poster-dir", "static/images", "directory for posters with -poster-storage local")
+	flag.StringVar(&app.PosterBaseURL, "poster-base-url", os.Getenv("POSTER_BASE_URL"), "url posters are served from; defaults to this api's static files, or the bucket with s3")
+	flag.StringVar(&app.PosterCacheDir, "poster-cache-dir", filepath.Join(os.TempDir(), "watch-a-movie-posters"), "directory resized posters are cached in")
+	flag.StringVar(&app.PosterVariantsURL, "poster-variants-url", os.Getenv("POSTER_VARIANTS_URL"), "url this api serves resized posters from; defaults to its /posters route on localhost")
+	flag.StringVar(&app.s3.Endpoint, "s3-endpoint", os.Getenv("S3_ENDPOINT"), "S3-compatible endpoint, e.g. http://localhost:9000")
+	flag.StringVar(&app.s3.Bucket, "s3-bucket", os.Getenv("S3_BUCKET"), "bucket for posters")
+	flag.StringVar(&app.s3.Region, "s3-region", "us-east-1", "bucket region")
+	flag.StringVar(&app.s3.AccessKey, "s3-access-key", os.Getenv("S3_ACCESS_KEY"), "S3 access key")
+	flag.StringVar(&app.s3.SecretKey, "s3-secret-key", os.Getenv("S3_SECRET_KEY"), "S3 secret key")
+	flag.StringVar(&app.MetadataURL, "metadata-url", enrichment.DefaultOMDbURL, "OMDb-compatible api movies are enriched from")
+	flag.StringVar(&app.MetadataAPIKey, "metadata-api-key", os.Getenv("OMDB_API_KEY"), "api key for -metadata-url; enrichment is off without one")
+	flag.BoolVar(&app.EnrichOnInsert, "enrich-on-insert", false, "fill in new movies with an IMDb ID from the metadata provider, unless ?enrich=false")
+	flag.StringVar(&app.ModerationFilter, "moderation-filter", os.Getenv("MODERATION_FILTER"), "file of words and /regexps/, one per line, that flag comments and reviews for moderation")
+	flag.StringVar(&app.Recommender, "recommender", recommend.Names[0], "how /me/recommendations picks movies: "+strings.Join(recommend.Names, ", "))
+	flag.StringVar(&app.SimilarWeights, "similar-weights", recommend.DefaultWeights.String(), "how much genres, year, mpaa and rating count in similar movies")
 	flag.Parse()
 
+	// the Postgres default makes no sense as a file name
+	if app.DBDriver == "sqlite" && !isFlagSet("dsn") && os.Getenv("DATABASE_URL") == "" {
+		app.DSN = "movies.db"
+	}
+
+	// set up password hashing
+	hasher, err := password.New(app.Hasher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	app.hasher = hasher
+	app.dummyHash, err = app.hasher.Hash("not-a-real-password")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// posters first: the repository needs their base url
+	err = app.setupPosters()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if app.MetadataAPIKey != "" {
+		app.metadata = enrichment.OMDbClient{BaseURL: app.MetadataURL, APIKey: app.MetadataAPIKey, Client: metadataClient}
+	}
+
+	app.recommender, err = recommend.New(app.Recommender)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app.similarWeights, err = recommend.ParseWeights(app.SimilarWeights)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if app.ModerationFilter != "" {
+		app.moderationFilter, err = moderation.LoadFilter(app.ModerationFilter)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("flagging comments and reviews that match any of %d rules", app.moderationFilter.Len())
+	}
+
 	// connect to db
-	conn, err := app.connectToDB()
+	app.DB, err = app.connectToDB()
 	if err != nil {
 		log.Fatal(err)
 	}
-	app.DB = &dbrepo.PostgresDBRepo{DB: conn}
 	defer app.DB.Connection().Close()
 
+	if app.Seed {
+		err = app.seedDemo()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if app.AdminEmail != "" {
+		err = app.bootstrapAdmin(app.AdminEmail)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	app.auth = Auth{
 		Issuer:        app.JWTIssuer,
 		Audience:      app.JWTAudience,
@@ -75,7 +211,7 @@ func main() {
 	}
 
 	log.Println("Starting API on port 8080...")
-	log.Println(`
+	log.Print(`
   ______    ______       ______    ______   __
  /\  ___\  /\  __ \     /\  __ \  /\  == \ /\ \
  \ \ \__\\ \ \ \/\ \    \ \ \_\ \ \ \  __/ \ \ \
@@ -84,9 +220,49 @@ func main() {
 
 `)
 
+	// cancelled on SIGINT/SIGTERM; every request context derives from it, so
+	// queries still in flight are abandoned when the server goes down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:        fmt.Sprintf(":%d", port),
+		Handler:     app.routes(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down...")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
 	// start a web server
-	err = http.ListenAndServe(fmt.Sprintf(":%d", port), app.routes())
-	if err != nil {
+	err = srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
 }
+
+// defaultDSN reads the DSN from the environment, falling back to a local
+// default.
+func defaultDSN() string {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = "host=localhost port=5432 user=snehil password=hello dbname=movies sslmode=disable timezone=UTC connect_timeout=5"
+	}
+	return dsn
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
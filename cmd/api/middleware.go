@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"os"
+	"watch-a-movie/internal/models"
 )
 
 func (app *application) enableCORS(h http.Handler) http.Handler {
@@ -24,13 +27,71 @@ func (app *application) enableCORS(h http.Handler) http.Handler {
 	})
 }
 
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
 func (app *application) authRequired(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_, _, err := app.auth.GetTokenFromHeaderAndVerify(w, r)
+		_, claims, err := app.auth.GetTokenFromHeaderAndVerify(w, r)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		h.ServeHTTP(w, r)
+
+		// make the verified claims available to the rest of the chain
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// requireRole only lets through requests whose token carries one of roles.
+// It must be mounted after authRequired.
+func (app *application) requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := claimsFromContext(r.Context())
+			if claims == nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			for _, have := range claims.Roles {
+				for _, want := range roles {
+					if have == want {
+						h.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			app.errorJSON(w, errors.New("forbidden"), http.StatusForbidden)
+		})
+	}
+}
+
+// requirePermission only lets through requests whose roles grant permission.
+// It must be mounted after authRequired.
+func (app *application) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := claimsFromContext(r.Context())
+			if claims == nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if !models.HasPermission(claims.Roles, permission) {
+				app.errorJSON(w, errors.New("forbidden"), http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func claimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsContextKey).(*Claims)
+	return claims
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminRoutesRequireAuth(t *testing.T) {
+	app := newTestApp(t)
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/admin/movies"},
+		{"GET", "/admin/movies/1"},
+		{"PUT", "/admin/movies/0"},
+		{"PUT", "/admin/movies/1"},
+		{"DELETE", "/admin/movies/1"},
+		{"POST", "/admin/genres"},
+		{"PUT", "/admin/genres/1"},
+		{"DELETE", "/admin/genres/1"},
+		{"POST", "/admin/genres/1/merge"},
+		{"GET", "/admin/users"},
+		{"PUT", "/admin/users/1/roles"},
+	}
+
+	// a token signed with the wrong key
+	forged := Auth{Issuer: app.auth.Issuer, Secret: "wrong", TokenExpiry: time.Minute}
+	forgedTokens, _ := forged.GenerateTokenPair(&jwtUser{ID: 1, Roles: []string{"admin"}})
+
+	// a refresh token is not an access token
+	refresh, _ := app.auth.GenerateTokenPair(&jwtUser{ID: 1, Roles: []string{"admin"}})
+
+	for _, route := range routes {
+		for name, token := range map[string]string{
+			"no token":      "",
+			"forged token":  forgedTokens.Token,
+			"refresh token": refresh.RefreshToken,
+		} {
+			rr := request(app, route.method, route.path, "", token)
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with %s: expected 401 but got %d", route.method, route.path, name, rr.Code)
+			}
+		}
+	}
+}
+
+func TestAdminRoutesRequireRole(t *testing.T) {
+	app := newTestApp(t)
+
+	tokens := map[string]string{
+		"user":   accessToken(t, app, userEmail),
+		"viewer": accessToken(t, app, viewerEmail),
+		"editor": accessToken(t, app, editorEmail),
+		"admin":  accessToken(t, app, adminEmail),
+	}
+
+	routes := []struct {
+		method  string
+		path    string
+		allowed []string
+	}{
+		{"GET", "/admin/movies", []string{"viewer", "editor", "admin"}},
+		{"GET", "/admin/movies/1", []string{"viewer", "editor", "admin"}},
+		{"PUT", "/admin/movies/0", []string{"editor", "admin"}},
+		{"PUT", "/admin/movies/1", []string{"editor", "admin"}},
+		{"DELETE", "/admin/movies/1", []string{"admin"}},
+		{"POST", "/admin/genres", []string{"editor", "admin"}},
+		{"PUT", "/admin/genres/1", []string{"editor", "admin"}},
+		{"DELETE", "/admin/genres/1", []string{"admin"}},
+		{"POST", "/admin/genres/1/merge", []string{"admin"}},
+		{"GET", "/admin/users", []string{"admin"}},
+		{"PUT", "/admin/users/1/roles", []string{"admin"}},
+	}
+
+	for _, route := range routes {
+		for role, token := range tokens {
+			allowed := false
+			for _, a := range route.allowed {
+				allowed = allowed || a == role
+			}
+
+			// no body, so allowed requests fail validation rather than succeed;
+			// all that matters here is whether they got past the middleware
+			rr := request(app, route.method, route.path, "", token)
+			if allowed && (rr.Code == http.StatusForbidden || rr.Code == http.StatusUnauthorized) {
+				t.Errorf("%s %s as %s: expected access but got %d", route.method, route.path, role, rr.Code)
+			}
+			if !allowed && rr.Code != http.StatusForbidden {
+				t.Errorf("%s %s as %s: expected 403 but got %d", route.method, route.path, role, rr.Code)
+			}
+		}
+	}
+}
+
+func TestEnableCORS(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv("ALLOWED_ORIGIN", "https://movies.example.com")
+
+	rr := request(app, "OPTIONS", "/admin/movies", "", "")
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected preflight to succeed without a token but got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://movies.example.com" {
+		t.Errorf("unexpected allowed origin %q", got)
+	}
+	if rr.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Error("expected allowed methods on preflight")
+	}
+
+	rr = request(app, "GET", "/", "", "")
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("expected credentials to be allowed")
+	}
+}
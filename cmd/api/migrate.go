@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"watch-a-movie/internal/migrations"
+	"watch-a-movie/internal/repository/dbrepo"
+)
+
+const migrateUsage = `usage: api migrate [-dsn dsn] <command>
+
+commands:
+  up             apply all pending migrations
+  down [n]       roll back the last n migrations (default 1)
+  status         list migrations and when they were applied
+  create <name>  add an empty migration to -dir
+
+Databases that predate migrations need no special step: up adopts the
+existing tables and adds what is missing.
+`
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dsn := fs.String("dsn", defaultDSN(), "Postgres connection string")
+	dir := fs.String("dir", migrations.Dir, "directory that create writes new migrations to")
+	fs.Usage = func() { fmt.Fprint(fs.Output(), migrateUsage) }
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	command, rest := fs.Arg(0), fs.Args()[1:]
+	switch command {
+	case "up", "down", "status", "create":
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// create only touches the source tree
+	if command == "create" {
+		if len(rest) != 1 {
+			return errors.New("usage: api migrate create <name>")
+		}
+
+		paths, err := migrations.Create(*dir, rest[0])
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return err
+	}
+
+	repo, err := dbrepo.NewPostgresDBRepo(*dsn, 0)
+	if err != nil {
+		return err
+	}
+	defer repo.DB.Close()
+
+	migrator, err := migrations.New(repo.DB)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			steps, err = strconv.Atoi(rest[0])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", rest[0])
+			}
+		}
+
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
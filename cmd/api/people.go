@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetPerson returns a person with their filmography, the latest movies first.
+func (app *application) GetPerson(w http.ResponseWriter, r *http.Request) {
+	personID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	person, err := app.DB.GetPerson(r.Context(), personID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("person not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	person.Filmography, err = app.DB.PersonCredits(r.Context(), personID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, person)
+}
+
+// addCast sets the cast of movie to its TopBilledCast actors.
+func (app *application) addCast(ctx context.Context, movie *models.Movie) error {
+	credits, err := app.DB.MovieCredits(ctx, movie.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range credits {
+		if c.Role == models.CreditActor && len(movie.Cast) < models.TopBilledCast {
+			movie.Cast = append(movie.Cast, c)
+		}
+	}
+	return nil
+}
+
+// SearchPeople finds people by name for the admin pages. The q query
+// parameter is matched anywhere in the name and limit caps how many,
+// DefaultPageSize by default.
+func (app *application) SearchPeople(w http.ResponseWriter, r *http.Request) {
+	limit := models.DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			app.errorJSON(w, fmt.Errorf("limit must be between 1 and %d", models.MaxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	people, err := app.DB.SearchPeople(r.Context(), strings.TrimSpace(r.URL.Query().Get("q")), limit)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, people)
+}
+
+func (app *application) InsertPerson(w http.ResponseWriter, r *http.Request) {
+	person, ok := app.readPerson(w, r)
+	if !ok {
+		return
+	}
+
+	person.CreatedAt = person.UpdatedAt
+
+	var err error
+	person.ID, err = app.DB.InsertPerson(r.Context(), person)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "person created",
+		Data:    person,
+	}
+
+	app.writeJSON(w, http.StatusCreated, resp)
+}
+
+func (app *application) UpdatePerson(w http.ResponseWriter, r *http.Request) {
+	personID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	person, ok := app.readPerson(w, r)
+	if !ok {
+		return
+	}
+	person.ID = personID
+
+	err = app.DB.UpdatePerson(r.Context(), person)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("person not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "person updated",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// DeletePerson deletes a person with all their credits.
+func (app *application) DeletePerson(w http.ResponseWriter, r *http.Request) {
+	personID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.DeletePerson(r.Context(), personID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("person not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "person deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// readPerson reads and checks the name, birth year and bio of a person.
+func (app *application) readPerson(w http.ResponseWriter, r *http.Request) (models.Person, bool) {
+	var person models.Person
+
+	var payload struct {
+		Name      string `json:"name"`
+		BirthYear int    `json:"birth_year"`
+		Bio       string `json:"bio"`
+	}
+
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return person, false
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		app.errorJSON(w, errors.New("name is required"))
+		return person, false
+	}
+	if payload.BirthYear < 0 || payload.BirthYear > time.Now().Year() {
+		app.errorJSON(w, errors.New("birth_year is not a valid year"))
+		return person, false
+	}
+
+	person = models.Person{
+		Name:      name,
+		BirthYear: payload.BirthYear,
+		Bio:       strings.TrimSpace(payload.Bio),
+		UpdatedAt: time.Now(),
+	}
+	return person, true
+}
+
+// MovieCredits returns all of a movie's credits, directors first and actors
+// last, by billing.
+func (app *application) MovieCredits(w http.ResponseWriter, r *http.Request) {
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	_, err = app.DB.GetMovieByID(r.Context(), movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	credits, err := app.DB.MovieCredits(r.Context(), movieID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, credits)
+}
+
+// InsertCredit credits the person in the body on the movie in the url.
+func (app *application) InsertCredit(w http.ResponseWriter, r *http.Request) {
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var payload struct {
+		PersonID  int    `json:"person_id"`
+		Role      string `json:"role"`
+		Character string `json:"character"`
+		Billing   int    `json:"billing"`
+	}
+
+	err = app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	credit := models.Credit{
+		MovieID:   movieID,
+		PersonID:  payload.PersonID,
+		Role:      payload.Role,
+		Character: strings.TrimSpace(payload.Character),
+		Billing:   payload.Billing,
+	}
+	err = checkCredit(credit)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	_, err = app.DB.GetMovieByID(r.Context(), movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = app.DB.GetPerson(r.Context(), credit.PersonID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("person not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	credit.ID, err = app.DB.InsertCredit(r.Context(), credit)
+	if errors.Is(err, repository.ErrDuplicate) {
+		app.errorJSON(w, errors.New("the person already has this credit"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeCredit(w, r, credit.ID, http.StatusCreated, "credit added")
+}
+
+// UpdateCredit changes the role, character and billing of a credit; the movie
+// and person stay.
+func (app *application) UpdateCredit(w http.ResponseWriter, r *http.Request) {
+	creditID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var payload struct {
+		Role      string `json:"role"`
+		Character string `json:"character"`
+		Billing   int    `json:"billing"`
+	}
+
+	err = app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	credit := models.Credit{
+		ID:        creditID,
+		Role:      payload.Role,
+		Character: strings.TrimSpace(payload.Character),
+		Billing:   payload.Billing,
+	}
+	err = checkCredit(credit)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.UpdateCredit(r.Context(), credit)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("credit not found"), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrDuplicate) {
+		app.errorJSON(w, errors.New("the person already has this credit"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeCredit(w, r, creditID, http.StatusAccepted, "credit updated")
+}
+
+func (app *application) DeleteCredit(w http.ResponseWriter, r *http.Request) {
+	creditID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.DeleteCredit(r.Context(), creditID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("credit not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "credit deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// checkCredit checks the role, character and billing of credit. Only actors
+// have a character and billing.
+func checkCredit(credit models.Credit) error {
+	if !models.ValidCreditRole(credit.Role) {
+		return fmt.Errorf("role must be one of %s", strings.Join(models.CreditRoles, ", "))
+	}
+	if credit.Billing < 0 {
+		return errors.New("billing can't be negative")
+	}
+	if credit.Role != models.CreditActor && (credit.Character != "" || credit.Billing != 0) {
+		return errors.New("only actors have a character and billing")
+	}
+	return nil
+}
+
+// writeCredit responds with the credit as stored, with the movie and person
+// names filled in.
+func (app *application) writeCredit(w http.ResponseWriter, r *http.Request, creditID, status int, message string) {
+	credit, err := app.DB.GetCredit(r.Context(), creditID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: message,
+		Data:    credit,
+	}
+
+	app.writeJSON(w, status, resp)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"watch-a-movie/internal/models"
+)
+
+func TestPeople(t *testing.T) {
+	app := newTestApp(t)
+	editor := accessToken(t, app, editorEmail)
+
+	rr := request(app, "POST", "/admin/people", `{"name": " Al Pacino ", "birth_year": 1940}`, editor)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 but got %d: %s", rr.Code, rr.Body)
+	}
+	var created struct {
+		Data models.Person `json:"data"`
+	}
+	decode(t, rr, &created)
+	if created.Data.ID == 0 || created.Data.Name != "Al Pacino" {
+		t.Fatalf("unexpected person %+v", created.Data)
+	}
+	pacino := created.Data.ID
+
+	// six actors, so that one is left out of the cast
+	for billing := 1; billing <= 6; billing++ {
+		body := fmt.Sprintf(`{"person_id": %d, "role": "actor", "character": "Part %d", "billing": %d}`, pacino, billing, billing)
+		rr = request(app, "POST", "/admin/movies/1/credits", body, editor)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected 201 but got %d: %s", rr.Code, rr.Body)
+		}
+	}
+	rr = request(app, "POST", "/admin/movies/2/credits", fmt.Sprintf(`{"person_id": %d, "role": "actor", "character": "Vincent Hanna"}`, pacino), editor)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 but got %d: %s", rr.Code, rr.Body)
+	}
+	var credit struct {
+		Data models.Credit `json:"data"`
+	}
+	decode(t, rr, &credit)
+	if credit.Data.MovieTitle != "Heat" || credit.Data.Name != "Al Pacino" {
+		t.Errorf("unexpected credit %+v", credit.Data)
+	}
+
+	rr = request(app, "GET", "/movies/1", "", "")
+	var movie models.Movie
+	decode(t, rr, &movie)
+	if len(movie.Cast) != models.TopBilledCast || movie.Cast[0].Character != "Part 1" || movie.Cast[0].Name != "Al Pacino" {
+		t.Errorf("expected the top billed cast but got %+v", movie.Cast)
+	}
+
+	rr = request(app, "GET", fmt.Sprintf("/people/%d", pacino), "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+	var person models.Person
+	decode(t, rr, &person)
+	if person.BirthYear != 1940 || len(person.Filmography) != 7 || person.Filmography[0].MovieTitle != "Heat" {
+		t.Errorf("unexpected person %+v", person)
+	}
+
+	rr = request(app, "PUT", fmt.Sprintf("/admin/credits/%d", credit.Data.ID), `{"role": "actor", "character": "Lt. Vincent Hanna", "billing": 1}`, editor)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	rr = request(app, "GET", "/admin/movies/2/credits", "", editor)
+	var credits []models.Credit
+	decode(t, rr, &credits)
+	if len(credits) != 1 || credits[0].Character != "Lt. Vincent Hanna" || credits[0].Billing != 1 {
+		t.Errorf("unexpected credits %+v", credits)
+	}
+
+	rr = request(app, "GET", "/admin/people?q=PACINO", "", editor)
+	var people []models.Person
+	decode(t, rr, &people)
+	if len(people) != 1 || people[0].ID != pacino {
+		t.Errorf("unexpected people %+v", people)
+	}
+
+	// editors may change credits, only admins delete people
+	rr = request(app, "DELETE", fmt.Sprintf("/admin/credits/%d", credit.Data.ID), "", editor)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	rr = request(app, "DELETE", fmt.Sprintf("/admin/people/%d", pacino), "", editor)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 but got %d", rr.Code)
+	}
+	rr = request(app, "DELETE", fmt.Sprintf("/admin/people/%d", pacino), "", accessToken(t, app, adminEmail))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = request(app, "GET", fmt.Sprintf("/people/%d", pacino), "", "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+	rr = request(app, "GET", "/movies/1", "", "")
+	movie = models.Movie{}
+	decode(t, rr, &movie)
+	if len(movie.Cast) != 0 {
+		t.Errorf("expected no cast but got %+v", movie.Cast)
+	}
+}
+
+func TestCreditsReject(t *testing.T) {
+	app := newTestApp(t)
+	editor := accessToken(t, app, editorEmail)
+
+	rr := request(app, "POST", "/admin/people", `{"name": "Michael Mann"}`, editor)
+	var created struct {
+		Data models.Person `json:"data"`
+	}
+	decode(t, rr, &created)
+	mann := created.Data.ID
+
+	rr = request(app, "POST", "/admin/movies/2/credits", fmt.Sprintf(`{"person_id": %d, "role": "director"}`, mann), editor)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	for _, tc := range []struct {
+		name, method, target, body string
+		status                     int
+	}{
+		{"no name", "POST", "/admin/people", `{"name": " "}`, http.StatusBadRequest},
+		{"future birth", "POST", "/admin/people", `{"name": "Nobody", "birth_year": 3000}`, http.StatusBadRequest},
+		{"unknown person", "PUT", "/admin/people/99", `{"name": "Nobody"}`, http.StatusNotFound},
+		{"bad role", "POST", "/admin/movies/2/credits", fmt.Sprintf(`{"person_id": %d, "role": "grip"}`, mann), http.StatusBadRequest},
+		{"director character", "POST", "/admin/movies/2/credits", fmt.Sprintf(`{"person_id": %d, "role": "director", "character": "Himself"}`, mann), http.StatusBadRequest},
+		{"negative billing", "POST", "/admin/movies/2/credits", fmt.Sprintf(`{"person_id": %d, "role": "actor", "billing": -1}`, mann), http.StatusBadRequest},
+		{"duplicate", "POST", "/admin/movies/2/credits", fmt.Sprintf(`{"person_id": %d, "role": "director"}`, mann), http.StatusConflict},
+		{"unknown movie", "POST", "/admin/movies/99/credits", fmt.Sprintf(`{"person_id": %d, "role": "writer"}`, mann), http.StatusNotFound},
+		{"unknown credit person", "POST", "/admin/movies/2/credits", `{"person_id": 99, "role": "writer"}`, http.StatusNotFound},
+		{"unknown credit", "PUT", "/admin/credits/99", `{"role": "writer"}`, http.StatusNotFound},
+		{"viewer", "POST", "/admin/people", `{"name": "Nobody"}`, http.StatusForbidden},
+	} {
+		token := editor
+		if tc.name == "viewer" {
+			token = accessToken(t, app, viewerEmail)
+		}
+		rr := request(app, tc.method, tc.target, tc.body, token)
+		if rr.Code != tc.status {
+			t.Errorf("%s: expected %d but got %d: %s", tc.name, tc.status, rr.Code, rr.Body)
+		}
+	}
+}
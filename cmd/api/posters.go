@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/posters"
+	"watch-a-movie/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// maxPosterBytes caps the size of an uploaded poster.
+	maxPosterBytes = 5 << 20
+
+	// posters must be at least this big to look right on a movie page, and
+	// at most this big so that decoding them stays cheap
+	minPosterWidth  = 200
+	minPosterHeight = 300
+	maxPosterSide   = 6000
+)
+
+// posterTypes are the image types accepted as posters, by the type sniffed
+// from their first bytes, with the extension they are stored under.
+var posterTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// uploadedPoster matches the names given to uploaded posters, as opposed to
+// the files shipped in static/images, which must never be deleted.
+var uploadedPoster = regexp.MustCompile(`^\d+-[0-9a-f]{16}\.(jpg|png|webp)$`)
+
+// setupPosters builds the poster store named by app.PosterStorage and the
+// cache of its variants, and works out the base urls posters and variants are
+// served from when none were configured.
+func (app *application) setupPosters() error {
+	switch app.PosterStorage {
+	case "local":
+		app.posters = storage.LocalStore{Dir: app.PosterDir}
+		if app.PosterBaseURL == "" {
+			app.PosterBaseURL = fmt.Sprintf("http://localhost:%d/static/images/", port)
+		}
+
+	case "s3":
+		if app.s3.Endpoint == "" || app.s3.Bucket == "" {
+			return errors.New("-poster-storage s3 needs -s3-endpoint and -s3-bucket")
+		}
+		app.posters = app.s3
+		if app.PosterBaseURL == "" {
+			app.PosterBaseURL = app.s3.URL("")
+		}
+
+	default:
+		return fmt.Errorf("unknown poster storage %q", app.PosterStorage)
+	}
+
+	if !strings.HasSuffix(app.PosterBaseURL, "/") {
+		app.PosterBaseURL += "/"
+	}
+
+	app.variants = &posters.Cache{Dir: app.PosterCacheDir, Store: app.posters}
+	if app.PosterVariantsURL == "" {
+		app.PosterVariantsURL = fmt.Sprintf("http://localhost:%d/posters/", port)
+	}
+	if !strings.HasSuffix(app.PosterVariantsURL, "/") {
+		app.PosterVariantsURL += "/"
+	}
+
+	return nil
+}
+
+// UploadPoster replaces a movie's poster with the image in the poster field of
+// a multipart form. The image type is decided by its content, not its name or
+// the type the client claims, and the image must be a JPEG, PNG or WebP of
+// reasonable dimensions.
+func (app *application) UploadPoster(w http.ResponseWriter, r *http.Request) {
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	movie, err := app.DB.GetMovieByID(r.Context(), movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// leave room for the multipart headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxPosterBytes+64<<10)
+
+	data, err := readPosterPart(r)
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) || errors.Is(err, errPosterTooBig) {
+		app.errorJSON(w, fmt.Errorf("posters can be at most %d MB", maxPosterBytes>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	config, contentType, err := checkPoster(data)
+	var badPoster *posterError
+	if errors.As(err, &badPoster) {
+		app.errorJSON(w, err, badPoster.status)
+		return
+	}
+
+	name, err := app.savePoster(r.Context(), movie, data, contentType)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "poster uploaded",
+		Data: map[string]interface{}{
+			"poster":          app.PosterBaseURL + name,
+			"poster_variants": models.NewPosterVariants(app.PosterVariantsURL, name),
+			"width":           config.Width,
+			"height":          config.Height,
+			"type":            contentType,
+		},
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// PosterVariant serves a resized copy of a poster, named as in the
+// poster_variants of a movie, making the variants of the poster the first
+// time any of them is asked for.
+func (app *application) PosterVariant(w http.ResponseWriter, r *http.Request) {
+	file, err := app.variants.Variant(r.Context(), chi.URLParam(r, "size"), chi.URLParam(r, "file"))
+	if errors.Is(err, posters.ErrNotFound) {
+		app.errorJSON(w, errors.New("poster not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFile(w, r, file)
+}
+
+// posterError is an image that can't be used as a poster, with the status to
+// answer an upload of it with.
+type posterError struct {
+	status int
+	err    error
+}
+
+func (e *posterError) Error() string {
+	return e.err.Error()
+}
+
+// checkPoster makes sure data is a JPEG, PNG or WebP image of reasonable
+// dimensions, going by its content rather than any name or type it came with,
+// and returns its dimensions and type. Errors are *posterError.
+func checkPoster(data []byte) (image.Config, string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := posterTypes[contentType]; !ok {
+		return image.Config{}, "", &posterError{http.StatusUnsupportedMediaType,
+			fmt.Errorf("posters must be JPEG, PNG or WebP images, not %s", contentType)}
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || "image/"+format != contentType {
+		return image.Config{}, "", &posterError{http.StatusUnsupportedMediaType,
+			errors.New("the poster is not a valid image")}
+	}
+
+	if config.Width < minPosterWidth || config.Height < minPosterHeight {
+		return config, "", &posterError{http.StatusUnprocessableEntity,
+			fmt.Errorf("posters must be at least %dx%d pixels, this one is %dx%d",
+				minPosterWidth, minPosterHeight, config.Width, config.Height)}
+	}
+	if config.Width > maxPosterSide || config.Height > maxPosterSide {
+		return config, "", &posterError{http.StatusUnprocessableEntity,
+			fmt.Errorf("posters can be at most %d pixels on each side, this one is %dx%d",
+				maxPosterSide, config.Width, config.Height)}
+	}
+
+	return config, contentType, nil
+}
+
+// savePoster stores data, a poster that passed checkPoster, as the poster of
+// movie and returns its file name. The poster it replaces is deleted if it
+// was uploaded.
+func (app *application) savePoster(ctx context.Context, movie *models.Movie, data []byte, contentType string) (string, error) {
+	// the name changes with the content, so caches never serve a stale poster
+	ext := posterTypes[contentType]
+	sum := sha256.Sum256(data)
+	name := fmt.Sprintf("%d-%x%s", movie.ID, sum[:8], ext)
+
+	err := app.posters.Put(ctx, name, data, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	// variants are made now so the first visitor doesn't wait for them;
+	// should this fail they are made on first request instead
+	err = app.variants.Generate(strings.TrimSuffix(name, ext), data)
+	if err != nil {
+		log.Printf("making variants of poster %s: %s", name, err)
+	}
+
+	err = app.DB.UpdateMoviePoster(ctx, movie.ID, name)
+	if err != nil {
+		return "", err
+	}
+
+	// the previous poster is removed once nothing points at it anymore
+	old := movie.Poster[strings.LastIndex(movie.Poster, "/")+1:]
+	if old != name && uploadedPoster.MatchString(old) {
+		err = app.posters.Delete(ctx, old)
+		if err != nil {
+			log.Printf("deleting poster %s: %s", old, err)
+		}
+		err = app.variants.Remove(strings.TrimSuffix(old, path.Ext(old)))
+		if err != nil {
+			log.Printf("deleting variants of poster %s: %s", old, err)
+		}
+	}
+
+	return name, nil
+}
+
+var errPosterTooBig = errors.New("poster too big")
+
+// readPosterPart returns the contents of the poster field of a multipart
+// request, reading no more than maxPosterBytes of it.
+func readPosterPart(r *http.Request) ([]byte, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("send the poster as multipart/form-data in a field named poster")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no poster field in the form")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() != "poster" {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxPosterBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxPosterBytes {
+			return nil, errPosterTooBig
+		}
+		if len(data) == 0 {
+			return nil, errors.New("the poster is empty")
+		}
+
+		return data, nil
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"watch-a-movie/internal/models"
+
+	_ "golang.org/x/image/webp"
+)
+
+// testImage encodes a w×h image with encode.
+func testImage(t *testing.T, w, h int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, x%h, color.RGBA{R: 200, A: 255})
+	}
+
+	var buf bytes.Buffer
+	err := encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func pngImage(t *testing.T, w, h int) []byte {
+	return testImage(t, w, h, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+}
+
+// uploadPoster posts data as the poster field of a multipart form.
+func uploadPoster(t *testing.T, app *application, movieID, token string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	// the claimed name and type are ignored
+	part, err := mw.CreateFormFile("poster", "poster.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/admin/movies/"+movieID+"/poster", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestUploadPoster(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	rr := uploadPoster(t, app, "2", token, pngImage(t, 300, 450))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			Poster string `json:"poster"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+			Type   string `json:"type"`
+		} `json:"data"`
+	}
+	decode(t, rr, &resp)
+
+	name := strings.TrimPrefix(resp.Data.Poster, app.PosterBaseURL)
+	if !uploadedPoster.MatchString(name) || !strings.HasPrefix(name, "2-") || !strings.HasSuffix(name, ".png") {
+		t.Errorf("unexpected poster url %s", resp.Data.Poster)
+	}
+	if resp.Data.Width != 300 || resp.Data.Height != 450 || resp.Data.Type != "image/png" {
+		t.Errorf("unexpected response %+v", resp.Data)
+	}
+
+	if _, err := os.Stat(filepath.Join(app.PosterDir, name)); err != nil {
+		t.Errorf("expected the poster to be stored: %s", err)
+	}
+
+	movie, _ := app.DB.GetMovieByID(t.Context(), 2)
+	if movie.Poster != resp.Data.Poster {
+		t.Errorf("expected the movie to point at %s but got %s", resp.Data.Poster, movie.Poster)
+	}
+
+	// the variants were made with the upload
+	stem := strings.TrimSuffix(name, ".png")
+	if movie.PosterVariants == nil || movie.PosterVariants.Card.WebP != app.PosterVariantsURL+"card/"+stem+".webp" {
+		t.Errorf("unexpected variants %+v", movie.PosterVariants)
+	}
+	if _, err := os.Stat(filepath.Join(app.PosterCacheDir, "thumbnail", stem+".jpg")); err != nil {
+		t.Errorf("expected the variants to be cached: %s", err)
+	}
+
+	// a JPEG replaces it, and the uploaded PNG is cleaned up
+	jpg := testImage(t, 200, 300, func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) })
+	rr = uploadPoster(t, app, "2", token, jpg)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	decode(t, rr, &resp)
+	if !strings.HasSuffix(resp.Data.Poster, ".jpg") {
+		t.Errorf("expected a .jpg poster but got %s", resp.Data.Poster)
+	}
+	if _, err := os.Stat(filepath.Join(app.PosterDir, name)); !os.IsNotExist(err) {
+		t.Errorf("expected the old poster to be deleted but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.PosterCacheDir, "thumbnail", stem+".jpg")); !os.IsNotExist(err) {
+		t.Errorf("expected the old variants to be deleted but got %v", err)
+	}
+}
+
+func TestPosterVariant(t *testing.T) {
+	app := newTestApp(t)
+
+	// heat.jpg came with the catalog, so its variants are made on request
+	jpg := testImage(t, 400, 600, func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) })
+	os.WriteFile(filepath.Join(app.PosterDir, "heat.jpg"), jpg, 0o644)
+
+	rr := request(app, "GET", "/movies/2", "", "")
+	var movie models.Movie
+	decode(t, rr, &movie)
+
+	variants := movie.PosterVariants
+	if variants == nil || variants.Thumbnail.Width != 160 || variants.Full.JPEG != "http://localhost:8080/posters/full/heat.jpg" {
+		t.Fatalf("unexpected variants %+v", variants)
+	}
+
+	for _, tt := range []struct {
+		url, contentType string
+		width            int
+	}{
+		{variants.Card.WebP, "image/webp", 342},
+		{variants.Card.JPEG, "image/jpeg", 342},
+		{variants.Thumbnail.JPEG, "image/jpeg", 160},
+	} {
+		rr := request(app, "GET", strings.TrimPrefix(tt.url, "http://localhost:8080"), "", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 but got %d: %s", tt.url, rr.Code, rr.Body)
+		}
+		if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: expected %s but got %s", tt.url, tt.contentType, got)
+		}
+		config, _, err := image.DecodeConfig(rr.Body)
+		if err != nil || config.Width != tt.width {
+			t.Errorf("%s: expected width %d but got %d, %v", tt.url, tt.width, config.Width, err)
+		}
+	}
+
+	for _, url := range []string{"/posters/card/missing.jpg", "/posters/huge/heat.jpg", "/posters/card/heat.gif"} {
+		rr := request(app, "GET", url, "", "")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404 but got %d", url, rr.Code)
+		}
+	}
+}
+
+func TestUploadPosterKeepsShippedPosters(t *testing.T) {
+	app := newTestApp(t)
+
+	// heat.jpg came with the catalog rather than from an upload
+	shipped := filepath.Join(app.PosterDir, "heat.jpg")
+	os.WriteFile(shipped, []byte("jpeg"), 0o644)
+
+	rr := uploadPoster(t, app, "2", accessToken(t, app, editorEmail), pngImage(t, 300, 450))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	if _, err := os.Stat(shipped); err != nil {
+		t.Errorf("expected heat.jpg to be kept: %s", err)
+	}
+}
+
+func TestUploadPosterRejects(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, editorEmail)
+
+	gifData := testImage(t, 300, 450, func(b *bytes.Buffer, img image.Image) error { return gif.Encode(b, img, nil) })
+
+	// a PNG signature followed by garbage
+	fakePNG := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte("x"), 100)...)
+
+	tests := []struct {
+		name   string
+		movie  string
+		data   []byte
+		status int
+	}{
+		{"text", "2", []byte("<html>not an image</html>"), http.StatusUnsupportedMediaType},
+		{"gif", "2", gifData, http.StatusUnsupportedMediaType},
+		{"corrupt png", "2", fakePNG, http.StatusUnsupportedMediaType},
+		{"too small", "2", pngImage(t, 100, 150), http.StatusUnprocessableEntity},
+		{"too large", "2", bytes.Repeat([]byte{0}, maxPosterBytes+1), http.StatusRequestEntityTooLarge},
+		{"unknown movie", "99", pngImage(t, 300, 450), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := uploadPoster(t, app, tt.movie, token, tt.data)
+			if rr.Code != tt.status {
+				t.Errorf("expected %d but got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	// nothing was stored
+	entries, _ := os.ReadDir(app.PosterDir)
+	if len(entries) != 0 {
+		t.Errorf("expected no files but got %d", len(entries))
+	}
+
+	// viewers can't change the catalog
+	rr := uploadPoster(t, app, "2", accessToken(t, app, viewerEmail), pngImage(t, 300, 450))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("viewer: expected 403 but got %d", rr.Code)
+	}
+
+	// not multipart at all
+	rr = request(app, "POST", "/admin/movies/2/poster", `{"poster": "x"}`, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("json body: expected 400 but got %d", rr.Code)
+	}
+}
+
+func TestPosterBaseURL(t *testing.T) {
+	app := newTestApp(t)
+	app.PosterStorage = "s3"
+	app.PosterBaseURL = ""
+	app.s3.Endpoint = "http://minio.local:9000"
+	app.s3.Bucket = "posters"
+
+	err := app.setupPosters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.PosterBaseURL != "http://minio.local:9000/posters/" {
+		t.Errorf("expected the bucket url but got %s", app.PosterBaseURL)
+	}
+
+	app.PosterBaseURL = "https://cdn.example.com/posters"
+	err = app.setupPosters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.PosterBaseURL != "https://cdn.example.com/posters/" {
+		t.Errorf("expected the configured url but got %s", app.PosterBaseURL)
+	}
+
+	app.s3.Bucket = ""
+	if app.setupPosters() == nil {
+		t.Error("expected s3 without a bucket to be rejected")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/recommend"
+)
+
+// recommendation is a movie recommended to the logged in user, because of
+// the movies they liked that it is most like.
+type recommendation struct {
+	Movie   *models.Movie `json:"movie"`
+	Score   float64       `json:"score"`
+	Because []movieRef    `json:"because"`
+}
+
+type movieRef struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// recommendationSnapshot is what recommendations are worked out from, for
+// every user: the catalog by id, and recommend.Data with everybody's ratings
+// and no watchlists. It is shared between requests and must not be changed.
+type recommendationSnapshot struct {
+	data   *recommend.Data
+	movies map[int]*models.Movie
+}
+
+// recommendationCache keeps the snapshot until the catalog or the ratings
+// change, with generations as in similarCache.
+type recommendationCache struct {
+	mu         sync.Mutex
+	snapshot   *recommendationSnapshot
+	generation uint64
+}
+
+func (c *recommendationCache) get() (*recommendationSnapshot, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.snapshot, c.generation
+}
+
+func (c *recommendationCache) put(snapshot *recommendationSnapshot, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation == c.generation {
+		c.snapshot = snapshot
+	}
+}
+
+func (c *recommendationCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.snapshot = nil
+	c.generation++
+}
+
+// ratingChanges empties the recommendation cache after every request that
+// may have changed a rating.
+func (app *application) ratingChanges(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.recommendations.clear()
+		}
+	})
+}
+
+// MyRecommendations recommends movies to the logged in user that they haven't
+// rated or put on their watchlist, from the ones they rated highly or did put
+// there. The limit query parameter caps how many, DefaultPageSize by default.
+func (app *application) MyRecommendations(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	limit := models.DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			app.errorJSON(w, fmt.Errorf("limit must be between 1 and %d", models.MaxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	snapshot, err := app.recommendationSnapshot(r.Context())
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	watchlist, err := app.DB.WatchlistMovieIDs(r.Context(), userID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	data := snapshot.data.WithWatchlists(map[int][]int{userID: sortedIDs(watchlist)})
+
+	byID := snapshot.movies
+	items := []recommendation{}
+	for _, rec := range app.recommender.Recommend(data, userID, limit) {
+		movie, ok := byID[rec.MovieID]
+		if !ok {
+			// added since the genres were read
+			continue
+		}
+
+		item := recommendation{Movie: movie, Score: rec.Score, Because: []movieRef{}}
+		for _, id := range rec.Because {
+			if m, ok := byID[id]; ok {
+				item.Because = append(item.Because, movieRef{ID: id, Title: m.Title})
+			}
+		}
+		items = append(items, item)
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, items)
+}
+
+// recommendationSnapshot returns the cached snapshot, reading the catalog,
+// its genres and everybody's ratings if there is none.
+func (app *application) recommendationSnapshot(ctx context.Context) (*recommendationSnapshot, error) {
+	snapshot, generation := app.recommendations.get()
+	if snapshot != nil {
+		return snapshot, nil
+	}
+
+	movies, err := app.DB.AllMovies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*models.Movie, len(movies))
+	for _, m := range movies {
+		byID[m.ID] = m
+	}
+
+	genres, err := app.DB.MovieGenreIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings, err := app.DB.AllRatings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &recommend.Data{Genres: genres, Ratings: ratings}
+	snapshot = &recommendationSnapshot{data: data.Cached(), movies: byID}
+	app.recommendations.put(snapshot, generation)
+	return snapshot, nil
+}
+
+// sortedIDs returns the ids in ids, in order.
+func sortedIDs(ids map[int]bool) []int {
+	sorted := make([]int, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	slices.Sort(sorted)
+	return sorted
+}
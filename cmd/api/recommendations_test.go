@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+	"watch-a-movie/internal/models"
+)
+
+func recommendationsFor(t *testing.T, app *application, token string) []recommendation {
+	t.Helper()
+
+	rr := request(app, "GET", "/me/recommendations", "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+	var items []recommendation
+	decode(t, rr, &items)
+	return items
+}
+
+func TestRecommendations(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, userEmail)
+
+	recommendations := func() []recommendation {
+		t.Helper()
+		return recommendationsFor(t, app, token)
+	}
+
+	if items := recommendations(); len(items) != 0 {
+		t.Errorf("expected nothing to go on but got %+v", items)
+	}
+
+	// another crime movie for liking The Godfather; nothing for Barbie
+	request(app, "POST", "/movies/1/reviews", `{"rating": 9}`, token)
+	items := recommendations()
+	if len(items) != 1 || items[0].Movie.Title != "Heat" || items[0].Score <= 0 {
+		t.Fatalf("expected Heat but got %+v", items)
+	}
+	if len(items[0].Because) != 1 || items[0].Because[0].Title != "The Godfather" {
+		t.Errorf("expected Heat because of The Godfather but got %+v", items[0].Because)
+	}
+
+	// which is already on the list
+	request(app, "POST", "/me/watchlist", `{"movie_id": 2}`, token)
+	if items := recommendations(); len(items) != 0 {
+		t.Errorf("expected nothing new but got %+v", items)
+	}
+
+	rr := request(app, "GET", "/me/recommendations?limit=0", "", token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 but got %d", rr.Code)
+	}
+	rr = request(app, "GET", "/me/recommendations", "", "")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: expected 401 but got %d", rr.Code)
+	}
+}
+
+func TestRecommendationsCached(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, userEmail)
+
+	if items := recommendationsFor(t, app, token); len(items) != 0 {
+		t.Fatalf("expected nothing to go on but got %+v", items)
+	}
+
+	// cached until a rating changes through the api
+	now := time.Now()
+	_, err := app.DB.InsertReview(t.Context(), models.Review{
+		MovieID:   1,
+		UserID:    4,
+		Rating:    9,
+		Status:    models.CommentApproved,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := recommendationsFor(t, app, token); len(items) != 0 {
+		t.Errorf("expected the cached ratings but got %+v", items)
+	}
+
+	rr := request(app, "POST", "/movies/3/reviews", `{"rating": 9}`, token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	items := recommendationsFor(t, app, token)
+	if len(items) != 1 || items[0].Movie.Title != "Heat" {
+		t.Fatalf("expected Heat but got %+v", items)
+	}
+
+	// and until the catalog changes
+	rr = request(app, "DELETE", "/admin/movies/2", "", accessToken(t, app, adminEmail))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	if items := recommendationsFor(t, app, token); len(items) != 0 {
+		t.Errorf("expected Heat to be gone but got %+v", items)
+	}
+}
+
+func TestRecommendationCacheSkipsStalePuts(t *testing.T) {
+	var c recommendationCache
+
+	snapshot, generation := c.get()
+	if snapshot != nil {
+		t.Fatal("expected an empty cache")
+	}
+
+	// a rating changes while the snapshot is read
+	c.clear()
+	c.put(&recommendationSnapshot{}, generation)
+	if snapshot, _ := c.get(); snapshot != nil {
+		t.Error("expected the stale snapshot not to be cached")
+	}
+
+	_, generation = c.get()
+	c.put(&recommendationSnapshot{}, generation)
+	if snapshot, _ := c.get(); snapshot == nil {
+		t.Error("expected the snapshot to be cached")
+	}
+}
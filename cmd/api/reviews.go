@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxReviewBody caps the length of a written review, in characters.
+const maxReviewBody = 5000
+
+// MovieReviews returns one page of a movie's approved reviews, newest first,
+// with its community rating. It takes the page and page_size query
+// parameters.
+func (app *application) MovieReviews(w http.ResponseWriter, r *http.Request) {
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	page, pageSize, err := readPage(r.URL.Query())
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	movie, err := app.DB.GetMovieByID(r.Context(), movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	filter := models.ReviewFilter{MovieID: movieID, Statuses: []string{models.CommentApproved}, Page: page, PageSize: pageSize}
+	reviews, total, err := app.DB.ListReviews(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = app.addCommunityRatings(r.Context(), movie)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var payload = struct {
+		Items     []*models.Review        `json:"items"`
+		Total     int                     `json:"total"`
+		Page      int                     `json:"page"`
+		PageSize  int                     `json:"page_size"`
+		Next      string                  `json:"next,omitempty"`
+		Prev      string                  `json:"prev,omitempty"`
+		Community *models.CommunityRating `json:"community"`
+	}{
+		Items:     reviews,
+		Total:     total,
+		Page:      page,
+		PageSize:  pageSize,
+		Community: movie.Community,
+	}
+
+	if (page-1)*pageSize+len(reviews) < total {
+		payload.Next = pageLink(r.URL, page+1)
+	}
+	if page > 1 {
+		payload.Prev = pageLink(r.URL, page-1)
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// InsertReview rates a movie for the logged in user, with an optional written
+// review. A user reviews a movie only once; after that the review is changed
+// with UpdateReview. Reviews matching the pre-filter are flagged and wait for
+// a moderator.
+func (app *application) InsertReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := app.DB.GetMovieByID(r.Context(), review.MovieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	review.CreatedAt = review.UpdatedAt
+	review.Status = models.CommentApproved
+	app.screenReview(&review)
+
+	_, err = app.DB.InsertReview(r.Context(), review)
+	if errors.Is(err, repository.ErrDuplicate) {
+		app.errorJSON(w, errors.New("you already reviewed this movie"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeReview(w, r, review, "review added")
+}
+
+// UpdateReview replaces the rating and body of the logged in user's review.
+// The new body is screened like a new review's; one that passes leaves the
+// review in the state it was in.
+func (app *application) UpdateReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := app.DB.GetReview(r.Context(), review.MovieID, review.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("you haven't reviewed this movie"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	review.Status, review.FlagReason = current.Status, current.FlagReason
+	app.screenReview(&review)
+
+	err = app.DB.UpdateReview(r.Context(), review)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("you haven't reviewed this movie"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	app.writeReview(w, r, review, "review updated")
+}
+
+// DeleteReview deletes the logged in user's review of a movie.
+func (app *application) DeleteReview(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.DeleteReview(r.Context(), movieID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("you haven't reviewed this movie"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "review deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// readReview reads the rating and body sent for the movie in the URL into a
+// review by the logged in user. It writes the error response and returns
+// false if the request is no good.
+func (app *application) readReview(w http.ResponseWriter, r *http.Request) (models.Review, bool) {
+	var review models.Review
+
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return review, false
+	}
+
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return review, false
+	}
+
+	var payload struct {
+		Rating int    `json:"rating"`
+		Body   string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return review, false
+	}
+
+	if payload.Rating < models.MinRating || payload.Rating > models.MaxRating {
+		app.errorJSON(w, fmt.Errorf("rating must be between %d and %d", models.MinRating, models.MaxRating))
+		return review, false
+	}
+	body := strings.TrimSpace(payload.Body)
+	if utf8.RuneCountInString(body) > maxReviewBody {
+		app.errorJSON(w, fmt.Errorf("body can be at most %d characters", maxReviewBody))
+		return review, false
+	}
+
+	review = models.Review{
+		MovieID:   movieID,
+		UserID:    userID,
+		Rating:    payload.Rating,
+		Body:      body,
+		UpdatedAt: time.Now(),
+	}
+	return review, true
+}
+
+// screenReview flags review if its body matches the pre-filter, as
+// InsertComment does for comments.
+func (app *application) screenReview(review *models.Review) {
+	if rule, ok := app.moderationFilter.Match(review.Body); ok {
+		review.Status = models.CommentFlagged
+		review.FlagReason = "matched " + rule
+	}
+}
+
+// writeReview answers a saved review with the review as stored.
+func (app *application) writeReview(w http.ResponseWriter, r *http.Request, review models.Review, message string) {
+	saved, err := app.DB.GetReview(r.Context(), review.MovieID, review.UserID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if saved.Status != models.CommentApproved {
+		message = "review awaiting moderation"
+	}
+	// as for comments, the rule that matched is for moderators only
+	saved.FlagReason = ""
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: message,
+		Data:    saved,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// addCommunityRatings sets Community on movies. Movies nobody rated get a
+// rating of 0 from 0 votes.
+func (app *application) addCommunityRatings(ctx context.Context, movies ...*models.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	ratings, err := app.DB.CommunityRatings(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		rating := ratings[movie.ID]
+		movie.Community = &rating
+	}
+	return nil
+}
+
+// readPage reads the page and page_size query parameters, validated the same
+// way readMovieFilter does.
+func readPage(qs url.Values) (page, pageSize int, err error) {
+	page, pageSize = 1, models.DefaultPageSize
+
+	if v := qs.Get("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, errors.New("page must be a positive integer")
+		}
+	}
+	if v := qs.Get("page_size"); v != "" {
+		pageSize, err = strconv.Atoi(v)
+		if err != nil || pageSize < 1 || pageSize > models.MaxPageSize {
+			return 0, 0, fmt.Errorf("page_size must be between 1 and %d", models.MaxPageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+// ReviewQueue returns one page of reviews for moderators, oldest first. The
+// status query parameter is a comma separated list of states and defaults to
+// flagged; movie_id keeps one movie's reviews.
+func (app *application) ReviewQueue(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	filter := models.ReviewFilter{
+		Statuses:    []string{models.CommentFlagged},
+		OldestFirst: true,
+	}
+
+	var err error
+	filter.Page, filter.PageSize, err = readPage(qs)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if v := qs.Get("status"); v != "" {
+		filter.Statuses, err = readStatuses(v)
+		if err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+	}
+
+	if v := qs.Get("movie_id"); v != "" {
+		filter.MovieID, err = strconv.Atoi(v)
+		if err != nil || filter.MovieID < 1 {
+			app.errorJSON(w, errors.New("movie_id must be a positive integer"))
+			return
+		}
+	}
+
+	reviews, total, err := app.DB.ListReviews(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var payload = struct {
+		Items    []*models.Review `json:"items"`
+		Total    int              `json:"total"`
+		Page     int              `json:"page"`
+		PageSize int              `json:"page_size"`
+		Next     string           `json:"next,omitempty"`
+		Prev     string           `json:"prev,omitempty"`
+	}{
+		Items:    reviews,
+		Total:    total,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+	}
+
+	if filter.Offset()+len(reviews) < total {
+		payload.Next = pageLink(r.URL, filter.Page+1)
+	}
+	if filter.Page > 1 {
+		payload.Prev = pageLink(r.URL, filter.Page-1)
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// ReviewForModeration returns a review with the moderation actions taken on
+// it, newest first.
+func (app *application) ReviewForModeration(w http.ResponseWriter, r *http.Request) {
+	reviewID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	review, err := app.DB.GetReviewByID(r.Context(), reviewID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("review not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	actions, err := app.DB.ReviewActions(r.Context(), reviewID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var payload = struct {
+		Review  *models.Review             `json:"review"`
+		Actions []*models.ModerationAction `json:"actions"`
+	}{
+		Review:  review,
+		Actions: actions,
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// ModerateReview moves a review to another state, with an optional note, and
+// records which admin did it.
+func (app *application) ModerateReview(w http.ResponseWriter, r *http.Request) {
+	reviewID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	action, ok := app.readModeration(w, r)
+	if !ok {
+		return
+	}
+	action.ReviewID = reviewID
+
+	err = app.DB.ModerateReview(r.Context(), action)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("review not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	review, err := app.DB.GetReviewByID(r.Context(), reviewID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "review " + action.ToStatus,
+		Data:    review,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
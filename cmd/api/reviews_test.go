@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/moderation"
+)
+
+type reviewPage struct {
+	Items     []*models.Review       `json:"items"`
+	Total     int                    `json:"total"`
+	Next      string                 `json:"next"`
+	Community models.CommunityRating `json:"community"`
+}
+
+func TestReviews(t *testing.T) {
+	app := newTestApp(t)
+	user := accessToken(t, app, userEmail)
+	viewer := accessToken(t, app, viewerEmail)
+
+	var resp struct {
+		Message string        `json:"message"`
+		Data    models.Review `json:"data"`
+	}
+
+	rr := request(app, "POST", "/movies/2/reviews", `{"rating": 9, "body": "  the diner scene  "}`, user)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	decode(t, rr, &resp)
+	if resp.Data.Rating != 9 || resp.Data.Body != "the diner scene" || resp.Data.Author != "Uma U." || resp.Data.ID == 0 {
+		t.Errorf("unexpected review %+v", resp.Data)
+	}
+
+	rr = request(app, "POST", "/movies/2/reviews", `{"rating": 6}`, viewer)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	// one review per user
+	rr = request(app, "POST", "/movies/2/reviews", `{"rating": 1}`, user)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var page reviewPage
+	decode(t, request(app, "GET", "/movies/2/reviews?page_size=1", "", ""), &page)
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0].Author != "Vera V." || page.Next == "" {
+		t.Errorf("expected the viewer's review first of 2 but got %+v", page)
+	}
+	if page.Community != (models.CommunityRating{Average: 7.5, Votes: 2}) {
+		t.Errorf("unexpected community rating %+v", page.Community)
+	}
+
+	rr = request(app, "PUT", "/movies/2/reviews", `{"rating": 8}`, user)
+	decode(t, rr, &resp)
+	if rr.Code != http.StatusAccepted || resp.Data.Rating != 8 || resp.Data.Body != "" {
+		t.Errorf("expected the review to be updated but got %d: %+v", rr.Code, resp)
+	}
+
+	var movie models.Movie
+	decode(t, request(app, "GET", "/movies/2", "", ""), &movie)
+	if movie.Community == nil || *movie.Community != (models.CommunityRating{Average: 7, Votes: 2}) {
+		t.Errorf("unexpected community rating %+v", movie.Community)
+	}
+
+	rr = request(app, "DELETE", "/movies/2/reviews", "", viewer)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	rr = request(app, "DELETE", "/movies/2/reviews", "", viewer)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+
+	var movies moviePage
+	decode(t, request(app, "GET", "/movies", "", ""), &movies)
+	for _, m := range movies.Items {
+		expected := models.CommunityRating{}
+		if m.ID == 2 {
+			expected = models.CommunityRating{Average: 8, Votes: 1}
+		}
+		if m.Community == nil || *m.Community != expected {
+			t.Errorf("%s: expected %+v but got %+v", m.Title, expected, m.Community)
+		}
+	}
+}
+
+func TestReviewModeration(t *testing.T) {
+	app := newTestApp(t)
+	filter, err := moderation.ParseFilter(strings.NewReader("spoiler\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.moderationFilter = filter
+
+	user := accessToken(t, app, userEmail)
+	admin := accessToken(t, app, adminEmail)
+
+	var resp struct {
+		Message string        `json:"message"`
+		Data    models.Review `json:"data"`
+	}
+
+	rr := request(app, "POST", "/movies/2/reviews", `{"rating": 9, "body": "Spoiler: nobody walks away"}`, user)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	decode(t, rr, &resp)
+	if resp.Data.Status != models.CommentFlagged || resp.Data.FlagReason != "" || resp.Message != "review awaiting moderation" {
+		t.Errorf("expected the review to be flagged quietly but got %+v", resp)
+	}
+	reviewID := resp.Data.ID
+
+	// hidden from the movie, though the rating counts
+	var page reviewPage
+	decode(t, request(app, "GET", "/movies/2/reviews", "", ""), &page)
+	if page.Total != 0 || page.Community.Votes != 1 {
+		t.Errorf("expected no public reviews from 1 vote but got %+v", page)
+	}
+
+	var queue reviewPage
+	decode(t, request(app, "GET", "/admin/reviews", "", admin), &queue)
+	if queue.Total != 1 || queue.Items[0].ID != reviewID || queue.Items[0].FlagReason != "matched spoiler" {
+		t.Fatalf("expected the review in the queue but got %+v", queue)
+	}
+	rr = request(app, "GET", "/admin/reviews", "", user)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 but got %d", rr.Code)
+	}
+
+	rr = request(app, "PUT", fmt.Sprintf("/admin/reviews/%d", reviewID), `{"status": "approved", "note": "mild"}`, admin)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	decode(t, request(app, "GET", "/movies/2/reviews", "", ""), &page)
+	if page.Total != 1 {
+		t.Errorf("expected the approved review to be public but got %+v", page)
+	}
+
+	var detail struct {
+		Review  models.Review              `json:"review"`
+		Actions []*models.ModerationAction `json:"actions"`
+	}
+	decode(t, request(app, "GET", fmt.Sprintf("/admin/reviews/%d", reviewID), "", admin), &detail)
+	if len(detail.Actions) != 1 || detail.Actions[0].Admin != "1" || detail.Actions[0].FromStatus != models.CommentFlagged {
+		t.Errorf("unexpected actions %+v", detail.Actions)
+	}
+
+	// editing a spoiler back in flags it again
+	rr = request(app, "PUT", "/movies/2/reviews", `{"rating": 9, "body": "spoiler alert: wow"}`, user)
+	decode(t, rr, &resp)
+	if resp.Data.Status != models.CommentFlagged {
+		t.Errorf("expected the edited review to be flagged but got %+v", resp.Data)
+	}
+
+	rr = request(app, "PUT", "/admin/reviews/99", `{"status": "approved"}`, admin)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+	rr = request(app, "PUT", fmt.Sprintf("/admin/reviews/%d", reviewID), `{"status": "gone"}`, admin)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 but got %d", rr.Code)
+	}
+}
+
+func TestReviewsReject(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, userEmail)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		token  string
+		status int
+	}{
+		{"anonymous", "POST", "/movies/1/reviews", `{"rating": 5}`, "", http.StatusUnauthorized},
+		{"no rating", "POST", "/movies/1/reviews", `{"body": "x"}`, token, http.StatusBadRequest},
+		{"rating too high", "POST", "/movies/1/reviews", `{"rating": 11}`, token, http.StatusBadRequest},
+		{"unknown field", "POST", "/movies/1/reviews", `{"rating": 5, "stars": 3}`, token, http.StatusBadRequest},
+		{"unknown movie", "POST", "/movies/99/reviews", `{"rating": 5}`, token, http.StatusNotFound},
+		{"not reviewed", "PUT", "/movies/1/reviews", `{"rating": 5}`, token, http.StatusNotFound},
+		{"list unknown movie", "GET", "/movies/99/reviews", "", "", http.StatusNotFound},
+		{"bad page size", "GET", "/movies/1/reviews?page_size=1000", "", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(app, tt.method, tt.target, tt.body, tt.token)
+			if rr.Code != tt.status {
+				t.Errorf("expected %d but got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"net/http"
 	"path/filepath"
+	"watch-a-movie/internal/models"
 )
 
 func (app *application) routes() http.Handler {
@@ -15,18 +16,82 @@ func (app *application) routes() http.Handler {
 
 	mux.Get("/", app.Home)
 	mux.Get("/movies", app.AllMovies)
+	mux.Get("/movies/search", app.SearchMovies)
 	mux.Post("/authenticate", app.authenticate)
+	mux.Post("/register", app.register)
 	mux.Get("/refresh", app.refreshToken)
 	mux.Post("/movie", app.displayMovie)
 	mux.Get("/logout", app.logout)
 	mux.Get("/movies/{id}", app.GetMovie)
+	mux.Get("/movies/{id}/similar", app.SimilarMovies)
+	mux.Get("/movies/{id}/reviews", app.MovieReviews)
+	mux.With(app.authRequired, app.ratingChanges).Post("/movies/{id}/reviews", app.InsertReview)
+	mux.With(app.authRequired, app.ratingChanges).Put("/movies/{id}/reviews", app.UpdateReview)
+	mux.With(app.authRequired, app.ratingChanges).Delete("/movies/{id}/reviews", app.DeleteReview)
+	mux.Get("/movies/{id}/comments", app.MovieComments)
+	mux.With(app.authRequired).Post("/movies/{id}/comments", app.InsertComment)
+	mux.With(app.authRequired).Post("/comments/{id}/reports", app.ReportComment)
+	mux.Get("/people/{id}", app.GetPerson)
 	mux.Get("/genres", app.AllGenres)
+	mux.Get("/posters/{size}/{file}", app.PosterVariant)
+
+	mux.Route("/me", func(mux chi.Router) {
+		mux.Use(app.authRequired)
+
+		mux.Get("/watchlist", app.MyWatchlist)
+		mux.Post("/watchlist", app.SaveWatchlistEntry)
+		mux.Delete("/watchlist/{movieID}", app.RemoveFromWatchlist)
+		mux.Get("/recommendations", app.MyRecommendations)
+	})
 
 	mux.Route("/admin", func(mux chi.Router) {
 		mux.Use(app.authRequired)
-		mux.Get("/movies", app.MovieCatalog)
-		mux.Get("/movies/{id}", app.MovieForEdit)
-		mux.Put("/movies/0", app.InsertMovie)
+
+		// writes to the catalog empty the similar movies cache
+		mux.Group(func(mux chi.Router) {
+			mux.Use(app.catalogChanges)
+
+			mux.With(app.requirePermission(models.PermissionCatalogRead)).Get("/movies", app.MovieCatalog)
+			mux.With(app.requirePermission(models.PermissionCatalogRead)).Get("/movies/export", app.ExportMovies)
+			mux.With(app.requirePermission(models.PermissionCatalogRead)).Get("/movies/{id}", app.MovieForEdit)
+			mux.With(app.requirePermission(models.PermissionCatalogWrite)).Put("/movies/0", app.InsertMovie)
+			mux.With(app.requirePermission(models.PermissionCatalogWrite)).Post("/movies/import", app.ImportMovies)
+			mux.With(app.requirePermission(models.PermissionCatalogWrite)).Put("/movies/{id}", app.UpdateMovie)
+			mux.With(app.requirePermission(models.PermissionCatalogWrite)).Post("/movies/{id}/poster", app.UploadPoster)
+			mux.With(app.requirePermission(models.PermissionCatalogWrite)).Post("/movies/{id}/enrich", app.EnrichMovie)
+			mux.With(app.requirePermission(models.PermissionCatalogDelete)).Delete("/movies/{id}", app.DeleteMovie)
+
+			mux.With(app.requirePermission(models.PermissionCatalogWrite)).Post("/genres", app.InsertGenre)
+			mux.With(app.requirePermission(models.PermissionCatalogWrite)).Put("/genres/{id}", app.UpdateGenre)
+			mux.With(app.requirePermission(models.PermissionCatalogDelete)).Delete("/genres/{id}", app.DeleteGenre)
+			mux.With(app.requirePermission(models.PermissionCatalogDelete)).Post("/genres/{id}/merge", app.MergeGenres)
+		})
+
+		mux.With(app.requirePermission(models.PermissionCatalogRead)).Get("/people", app.SearchPeople)
+		mux.With(app.requirePermission(models.PermissionCatalogWrite)).Post("/people", app.InsertPerson)
+		mux.With(app.requirePermission(models.PermissionCatalogWrite)).Put("/people/{id}", app.UpdatePerson)
+		mux.With(app.requirePermission(models.PermissionCatalogDelete)).Delete("/people/{id}", app.DeletePerson)
+		mux.With(app.requirePermission(models.PermissionCatalogRead)).Get("/movies/{id}/credits", app.MovieCredits)
+		mux.With(app.requirePermission(models.PermissionCatalogWrite)).Post("/movies/{id}/credits", app.InsertCredit)
+		mux.With(app.requirePermission(models.PermissionCatalogWrite)).Put("/credits/{id}", app.UpdateCredit)
+		mux.With(app.requirePermission(models.PermissionCatalogWrite)).Delete("/credits/{id}", app.DeleteCredit)
+
+		mux.Group(func(mux chi.Router) {
+			mux.Use(app.requirePermission(models.PermissionCommentsModerate))
+			mux.Get("/comments", app.CommentQueue)
+			mux.Get("/comments/actions", app.ModerationLog)
+			mux.Get("/comments/{id}", app.CommentForModeration)
+			mux.Put("/comments/{id}", app.ModerateComment)
+			mux.Get("/reviews", app.ReviewQueue)
+			mux.Get("/reviews/{id}", app.ReviewForModeration)
+			mux.Put("/reviews/{id}", app.ModerateReview)
+		})
+
+		mux.Group(func(mux chi.Router) {
+			mux.Use(app.requireRole(models.RoleAdmin))
+			mux.Get("/users", app.AllUsers)
+			mux.Put("/users/{id}/roles", app.UpdateUserRoles)
+		})
 	})
 
 	// Serve static files
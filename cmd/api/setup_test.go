@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/password"
+	"watch-a-movie/internal/recommend"
+	"watch-a-movie/internal/repository/dbrepo"
+)
+
+// test users, all with the password testPassword
+const (
+	adminEmail  = "admin@example.com"
+	editorEmail = "editor@example.com"
+	viewerEmail = "viewer@example.com"
+	userEmail   = "user@example.com"
+
+	testPassword = "secret123"
+)
+
+// cheap bcrypt so the suite stays fast
+var testHasher = password.NewBcrypt(4)
+
+// newTestApp returns an application backed by a freshly seeded in-memory
+// repository, so tests can't see each other's writes.
+func newTestApp(t *testing.T) *application {
+	t.Helper()
+
+	repo := dbrepo.NewMemoryDBRepo()
+
+	hash, err := testHasher.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	repo.Seed(dbrepo.Fixtures{
+		Genres: []models.Genre{
+			{ID: 1, Genre: "Drama"},
+			{ID: 2, Genre: "Crime"},
+			{ID: 3, Genre: "Action"},
+			{ID: 4, Genre: "Comedy"},
+		},
+		Movies: []models.Movie{
+			{ID: 1, Title: "The Godfather", Release: 1972, RuntimeHours: 175, IMDb: 9.2, MPAA: "R", IMDbID: "tt0068646",
+				Description: "The aging patriarch of an organized crime dynasty transfers control to his son.",
+				Poster:      "godfather.jpg", GenresArray: []int{1, 2}, CreatedAt: now.Add(-3 * time.Hour)},
+			{ID: 2, Title: "Heat", Release: 1995, RuntimeHours: 170, IMDb: 8.3, MPAA: "R", IMDbID: "tt0113277",
+				Description: "A group of professional bank robbers start to feel the heat from police.",
+				Poster:      "heat.jpg", GenresArray: []int{2, 3}, CreatedAt: now.Add(-2 * time.Hour)},
+			{ID: 3, Title: "Barbie", Release: 2023, RuntimeHours: 114, IMDb: 6.8, MPAA: "PG-13", IMDbID: "tt1517268",
+				Description: "Barbie suffers a crisis that leads her to question her world.",
+				Poster:      "barbie.jpg", GenresArray: []int{4}, CreatedAt: now.Add(-time.Hour)},
+		},
+		Users: []models.User{
+			// the admin still has a cleartext password from before hashing
+			{ID: 1, FirstName: "Ada", LastName: "Admin", Email: adminEmail, Password: testPassword, Roles: []string{models.RoleAdmin}},
+			{ID: 2, FirstName: "Eddie", LastName: "Editor", Email: editorEmail, Password: hash, Roles: []string{models.RoleEditor}},
+			{ID: 3, FirstName: "Vera", LastName: "Viewer", Email: viewerEmail, Password: hash, Roles: []string{models.RoleViewer}},
+			{ID: 4, FirstName: "Uma", LastName: "User", Email: userEmail, Password: hash},
+		},
+	})
+
+	app := &application{
+		DB:             repo,
+		JWTSecret:      "test-secret",
+		hasher:         testHasher,
+		PosterStorage:  "local",
+		PosterDir:      t.TempDir(),
+		PosterCacheDir: t.TempDir(),
+		recommender:    recommend.Genres{},
+		similarWeights: recommend.DefaultWeights,
+	}
+
+	err = app.setupPosters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.PosterVariantsURL = app.PosterVariantsURL
+	app.dummyHash = hash
+	app.auth = Auth{
+		Issuer:        "example.com",
+		Audience:      "example.com",
+		Secret:        app.JWTSecret,
+		TokenExpiry:   time.Minute * 15,
+		RefreshExpiry: time.Hour * 24,
+		CookiePath:    "/",
+		CookieName:    "__Host-refresh-token",
+		CookieDomain:  "localhost",
+	}
+
+	return app
+}
+
+// accessToken returns a bearer token for the seeded user with that email.
+func accessToken(t *testing.T, app *application, email string) string {
+	t.Helper()
+
+	user, err := app.DB.GetUserByEmail(t.Context(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := app.auth.GenerateTokenPair(&jwtUser{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Roles:     user.Roles,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokens.Token
+}
+
+// request sends a request through the full router. token, if set, is sent as
+// a bearer token.
+func request(app *application, method, target, body, token string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != "" {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	return rr
+}
+
+func decode(t *testing.T, rr *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	err := json.Unmarshal(rr.Body.Bytes(), v)
+	if err != nil {
+		t.Fatalf("decoding %q: %s", rr.Body.String(), err)
+	}
+}
+
+// refreshCookie returns the refresh cookie set on rr, if any.
+func refreshCookie(app *application, rr *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == app.auth.CookieName {
+			return c
+		}
+	}
+	return nil
+}
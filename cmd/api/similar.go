@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/recommend"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// similarMovie is a movie like the one asked about, scored from 0 to 1.
+type similarMovie struct {
+	Movie *models.Movie `json:"movie"`
+	Score float64       `json:"score"`
+}
+
+// similarCache keeps the movies most like each movie, up to MaxPageSize of
+// them, until the catalog changes. Cached movies are shared between requests
+// and must not be changed.
+//
+// Every clear starts a new generation. get returns the generation it read,
+// and put only stores movies ranked in the current one, so a ranking read
+// before a change isn't cached after it.
+type similarCache struct {
+	mu         sync.Mutex
+	movies     map[int][]similarMovie
+	generation uint64
+}
+
+func (c *similarCache) get(id int) ([]similarMovie, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	movies, ok := c.movies[id]
+	return movies, c.generation, ok
+}
+
+func (c *similarCache) put(id int, movies []similarMovie, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if c.movies == nil {
+		c.movies = make(map[int][]similarMovie)
+	}
+	c.movies[id] = movies
+}
+
+func (c *similarCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.movies = nil
+	c.generation++
+}
+
+// catalogChanges empties the similar movies and recommendation caches after
+// every request that may have changed the catalog. Changes made around the
+// api, such as with moviesctl, are not seen until a restart.
+func (app *application) catalogChanges(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.similar.clear()
+			app.recommendations.clear()
+		}
+	})
+}
+
+// SimilarMovies returns the movies most like a movie, most alike first; see
+// recommend.Similar for how they are compared. The limit query parameter caps
+// how many, DefaultPageSize by default.
+func (app *application) SimilarMovies(w http.ResponseWriter, r *http.Request) {
+	movieID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	limit := models.DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			app.errorJSON(w, fmt.Errorf("limit must be between 1 and %d", models.MaxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	movies, generation, ok := app.similar.get(movieID)
+	if !ok {
+		movies, err = app.similarMovies(r.Context(), movieID)
+		if errors.Is(err, sql.ErrNoRows) {
+			app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			app.errorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+		app.similar.put(movieID, movies, generation)
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, movies[:min(limit, len(movies))])
+}
+
+// similarMovies ranks the catalog against movieID. It returns sql.ErrNoRows
+// if there is no such movie.
+func (app *application) similarMovies(ctx context.Context, movieID int) ([]similarMovie, error) {
+	movies, err := app.DB.AllMovies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genres, err := app.DB.MovieGenreIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *recommend.Features
+	byID := make(map[int]*models.Movie, len(movies))
+	catalog := make([]recommend.Features, 0, len(movies))
+	for _, m := range movies {
+		byID[m.ID] = m
+		catalog = append(catalog, recommend.Features{
+			ID:     m.ID,
+			Genres: genres[m.ID],
+			Year:   m.Release,
+			MPAA:   m.MPAA,
+			Rating: m.IMDb,
+		})
+		if m.ID == movieID {
+			target = &catalog[len(catalog)-1]
+		}
+	}
+	if target == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	similar := []similarMovie{}
+	for _, match := range recommend.Similar(*target, catalog, app.similarWeights, models.MaxPageSize) {
+		similar = append(similar, similarMovie{Movie: byID[match.MovieID], Score: match.Score})
+	}
+	return similar, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func similarTitles(t *testing.T, app *application, target string) []string {
+	t.Helper()
+
+	rr := request(app, "GET", target, "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var items []similarMovie
+	decode(t, rr, &items)
+	titles := []string{}
+	for _, item := range items {
+		if item.Score <= 0 || item.Score > 1 {
+			t.Errorf("%s: score %f out of range", item.Movie.Title, item.Score)
+		}
+		titles = append(titles, item.Movie.Title)
+	}
+	return titles
+}
+
+func TestSimilarMovies(t *testing.T) {
+	app := newTestApp(t)
+
+	// The Godfather shares a genre and the R rating with Heat, Barbie only
+	// comes close in rating
+	if got := similarTitles(t, app, "/movies/2/similar"); len(got) != 2 || got[0] != "The Godfather" || got[1] != "Barbie" {
+		t.Fatalf("expected The Godfather, Barbie but got %v", got)
+	}
+	if got := similarTitles(t, app, "/movies/2/similar?limit=1"); len(got) != 1 {
+		t.Errorf("expected one movie but got %v", got)
+	}
+
+	// cached until the catalog changes through the api
+	err := app.DB.DeleteMovie(t.Context(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := similarTitles(t, app, "/movies/2/similar"); len(got) != 2 {
+		t.Errorf("expected the cached movies but got %v", got)
+	}
+
+	rr := request(app, "DELETE", "/admin/movies/1", "", accessToken(t, app, adminEmail))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	if got := similarTitles(t, app, "/movies/2/similar"); len(got) != 0 {
+		t.Errorf("expected no similar movies left but got %v", got)
+	}
+
+	rr = request(app, "GET", "/movies/99/similar", "", "")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+	rr = request(app, "GET", "/movies/2/similar?limit=1000", "", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 but got %d", rr.Code)
+	}
+}
+
+func TestSimilarCacheSkipsStalePuts(t *testing.T) {
+	var c similarCache
+
+	_, generation, ok := c.get(1)
+	if ok {
+		t.Fatal("expected an empty cache")
+	}
+
+	// the catalog changes while the ranking is worked out
+	c.clear()
+	c.put(1, []similarMovie{{Score: 1}}, generation)
+	if _, _, ok := c.get(1); ok {
+		t.Error("expected the stale ranking not to be cached")
+	}
+
+	_, generation, _ = c.get(1)
+	c.put(1, []similarMovie{{Score: 1}}, generation)
+	if movies, _, ok := c.get(1); !ok || len(movies) != 1 {
+		t.Errorf("expected the ranking to be cached but got %v, %v", movies, ok)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+	"watch-a-movie/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxWatchlistNotes caps the notes kept with a watchlist entry, in characters.
+const maxWatchlistNotes = 1000
+
+// currentUserID is the id of the user whose token authorized the request. It
+// must be called behind authRequired.
+func currentUserID(r *http.Request) (int, error) {
+	claims := claimsFromContext(r.Context())
+	if claims == nil {
+		return 0, errors.New("unauthorized")
+	}
+	return strconv.Atoi(claims.Subject)
+}
+
+// MyWatchlist returns the logged in user's watchlist in order. The watched
+// query parameter, true or false, keeps only watched or unwatched movies.
+func (app *application) MyWatchlist(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	v := r.URL.Query().Get("watched")
+	watched, err := boolParam(v, "watched")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	entries, err := app.DB.Watchlist(r.Context(), userID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	items := []*models.WatchlistEntry{}
+	for _, e := range entries {
+		if v == "" || e.Watched == watched {
+			items = append(items, e)
+		}
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, items)
+}
+
+// SaveWatchlistEntry adds a movie to the logged in user's watchlist, or
+// changes it there. Only the fields sent are changed: position moves the
+// movie, counting from 1; notes replaces its notes; watched marks it watched
+// or not, at watched_at or now. Sending watched_at alone marks it watched.
+func (app *application) SaveWatchlistEntry(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		MovieID   int        `json:"movie_id"`
+		Position  *int       `json:"position"`
+		Notes     *string    `json:"notes"`
+		Watched   *bool      `json:"watched"`
+		WatchedAt *time.Time `json:"watched_at"`
+	}
+
+	err = app.readJSON(w, r, &payload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if payload.MovieID <= 0 {
+		app.errorJSON(w, errors.New("movie_id is required"))
+		return
+	}
+	if payload.Position != nil && *payload.Position < 1 {
+		app.errorJSON(w, errors.New("position counts from 1"))
+		return
+	}
+	if payload.Notes != nil && utf8.RuneCountInString(*payload.Notes) > maxWatchlistNotes {
+		app.errorJSON(w, fmt.Errorf("notes can be at most %d characters", maxWatchlistNotes))
+		return
+	}
+	if payload.WatchedAt != nil && payload.WatchedAt.After(time.Now()) {
+		app.errorJSON(w, errors.New("watched_at is in the future"))
+		return
+	}
+	if payload.Watched != nil && !*payload.Watched && payload.WatchedAt != nil {
+		app.errorJSON(w, errors.New("an unwatched movie can't have watched_at"))
+		return
+	}
+
+	entry, err := app.watchlistEntry(r.Context(), userID, payload.MovieID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	added := entry == nil
+	if added {
+		entry = &models.WatchlistEntry{MovieID: payload.MovieID}
+	}
+
+	entry.Position = 0
+	if payload.Position != nil {
+		entry.Position = *payload.Position
+	}
+	if payload.Notes != nil {
+		entry.Notes = *payload.Notes
+	}
+
+	if payload.WatchedAt != nil {
+		entry.WatchedAt = payload.WatchedAt
+	} else if payload.Watched != nil && !*payload.Watched {
+		entry.WatchedAt = nil
+	} else if payload.Watched != nil && entry.WatchedAt == nil {
+		now := time.Now()
+		entry.WatchedAt = &now
+	}
+
+	err = app.DB.SaveWatchlistEntry(r.Context(), userID, *entry)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("movie not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	entry, err = app.watchlistEntry(r.Context(), userID, payload.MovieID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "watchlist updated",
+		Data:    entry,
+	}
+	if added {
+		resp.Message = "added to watchlist"
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// RemoveFromWatchlist takes a movie off the logged in user's watchlist.
+func (app *application) RemoveFromWatchlist(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	movieID, err := strconv.Atoi(chi.URLParam(r, "movieID"))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.DB.RemoveFromWatchlist(r.Context(), userID, movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.errorJSON(w, errors.New("the movie is not on your watchlist"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := JSONResponse{
+		Error:   false,
+		Message: "removed from watchlist",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// watchlistEntry returns the entry for movieID on a user's watchlist, or nil
+// if the movie isn't on it.
+func (app *application) watchlistEntry(ctx context.Context, userID, movieID int) (*models.WatchlistEntry, error) {
+	entries, err := app.DB.Watchlist(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.MovieID == movieID {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// markWatchlist sets InWatchlist on movies when the request carries a valid
+// token, for the public routes that don't require one. Anonymous requests, and
+// requests with a bad token, get the movies as they are.
+func (app *application) markWatchlist(w http.ResponseWriter, r *http.Request, movies ...*models.Movie) {
+	_, claims, err := app.auth.GetTokenFromHeaderAndVerify(w, r)
+	if err != nil {
+		return
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return
+	}
+
+	ids, err := app.DB.WatchlistMovieIDs(r.Context(), userID)
+	if err != nil {
+		log.Printf("watchlist of user %d: %s", userID, err)
+		return
+	}
+
+	for _, movie := range movies {
+		in := ids[movie.ID]
+		movie.InWatchlist = &in
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+	"watch-a-movie/internal/models"
+)
+
+func watchlist(t *testing.T, app *application, token, query string) []models.WatchlistEntry {
+	t.Helper()
+
+	rr := request(app, "GET", "/me/watchlist"+query, "", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d: %s", rr.Code, rr.Body)
+	}
+
+	var entries []models.WatchlistEntry
+	decode(t, rr, &entries)
+	return entries
+}
+
+func movieIDs(entries []models.WatchlistEntry) []int {
+	ids := []int{}
+	for _, e := range entries {
+		ids = append(ids, e.MovieID)
+	}
+	return ids
+}
+
+func TestWatchlist(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, userEmail)
+
+	if entries := watchlist(t, app, token, ""); len(entries) != 0 {
+		t.Fatalf("expected an empty watchlist but got %v", entries)
+	}
+
+	for _, body := range []string{`{"movie_id": 1}`, `{"movie_id": 2, "notes": "the diner scene"}`, `{"movie_id": 3, "position": 1}`} {
+		rr := request(app, "POST", "/me/watchlist", body, token)
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("%s: expected 202 but got %d: %s", body, rr.Code, rr.Body)
+		}
+	}
+
+	entries := watchlist(t, app, token, "")
+	if got := movieIDs(entries); len(got) != 3 || got[0] != 3 || got[1] != 1 || got[2] != 2 {
+		t.Fatalf("expected movies 3, 1, 2 but got %v", got)
+	}
+	if entries[2].Notes != "the diner scene" || entries[2].Movie == nil || entries[2].Movie.Title != "Heat" {
+		t.Errorf("unexpected entry %+v", entries[2])
+	}
+
+	// marking a movie watched keeps its notes and place
+	rr := request(app, "POST", "/me/watchlist", `{"movie_id": 2, "watched": true}`, token)
+	var resp struct {
+		Message string                `json:"message"`
+		Data    models.WatchlistEntry `json:"data"`
+	}
+	decode(t, rr, &resp)
+	if rr.Code != http.StatusAccepted || resp.Message != "watchlist updated" {
+		t.Fatalf("expected the entry to be updated but got %d: %+v", rr.Code, resp)
+	}
+	if !resp.Data.Watched || resp.Data.WatchedAt == nil || resp.Data.Notes != "the diner scene" || resp.Data.Position != 3 {
+		t.Errorf("unexpected entry %+v", resp.Data)
+	}
+
+	// with a date of its own
+	rr = request(app, "POST", "/me/watchlist", `{"movie_id": 3, "watched_at": "2024-02-01T20:00:00Z"}`, token)
+	decode(t, rr, &resp)
+	if !resp.Data.Watched || !resp.Data.WatchedAt.Equal(time.Date(2024, 2, 1, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected entry %+v", resp.Data)
+	}
+
+	if got := movieIDs(watchlist(t, app, token, "?watched=true")); len(got) != 2 || got[0] != 3 || got[1] != 2 {
+		t.Errorf("expected watched movies 3, 2 but got %v", got)
+	}
+	if got := movieIDs(watchlist(t, app, token, "?watched=false")); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected unwatched movie 1 but got %v", got)
+	}
+
+	// and unwatched again
+	rr = request(app, "POST", "/me/watchlist", `{"movie_id": 3, "watched": false, "position": 3}`, token)
+	decode(t, rr, &resp)
+	if resp.Data.Watched || resp.Data.WatchedAt != nil || resp.Data.Position != 3 {
+		t.Errorf("unexpected entry %+v", resp.Data)
+	}
+
+	rr = request(app, "DELETE", "/me/watchlist/1", "", token)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 but got %d: %s", rr.Code, rr.Body)
+	}
+	rr = request(app, "DELETE", "/me/watchlist/1", "", token)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 but got %d", rr.Code)
+	}
+	if got := movieIDs(watchlist(t, app, token, "")); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("expected movies 2, 3 but got %v", got)
+	}
+
+	// every user has a list of their own
+	if entries := watchlist(t, app, accessToken(t, app, viewerEmail), ""); len(entries) != 0 {
+		t.Errorf("expected the viewer's watchlist to be empty but got %v", movieIDs(entries))
+	}
+}
+
+func TestWatchlistRejects(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, userEmail)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"no movie", `{"notes": "x"}`, http.StatusBadRequest},
+		{"unknown movie", `{"movie_id": 99}`, http.StatusNotFound},
+		{"position zero", `{"movie_id": 1, "position": 0}`, http.StatusBadRequest},
+		{"future", `{"movie_id": 1, "watched_at": "2999-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"contradiction", `{"movie_id": 1, "watched": false, "watched_at": "2020-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"unknown field", `{"movie_id": 1, "rating": 5}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(app, "POST", "/me/watchlist", tt.body, token)
+			if rr.Code != tt.status {
+				t.Errorf("expected %d but got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	rr := request(app, "GET", "/me/watchlist", "", "")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: expected 401 but got %d", rr.Code)
+	}
+}
+
+func TestInWatchlist(t *testing.T) {
+	app := newTestApp(t)
+	token := accessToken(t, app, userEmail)
+
+	request(app, "POST", "/me/watchlist", `{"movie_id": 2}`, token)
+
+	// anonymous requests don't say
+	var movie models.Movie
+	decode(t, request(app, "GET", "/movies/2", "", ""), &movie)
+	if movie.InWatchlist != nil {
+		t.Errorf("expected no in_watchlist but got %v", *movie.InWatchlist)
+	}
+
+	decode(t, request(app, "GET", "/movies/2", "", token), &movie)
+	if movie.InWatchlist == nil || !*movie.InWatchlist {
+		t.Errorf("expected movie 2 to be in the watchlist")
+	}
+
+	var page moviePage
+	decode(t, request(app, "GET", "/movies", "", token), &page)
+	for _, m := range page.Items {
+		if m.InWatchlist == nil || *m.InWatchlist != (m.ID == 2) {
+			t.Errorf("%s: unexpected in_watchlist %v", m.Title, m.InWatchlist)
+		}
+	}
+
+	var search struct {
+		Results []*models.MovieSearchResult `json:"results"`
+	}
+	decode(t, request(app, "GET", "/movies/search?q=heat", "", token), &search)
+	if len(search.Results) == 0 || search.Results[0].InWatchlist == nil || !*search.Results[0].InWatchlist {
+		t.Errorf("expected the search result to be in the watchlist: %+v", search.Results)
+	}
+}
@@ -0,0 +1,214 @@
+// Command moviesctl administers the catalog and its users straight against
+// the database, for the jobs that used to need raw SQL.
+//
+//	moviesctl [-db-driver postgres|sqlite] [-dsn dsn] [-json] <group> <command> [args]
+//
+// Run moviesctl without arguments for the list of commands.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"watch-a-movie/internal/password"
+	"watch-a-movie/internal/repository"
+	"watch-a-movie/internal/repository/dbrepo"
+)
+
+// ctl is what every command gets to work with.
+type ctl struct {
+	db     repository.DatabaseRepo
+	hasher password.Hasher
+	out    io.Writer
+
+	// json switches output from tables to JSON for scripting
+	json bool
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *ctl, args []string) error
+}
+
+// commands is keyed by group, then by command. It is filled in by init
+// because commands print their own usage from it.
+var commands map[string]map[string]command
+
+func init() {
+	commands = map[string]map[string]command{
+		"users": {
+			"list":           {"", usersList},
+			"create":         {"-email email -first-name name -last-name name [-password pw] [-roles role,...]", usersCreate},
+			"disable":        {"<email>", usersDisable},
+			"enable":         {"<email>", usersEnable},
+			"reset-password": {"<email> [-password pw]", usersResetPassword},
+			"roles":          {"<email> <role,...|none>", usersRoles},
+		},
+		"movies": {
+			"list":   {"", moviesList},
+			"export": {"[-o file]", moviesExport},
+			"import": {"<file>", moviesImport},
+		},
+		"genres": {
+			"list": {"", genresList},
+		},
+		"recommend": {
+			"evaluate": {"[-k n] [-holdout fraction] [-seed n] [-min-co-raters n] [-weight w]", recommendEvaluate},
+		},
+		"migrate": {
+			"up":     {"", migrateUp},
+			"down":   {"[n]", migrateDown},
+			"status": {"", migrateStatus},
+		},
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("moviesctl: ")
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = "host=localhost port=5432 user=snehil password=hello dbname=movies sslmode=disable timezone=UTC connect_timeout=5"
+	}
+
+	var c ctl
+	var driver, hasher string
+	var timeout time.Duration
+	flag.StringVar(&driver, "db-driver", "postgres", "database backend (postgres or sqlite)")
+	flag.StringVar(&dsn, "dsn", dsn, "Postgres connection string, or the database file for sqlite")
+	flag.DurationVar(&timeout, "db-timeout", 30*time.Second, "timeout for each database query")
+	flag.StringVar(&hasher, "password-hasher", "argon2id", "password hashing algorithm (argon2id or bcrypt)")
+	flag.BoolVar(&c.json, "json", false, "print JSON instead of tables")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)][flag.Arg(1)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	c.hasher, err = password.New(hasher)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch driver {
+	case "postgres":
+		c.db, err = dbrepo.NewPostgresDBRepo(dsn, timeout)
+	case "sqlite":
+		c.db, err = dbrepo.NewSQLiteDBRepo(dsn, timeout)
+	default:
+		err = fmt.Errorf("unknown database driver %q", driver)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.db.Connection().Close()
+
+	c.out = os.Stdout
+
+	err = cmd.run(context.Background(), &c, flag.Args()[2:])
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "usage: moviesctl [flags] <group> <command> [args]")
+	fmt.Fprintln(w, "\ncommands:")
+
+	var groups []string
+	for g := range commands {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	for _, g := range groups {
+		var names []string
+		for name := range commands[g] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s %s %s\n", g, name, commands[g][name].usage)
+		}
+	}
+
+	fmt.Fprintln(w, "\nflags:")
+	flag.PrintDefaults()
+}
+
+// table prints rows under header, or v as JSON in -json mode.
+func (c *ctl) table(v interface{}, header []string, rows [][]string) error {
+	if c.json {
+		return c.printJSON(v)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// done reports the outcome of a command that changed something.
+func (c *ctl) done(v interface{}, format string, args ...interface{}) error {
+	if c.json {
+		return c.printJSON(v)
+	}
+
+	_, err := fmt.Fprintf(c.out, format+"\n", args...)
+	return err
+}
+
+func (c *ctl) printJSON(v interface{}) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// parseFlags parses args with fs, allowing flags and positional arguments to
+// be mixed, and returns the positional ones.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// exactArgs checks that a command got n positional arguments.
+func exactArgs(args []string, n int, usage string) error {
+	if len(args) != n {
+		return errors.New("usage: " + usage)
+	}
+	return nil
+}
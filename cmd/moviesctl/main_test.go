@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/password"
+	"watch-a-movie/internal/repository/dbrepo"
+)
+
+func newTestCtl(t *testing.T) (*ctl, *bytes.Buffer) {
+	t.Helper()
+
+	repo := dbrepo.NewMemoryDBRepo()
+	repo.Seed(dbrepo.Fixtures{
+		Genres: []models.Genre{
+			{ID: 1, Genre: "Drama"},
+			{ID: 2, Genre: "Crime"},
+		},
+		Movies: []models.Movie{
+			{ID: 1, Title: "The Godfather", Release: 1972, RuntimeHours: 175, IMDb: 9.2, MPAA: "R", IMDbID: "tt0068646",
+				Description: "The aging patriarch of an organized crime dynasty transfers control to his son.",
+				Poster:      "godfather.jpg", GenresArray: []int{1, 2}},
+		},
+		Users: []models.User{
+			{ID: 1, FirstName: "Ada", LastName: "Admin", Email: "admin@example.com", Roles: []string{models.RoleAdmin}},
+		},
+	})
+
+	var out bytes.Buffer
+	return &ctl{db: repo, hasher: password.NewBcrypt(4), out: &out}, &out
+}
+
+func run(t *testing.T, c *ctl, group, name string, args ...string) error {
+	t.Helper()
+	return commands[group][name].run(t.Context(), c, args)
+}
+
+func TestUsersCreate(t *testing.T) {
+	c, out := newTestCtl(t)
+
+	err := run(t, c, "users", "create", "-email", " New@Example.com", "-first-name", "New", "-last-name", "User", "-roles", "editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "created user") || !strings.Contains(out.String(), "with password ") {
+		t.Errorf("expected the generated password to be printed but got %q", out)
+	}
+
+	user, err := c.db.GetUserByEmail(t.Context(), "new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Roles) != 1 || user.Roles[0] != models.RoleEditor {
+		t.Errorf("expected the editor role but got %v", user.Roles)
+	}
+
+	pw := strings.TrimSpace(out.String()[strings.LastIndex(out.String(), " "):])
+	ok, _ := c.hasher.Verify(pw, user.Password)
+	if !ok {
+		t.Error("expected the printed password to match the stored hash")
+	}
+
+	err = run(t, c, "users", "create", "-email", "new@example.com", "-first-name", "New", "-last-name", "User")
+	if err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("expected a duplicate error but got %v", err)
+	}
+
+	err = run(t, c, "users", "create", "-email", "x@example.com", "-first-name", "X", "-last-name", "Y", "-roles", "owner")
+	if err == nil {
+		t.Error("expected an unknown role to be rejected")
+	}
+}
+
+func TestUsersDisableEnable(t *testing.T) {
+	c, _ := newTestCtl(t)
+
+	err := run(t, c, "users", "disable", "ADMIN@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := c.db.GetUserByEmail(t.Context(), "admin@example.com")
+	if !user.Disabled {
+		t.Fatal("expected the user to be disabled")
+	}
+
+	err = run(t, c, "users", "enable", "admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ = c.db.GetUserByEmail(t.Context(), "admin@example.com")
+	if user.Disabled {
+		t.Error("expected the user to be enabled")
+	}
+
+	err = run(t, c, "users", "disable", "nobody@example.com")
+	if err == nil || !strings.Contains(err.Error(), "no user") {
+		t.Errorf("expected an unknown user error but got %v", err)
+	}
+}
+
+func TestUsersResetPasswordAndRoles(t *testing.T) {
+	c, _ := newTestCtl(t)
+
+	err := run(t, c, "users", "reset-password", "admin@example.com", "-password", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := c.db.GetUserByEmail(t.Context(), "admin@example.com")
+	ok, _ := c.hasher.Verify("hunter22", user.Password)
+	if !ok {
+		t.Error("expected the new password to be stored")
+	}
+
+	err = run(t, c, "users", "roles", "admin@example.com", "viewer,editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ = c.db.GetUserByEmail(t.Context(), "admin@example.com")
+	if strings.Join(user.Roles, ",") != "editor,viewer" && strings.Join(user.Roles, ",") != "viewer,editor" {
+		t.Errorf("expected viewer and editor but got %v", user.Roles)
+	}
+
+	err = run(t, c, "users", "roles", "admin@example.com", "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ = c.db.GetUserByEmail(t.Context(), "admin@example.com")
+	if len(user.Roles) != 0 {
+		t.Errorf("expected no roles but got %v", user.Roles)
+	}
+}
+
+func TestUsersListJSON(t *testing.T) {
+	c, out := newTestCtl(t)
+	c.json = true
+
+	err := run(t, c, "users", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users []models.User
+	err = json.Unmarshal(out.Bytes(), &users)
+	if err != nil {
+		t.Fatalf("decoding %q: %s", out, err)
+	}
+	if len(users) != 1 || users[0].Email != "admin@example.com" {
+		t.Errorf("unexpected users %+v", users)
+	}
+}
+
+func TestMoviesExportImport(t *testing.T) {
+	c, out := newTestCtl(t)
+	file := filepath.Join(t.TempDir(), "movies.jsonl")
+
+	err := run(t, c, "movies", "export", "-o", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record movieRecord
+	err = json.Unmarshal(bytes.TrimSpace(exported), &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Title != "The Godfather" || record.Runtime != 175 || record.Poster != "godfather.jpg" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if strings.Join(record.Genres, ",") != "Crime,Drama" {
+		t.Errorf("expected genres by name but got %v", record.Genres)
+	}
+
+	// import into an empty catalog; the genres are created along the way
+	other, _ := newTestCtl(t)
+	other.db = dbrepo.NewMemoryDBRepo()
+	other.out = out
+	out.Reset()
+
+	err = run(t, other, "movies", "import", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "imported 1 movies") {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	movies, _ := other.db.AllMovies(t.Context())
+	if len(movies) != 1 {
+		t.Fatalf("expected 1 movie but got %d", len(movies))
+	}
+
+	movie, err := other.db.OneMovie(t.Context(), movies[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Runtime() != 175 || len(movie.Genres) != 2 {
+		t.Errorf("unexpected movie %+v", movie)
+	}
+}
+
+func TestMoviesImportIsAllOrNothing(t *testing.T) {
+	c, _ := newTestCtl(t)
+	file := filepath.Join(t.TempDir(), "movies.jsonl")
+
+	lines := `{"title": "Heat", "release": 1995, "genres": ["Crime", "Thriller"]}` + "\n" + `{"release": 2023}` + "\n"
+	err := os.WriteFile(file, []byte(lines), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = run(t, c, "movies", "import", file)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error on line 2 but got %v", err)
+	}
+
+	movies, _ := c.db.AllMovies(t.Context())
+	if len(movies) != 1 {
+		t.Errorf("expected nothing to be imported but got %d movies", len(movies))
+	}
+}
+
+func TestGenresList(t *testing.T) {
+	c, out := newTestCtl(t)
+
+	err := run(t, c, "genres", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "Crime") {
+		t.Errorf("unexpected table %q", out)
+	}
+}
+
+func TestRecommendEvaluate(t *testing.T) {
+	c, out := newTestCtl(t)
+
+	// everybody likes the dramas and not the crime movies
+	var movies []models.Movie
+	for i := 2; i <= 9; i++ {
+		movies = append(movies, models.Movie{ID: i, Title: fmt.Sprint("Movie ", i), GenresArray: []int{1 + i%2}})
+	}
+	var users []models.User
+	for i := 2; i <= 6; i++ {
+		users = append(users, models.User{ID: i, Email: fmt.Sprintf("user%d@example.com", i)})
+	}
+	c.db.(*dbrepo.MemoryDBRepo).Seed(dbrepo.Fixtures{Movies: movies, Users: users})
+
+	for _, u := range users {
+		for _, m := range movies {
+			rating := 3
+			if m.GenresArray[0] == 1 {
+				rating = 9
+			}
+			_, err := c.db.InsertReview(t.Context(), models.Review{MovieID: m.ID, UserID: u.ID, Rating: rating, Status: models.CommentApproved})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	c.json = true
+	err := run(t, c, "recommend", "evaluate", "-k", "2", "-holdout", "0.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := out.String()
+
+	var results []evaluationRow
+	err = json.Unmarshal(out.Bytes(), &results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Recommender != "hybrid" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	for _, r := range results {
+		if r.K != 2 || r.Users == 0 || r.Precision <= 0 {
+			t.Errorf("unexpected evaluation %+v", r)
+		}
+	}
+
+	out.Reset()
+	err = run(t, c, "recommend", "evaluate", "-k", "2", "-holdout", "0.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != first {
+		t.Errorf("expected the same results again but got %s after %s", out, first)
+	}
+
+	err = run(t, c, "recommend", "evaluate", "-holdout", "1")
+	if err == nil {
+		t.Error("expected -holdout 1 to be rejected")
+	}
+}
+
+func TestMigrateRejectsSQLite(t *testing.T) {
+	c, _ := newTestCtl(t)
+
+	repo, err := dbrepo.NewSQLiteDBRepo(filepath.Join(t.TempDir(), "movies.db"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Connection().Close()
+	c.db = repo
+
+	err = run(t, c, "migrate", "status")
+	if err == nil || !strings.Contains(err.Error(), "postgres") {
+		t.Errorf("expected migrations to be refused on sqlite but got %v", err)
+	}
+}
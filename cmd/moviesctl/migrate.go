@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"watch-a-movie/internal/migrations"
+	"watch-a-movie/internal/repository/dbrepo"
+)
+
+// migrator returns a Migrator for the database. Migrations are Postgres SQL;
+// SQLite databases keep their schema up to date when they are opened.
+func (c *ctl) migrator() (*migrations.Migrator, error) {
+	if _, ok := c.db.(*dbrepo.SQLiteDBRepo); ok {
+		return nil, errors.New("migrations only apply to postgres; sqlite databases are upgraded when opened")
+	}
+	return migrations.New(c.db.Connection())
+}
+
+func migrateUp(ctx context.Context, c *ctl, args []string) error {
+	if err := exactArgs(args, 0, "migrate up"); err != nil {
+		return err
+	}
+
+	migrator, err := c.migrator()
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.done(names(applied), "applied %d migrations", len(applied))
+}
+
+func migrateDown(ctx context.Context, c *ctl, args []string) error {
+	steps := 1
+	switch len(args) {
+	case 0:
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("migrate down: %q is not a positive number", args[0])
+		}
+		steps = n
+	default:
+		return errors.New("usage: migrate down " + commands["migrate"]["down"].usage)
+	}
+
+	migrator, err := c.migrator()
+	if err != nil {
+		return err
+	}
+
+	rolledBack, err := migrator.Down(ctx, steps)
+	if err != nil {
+		return err
+	}
+
+	return c.done(names(rolledBack), "rolled back %d migrations", len(rolledBack))
+}
+
+func migrateStatus(ctx context.Context, c *ctl, args []string) error {
+	if err := exactArgs(args, 0, "migrate status"); err != nil {
+		return err
+	}
+
+	migrator, err := c.migrator()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	type status struct {
+		Version   int        `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"applied_at"`
+	}
+
+	var out []status
+	var rows [][]string
+	for _, s := range statuses {
+		out = append(out, status{Version: s.Version, Name: s.Name, AppliedAt: s.AppliedAt})
+
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{fmt.Sprintf("%04d", s.Version), s.Name, applied})
+	}
+
+	return c.table(out, []string{"VERSION", "NAME", "APPLIED"}, rows)
+}
+
+func names(ms []migrations.Migration) []string {
+	names := []string{}
+	for _, m := range ms {
+		names = append(names, fmt.Sprintf("%04d_%s", m.Version, m.Name))
+	}
+	return names
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+// movieRecord is one line of movies export, and of movies import. Genres are
+// given by name so that files can move between databases.
+type movieRecord struct {
+	Title       string   `json:"title"`
+	Release     int      `json:"release"`
+	Runtime     int      `json:"runtime"` // minutes
+	MPAA        string   `json:"mpaa"`
+	IMDb        float32  `json:"imdb"`
+	IMDbID      string   `json:"imdb_id"`
+	Description string   `json:"description"`
+	Poster      string   `json:"poster"`
+	Genres      []string `json:"genres"`
+}
+
+func moviesList(ctx context.Context, c *ctl, args []string) error {
+	movies, err := c.db.AllMovies(ctx)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, m := range movies {
+		rows = append(rows, []string{
+			strconv.Itoa(m.ID), m.Title, strconv.Itoa(m.Release), m.MPAA,
+			strconv.FormatFloat(float64(m.IMDb), 'f', 1, 32), m.IMDbID,
+		})
+	}
+
+	return c.table(movies, []string{"ID", "TITLE", "RELEASE", "MPAA", "IMDB", "IMDB ID"}, rows)
+}
+
+// moviesExport writes every movie as a line of JSON, to stdout or -o.
+func moviesExport(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("movies export", flag.ContinueOnError)
+	file := fs.String("o", "", "write to this file instead of stdout")
+
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("usage: movies export " + commands["movies"]["export"].usage)
+	}
+
+	out := c.out
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	movies, err := c.db.AllMovies(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	for _, m := range movies {
+		// AllMovies leaves out genres
+		movie, err := c.db.OneMovie(ctx, m.ID)
+		if err != nil {
+			return err
+		}
+
+		record := movieRecord{
+			Title:       movie.Title,
+			Release:     movie.Release,
+			Runtime:     movie.Runtime(),
+			MPAA:        movie.MPAA,
+			IMDb:        movie.IMDb,
+			IMDbID:      movie.IMDbID,
+			Description: movie.Description,
+			Poster:      movie.Poster[strings.LastIndex(movie.Poster, "/")+1:],
+		}
+		for _, g := range movie.Genres {
+			record.Genres = append(record.Genres, g.Genre)
+		}
+
+		err = enc.Encode(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *file != "" {
+		fmt.Fprintf(c.out, "exported %d movies to %s\n", len(movies), *file)
+	}
+	return nil
+}
+
+// moviesImport adds the movies in a file written by movies export, creating
+// any genres that don't exist yet. Either every movie is imported or none.
+func moviesImport(ctx context.Context, c *ctl, args []string) error {
+	if err := exactArgs(args, 1, "movies import "+commands["movies"]["import"].usage); err != nil {
+		return err
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := readMovieRecords(f)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	err = c.db.WithTx(ctx, func(repo repository.DatabaseRepo) error {
+		genres, err := genreIDsByName(ctx, repo)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, r := range records {
+			var genreIDs []int
+			for _, name := range r.Genres {
+				id, ok := genres[strings.ToLower(name)]
+				if !ok {
+					id, err = repo.InsertGenre(ctx, models.Genre{Genre: name, CreatedAt: now, UpdatedAt: now})
+					if err != nil {
+						return fmt.Errorf("genre %q: %w", name, err)
+					}
+					genres[strings.ToLower(name)] = id
+				}
+				genreIDs = append(genreIDs, id)
+			}
+
+			id, err := repo.InsertMovie(ctx, models.Movie{
+				Title:          r.Title,
+				Release:        r.Release,
+				RuntimeMinutes: r.Runtime,
+				MPAA:           r.MPAA,
+				IMDb:           r.IMDb,
+				IMDbID:         r.IMDbID,
+				Description:    r.Description,
+				Poster:         r.Poster,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			})
+			if err != nil {
+				return fmt.Errorf("movie %q: %w", r.Title, err)
+			}
+
+			err = repo.UpdateMovieGenres(ctx, id, genreIDs)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.done(map[string]interface{}{"imported": len(ids), "ids": ids}, "imported %d movies", len(ids))
+}
+
+func readMovieRecords(r io.Reader) ([]movieRecord, error) {
+	var records []movieRecord
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record movieRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.TrimSpace(record.Title) == "" {
+			return nil, fmt.Errorf("line %d: title is required", line)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// genreIDsByName maps lower-cased genre names to their ids.
+func genreIDsByName(ctx context.Context, repo repository.DatabaseRepo) (map[string]int, error) {
+	genres, err := repo.AllGenres(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int, len(genres))
+	for _, g := range genres {
+		ids[strings.ToLower(g.Genre)] = g.ID
+	}
+
+	return ids, nil
+}
+
+func genresList(ctx context.Context, c *ctl, args []string) error {
+	genres, err := c.db.AllGenres(ctx)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, g := range genres {
+		rows = append(rows, []string{strconv.Itoa(g.ID), g.Genre, strconv.Itoa(g.Movies)})
+	}
+
+	return c.table(genres, []string{"ID", "GENRE", "MOVIES"}, rows)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strconv"
+	"watch-a-movie/internal/recommend"
+)
+
+type evaluationRow struct {
+	Recommender string `json:"recommender"`
+	recommend.Evaluation
+}
+
+// recommendEvaluate holds out some of every user's ratings and reports how
+// many of the movies each recommender picks from the rest the users liked in
+// the held-out ones, as precision at k. The same seed gives the same numbers
+// for the same ratings.
+func recommendEvaluate(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("recommend evaluate", flag.ContinueOnError)
+	k := fs.Int("k", 10, "movies recommended to each user")
+	holdout := fs.Float64("holdout", 0.2, "fraction of each user's ratings held out")
+	seed := fs.Uint64("seed", 1, "seed for picking the held-out ratings")
+	minCoRaters := fs.Int("min-co-raters", recommend.DefaultMinCoRaters, "users who must have rated two movies to compare their ratings")
+	weight := fs.Float64("weight", recommend.DefaultWeight, "share of co-rating similarity in the hybrid recommender")
+
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("usage: recommend evaluate " + commands["recommend"]["evaluate"].usage)
+	}
+	if *k < 1 {
+		return errors.New("-k must be at least 1")
+	}
+	if *holdout <= 0 || *holdout >= 1 {
+		return errors.New("-holdout must be between 0 and 1")
+	}
+
+	genres, err := c.db.MovieGenreIDs(ctx)
+	if err != nil {
+		return err
+	}
+	ratings, err := c.db.AllRatings(ctx)
+	if err != nil {
+		return err
+	}
+
+	// watchlists are left out: the held-out movies may be on them, and the
+	// recommenders never pick movies on the user's watchlist
+	train, test := recommend.Split(&recommend.Data{Genres: genres, Ratings: ratings}, *holdout, *seed)
+
+	recommenders := map[string]recommend.Recommender{
+		"hybrid":     recommend.Hybrid{MinCoRaters: *minCoRaters, Weight: *weight},
+		"genres":     recommend.Genres{},
+		"co-ratings": recommend.CoRatings{MinCoRaters: *minCoRaters},
+	}
+
+	var results []evaluationRow
+	var rows [][]string
+	for _, name := range recommend.Names {
+		eval := recommend.Evaluate(recommenders[name], train, test, *k)
+		results = append(results, evaluationRow{Recommender: name, Evaluation: eval})
+		rows = append(rows, []string{name, strconv.Itoa(eval.Users), strconv.FormatFloat(eval.Precision, 'f', 4, 64)})
+	}
+
+	return c.table(results, []string{"RECOMMENDER", "USERS", "PRECISION@" + strconv.Itoa(*k)}, rows)
+}
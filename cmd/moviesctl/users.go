@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+func usersList(ctx context.Context, c *ctl, args []string) error {
+	users, err := c.db.AllUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, u := range users {
+		status := "active"
+		if u.Disabled {
+			status = "disabled"
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(u.ID), u.Email, u.FirstName + " " + u.LastName, strings.Join(u.Roles, ","), status,
+		})
+	}
+
+	return c.table(users, []string{"ID", "EMAIL", "NAME", "ROLES", "STATUS"}, rows)
+}
+
+func usersCreate(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	email := fs.String("email", "", "email address to log in with")
+	firstName := fs.String("first-name", "", "first name")
+	lastName := fs.String("last-name", "", "last name")
+	pw := fs.String("password", "", "password; a random one is generated and printed if empty")
+	roles := fs.String("roles", "", "comma separated roles")
+
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 || *email == "" || *firstName == "" || *lastName == "" {
+		return errors.New("usage: users create " + commands["users"]["create"].usage)
+	}
+
+	user := models.User{
+		FirstName: strings.TrimSpace(*firstName),
+		LastName:  strings.TrimSpace(*lastName),
+		Email:     normalizeEmail(*email),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	user.Roles, err = parseRoles(*roles)
+	if err != nil {
+		return err
+	}
+
+	plain, generated, err := passwordOrRandom(*pw)
+	if err != nil {
+		return err
+	}
+	user.Password, err = c.hasher.Hash(plain)
+	if err != nil {
+		return err
+	}
+
+	user.ID, err = c.db.InsertUser(ctx, user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return fmt.Errorf("%s is already registered", user.Email)
+	}
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		*models.User
+		Password string `json:"password,omitempty"`
+	}{User: &user}
+
+	if generated {
+		result.Password = plain
+		return c.done(result, "created user %d %s with password %s", user.ID, user.Email, plain)
+	}
+	return c.done(result, "created user %d %s", user.ID, user.Email)
+}
+
+func usersDisable(ctx context.Context, c *ctl, args []string) error {
+	return setDisabled(ctx, c, args, true)
+}
+
+func usersEnable(ctx context.Context, c *ctl, args []string) error {
+	return setDisabled(ctx, c, args, false)
+}
+
+func setDisabled(ctx context.Context, c *ctl, args []string, disabled bool) error {
+	if err := exactArgs(args, 1, "users disable|enable <email>"); err != nil {
+		return err
+	}
+
+	user, err := findUser(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	err = c.db.SetUserDisabled(ctx, user.ID, disabled)
+	if err != nil {
+		return err
+	}
+	user.Disabled = disabled
+
+	if disabled {
+		return c.done(user, "disabled %s and revoked their sessions", user.Email)
+	}
+	return c.done(user, "enabled %s", user.Email)
+}
+
+func usersResetPassword(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("users reset-password", flag.ContinueOnError)
+	pw := fs.String("password", "", "new password; a random one is generated and printed if empty")
+
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(rest, 1, "users reset-password "+commands["users"]["reset-password"].usage); err != nil {
+		return err
+	}
+
+	user, err := findUser(ctx, c, rest[0])
+	if err != nil {
+		return err
+	}
+
+	plain, generated, err := passwordOrRandom(*pw)
+	if err != nil {
+		return err
+	}
+
+	hash, err := c.hasher.Hash(plain)
+	if err != nil {
+		return err
+	}
+
+	err = c.db.UpdateUserPassword(ctx, user.ID, hash)
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		Email    string `json:"email"`
+		Password string `json:"password,omitempty"`
+	}{Email: user.Email}
+
+	if generated {
+		result.Password = plain
+		return c.done(result, "new password for %s: %s", user.Email, plain)
+	}
+	return c.done(result, "password for %s changed", user.Email)
+}
+
+func usersRoles(ctx context.Context, c *ctl, args []string) error {
+	if err := exactArgs(args, 2, "users roles "+commands["users"]["roles"].usage); err != nil {
+		return err
+	}
+
+	user, err := findUser(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	roles, err := parseRoles(args[1])
+	if err != nil {
+		return err
+	}
+
+	err = c.db.SetUserRoles(ctx, user.ID, roles)
+	if err != nil {
+		return err
+	}
+	user.Roles = roles
+
+	if len(roles) == 0 {
+		return c.done(user, "%s has no roles", user.Email)
+	}
+	return c.done(user, "%s now has roles %s", user.Email, strings.Join(roles, ", "))
+}
+
+func findUser(ctx context.Context, c *ctl, email string) (*models.User, error) {
+	user, err := c.db.GetUserByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no user with email %s", email)
+	}
+	return user, err
+}
+
+// parseRoles reads a comma separated list of roles; "none" or an empty string
+// is no roles at all.
+func parseRoles(s string) ([]string, error) {
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+
+	var roles []string
+	for _, role := range strings.Split(s, ",") {
+		role = strings.TrimSpace(role)
+		if !models.ValidRole(role) {
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+// passwordOrRandom returns pw, or a random password if pw is empty, and
+// whether it was generated.
+func passwordOrRandom(pw string) (string, bool, error) {
+	if pw != "" {
+		return pw, false, nil
+	}
+
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", false, err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), true, nil
+}
+
+// normalizeEmail matches how the api stores emails.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
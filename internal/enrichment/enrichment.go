@@ -0,0 +1,65 @@
+// Package enrichment looks movies up by IMDb ID in an external metadata
+// service, to fill in what the catalog is missing.
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"watch-a-movie/internal/models"
+)
+
+// MetadataProvider looks up a movie's metadata by its IMDb ID, such as
+// tt0068646. Movies the provider doesn't know return ErrNotFound.
+type MetadataProvider interface {
+	Lookup(ctx context.Context, imdbID string) (*Metadata, error)
+}
+
+// ErrNotFound is returned for IMDb IDs the provider has no movie for.
+var ErrNotFound = errors.New("enrichment: movie not found")
+
+// Metadata is what a provider knows about a movie. Fields it doesn't know are
+// left at their zero value.
+type Metadata struct {
+	Title       string
+	Release     int // year
+	Runtime     int // in minutes
+	IMDb        float32
+	MPAA        string
+	Description string
+	PosterURL   string
+}
+
+// Fill sets the fields of movie that are empty to their values in md, and
+// returns the json names of the fields it set. Posters are left alone, as the
+// provider only has a url for them.
+func Fill(movie *models.Movie, md *Metadata) []string {
+	var filled []string
+
+	if movie.Title == "" && md.Title != "" {
+		movie.Title = md.Title
+		filled = append(filled, "title")
+	}
+	if movie.Release == 0 && md.Release != 0 {
+		movie.Release = md.Release
+		filled = append(filled, "release")
+	}
+	if movie.Runtime() == 0 && md.Runtime != 0 {
+		movie.RuntimeHours = md.Runtime / 60
+		movie.RuntimeMinutes = md.Runtime % 60
+		filled = append(filled, "runtime")
+	}
+	if movie.IMDb == 0 && md.IMDb != 0 {
+		movie.IMDb = md.IMDb
+		filled = append(filled, "imdb")
+	}
+	if movie.MPAA == "" && md.MPAA != "" {
+		movie.MPAA = md.MPAA
+		filled = append(filled, "mpaa")
+	}
+	if movie.Description == "" && md.Description != "" {
+		movie.Description = md.Description
+		filled = append(filled, "description")
+	}
+
+	return filled
+}
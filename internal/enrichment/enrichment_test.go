@@ -0,0 +1,103 @@
+package enrichment
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"watch-a-movie/internal/models"
+)
+
+func omdbStub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Query().Get("apikey") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"Response":"False","Error":"Invalid API key!"}`))
+			return
+		}
+
+		switch r.URL.Query().Get("i") {
+		case "tt0068646":
+			w.Write([]byte(`{"Title":"The Godfather","Year":"1972","Rated":"R","Runtime":"175 min",
+				"Plot":"The aging patriarch of an organized crime dynasty transfers control to his son.",
+				"Poster":"https://example.com/godfather.jpg","imdbRating":"9.2","imdbID":"tt0068646","Response":"True"}`))
+		case "tt0903747":
+			w.Write([]byte(`{"Title":"Breaking Bad","Year":"2008–2013","Rated":"TV-MA","Runtime":"N/A",
+				"Plot":"N/A","Poster":"N/A","imdbRating":"N/A","Response":"True"}`))
+		default:
+			w.Write([]byte(`{"Response":"False","Error":"Incorrect IMDb ID."}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestOMDbClient(t *testing.T) {
+	srv := omdbStub(t)
+	client := OMDbClient{BaseURL: srv.URL, APIKey: "key"}
+
+	md, err := client.Lookup(t.Context(), "tt0068646")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Metadata{
+		Title:       "The Godfather",
+		Release:     1972,
+		Runtime:     175,
+		IMDb:        9.2,
+		MPAA:        "R",
+		Description: "The aging patriarch of an organized crime dynasty transfers control to his son.",
+		PosterURL:   "https://example.com/godfather.jpg",
+	}
+	if *md != expected {
+		t.Errorf("expected %+v but got %+v", expected, *md)
+	}
+
+	// unknown values are left empty
+	md, err = client.Lookup(t.Context(), "tt0903747")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Release != 2008 || md.Runtime != 0 || md.IMDb != 0 || md.Description != "" || md.PosterURL != "" {
+		t.Errorf("unexpected metadata %+v", *md)
+	}
+
+	_, err = client.Lookup(t.Context(), "tt0000000")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	client.APIKey = "wrong"
+	_, err = client.Lookup(t.Context(), "tt0068646")
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "Invalid API key") {
+		t.Errorf("expected an api key error but got %v", err)
+	}
+}
+
+func TestFill(t *testing.T) {
+	movie := models.Movie{Title: "Godfather", MPAA: "PG-13"}
+	md := &Metadata{Title: "The Godfather", Release: 1972, Runtime: 175, MPAA: "R", IMDb: 9.2}
+
+	filled := Fill(&movie, md)
+	if strings.Join(filled, ",") != "release,runtime,imdb" {
+		t.Errorf("unexpected fields filled %v", filled)
+	}
+
+	// what was there is kept
+	if movie.Title != "Godfather" || movie.MPAA != "PG-13" {
+		t.Errorf("expected the title and rating to be kept but got %q, %q", movie.Title, movie.MPAA)
+	}
+	if movie.RuntimeHours != 2 || movie.RuntimeMinutes != 55 || movie.Release != 1972 {
+		t.Errorf("unexpected movie %+v", movie)
+	}
+
+	if filled := Fill(&movie, md); len(filled) != 0 {
+		t.Errorf("expected nothing left to fill but got %v", filled)
+	}
+}
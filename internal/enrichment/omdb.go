@@ -0,0 +1,125 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultOMDbURL is the public OMDb API.
+const DefaultOMDbURL = "https://www.omdbapi.com/"
+
+// OMDbClient is a MetadataProvider backed by the OMDb API, or anything that
+// answers its ?i=<imdb id>&apikey=<key> requests the same way.
+type OMDbClient struct {
+	BaseURL string // DefaultOMDbURL if empty
+	APIKey  string
+
+	// Client is http.DefaultClient if nil
+	Client *http.Client
+}
+
+// omdbMovie is the part of an OMDb response that is used. OMDb sends every
+// value as a string, with N/A for unknown ones.
+type omdbMovie struct {
+	Response   string `json:"Response"`
+	Error      string `json:"Error"`
+	Title      string `json:"Title"`
+	Year       string `json:"Year"`
+	Rated      string `json:"Rated"`
+	Runtime    string `json:"Runtime"`
+	Plot       string `json:"Plot"`
+	Poster     string `json:"Poster"`
+	IMDbRating string `json:"imdbRating"`
+}
+
+func (c OMDbClient) Lookup(ctx context.Context, imdbID string) (*Metadata, error) {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultOMDbURL
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("i", imdbID)
+	q.Set("apikey", c.APIKey)
+	q.Set("plot", "short")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// OMDb answers unknown ids with 200 and Response False, and a bad key
+	// with 401 and the same kind of body
+	var movie omdbMovie
+	err = json.NewDecoder(resp.Body).Decode(&movie)
+	if err != nil {
+		return nil, fmt.Errorf("enrichment: omdb: %d response: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode == http.StatusOK && movie.Response == "False" {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK || movie.Response != "True" {
+		return nil, fmt.Errorf("enrichment: omdb: %d %s", resp.StatusCode, movie.Error)
+	}
+
+	md := &Metadata{
+		Title:       known(movie.Title),
+		MPAA:        known(movie.Rated),
+		Description: known(movie.Plot),
+		PosterURL:   known(movie.Poster),
+	}
+
+	// Year is 1972, or 2008–2013 for series
+	if year, err := strconv.Atoi(firstNumber(movie.Year)); err == nil {
+		md.Release = year
+	}
+	// Runtime is 175 min
+	if runtime, err := strconv.Atoi(firstNumber(movie.Runtime)); err == nil {
+		md.Runtime = runtime
+	}
+	if rating, err := strconv.ParseFloat(known(movie.IMDbRating), 32); err == nil {
+		md.IMDb = float32(rating)
+	}
+
+	return md, nil
+}
+
+// known returns s, or nothing if it's OMDb's N/A.
+func known(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "N/A" {
+		return ""
+	}
+	return s
+}
+
+// firstNumber returns the digits s starts with.
+func firstNumber(s string) string {
+	s = known(s)
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
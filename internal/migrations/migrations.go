@@ -0,0 +1,338 @@
+// Package migrations holds the Postgres schema as ordered up/down SQL files
+// embedded in the binary, and applies them, recording each applied version in
+// the schema_migrations table.
+//
+// Databases created before migrations existed already have the catalog
+// tables. The early migrations only use IF NOT EXISTS, so running up against
+// such a database adopts it: the existing tables are left alone and recorded
+// as migrated, and whatever is missing is added. There is no separate baseline
+// step.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Dir is where the migration files live, relative to the module root; create
+// writes new migrations here.
+const Dir = "internal/migrations/sql"
+
+// lockID is an arbitrary key for pg_advisory_xact_lock, so that several
+// instances starting at once don't apply the same migration twice.
+const lockID = 7_310_452_118
+
+// Migration is one schema change, read from a pair of files named
+// 0001_name.up.sql and 0001_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied, if it was.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in fsys, ordered by version. Every migration must
+// have both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migrations: %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations against a Postgres database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New returns a Migrator for the migrations embedded in the binary.
+func New(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	err := m.ensureTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range m.Migrations {
+		ran, err := m.apply(ctx, migration.Version, func(tx *sql.Tx, done bool) error {
+			if done {
+				return errSkip
+			}
+
+			_, err := tx.ExecContext(ctx, migration.Up)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				migration.Version, migration.Name)
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migrations: %04d_%s up: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns the ones it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	err := m.ensureTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration)
+	for _, migration := range m.Migrations {
+		known[migration.Version] = migration
+	}
+
+	var rolledBack []Migration
+	for range steps {
+		var version int
+		err := m.DB.QueryRowContext(ctx, `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1`).Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return rolledBack, err
+		}
+
+		migration, ok := known[version]
+		if !ok {
+			return rolledBack, fmt.Errorf("migrations: version %d is applied but not in this binary", version)
+		}
+
+		ran, err := m.apply(ctx, version, func(tx *sql.Tx, done bool) error {
+			if !done {
+				return errSkip
+			}
+
+			_, err := tx.ExecContext(ctx, migration.Down)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version)
+			return err
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("migrations: %04d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			rolledBack = append(rolledBack, migration)
+		}
+	}
+
+	return rolledBack, nil
+}
+
+// Status lists every known migration with the time it was applied, or nil if
+// it is pending.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	err := m.ensureTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err := rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.Migrations {
+		status := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// errSkip tells apply to roll back quietly because another instance got there
+// first.
+var errSkip = errors.New("skip")
+
+// apply runs fn in a transaction holding the migration lock. done reports
+// whether version is already recorded as applied.
+func (m *Migrator) apply(ctx context.Context, version int, fn func(tx *sql.Tx, done bool) error) (bool, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockID)
+	if err != nil {
+		return false, err
+	}
+
+	var done bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&done)
+	if err != nil {
+		return false, err
+	}
+
+	err = fn(tx, done)
+	if errors.Is(err, errSkip) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+	return err
+}
+
+// Create writes an empty up/down pair for a new migration into dir, numbered
+// after the highest version already there, and returns the file paths.
+func Create(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}), "_"))
+	if name == "" {
+		return nil, errors.New("migrations: a migration needs a name")
+	}
+
+	existing, err := Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+
+		err := os.WriteFile(path, []byte(fmt.Sprintf("-- %04d_%s %s\n", version, name, direction)), 0o644)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,166 @@
+package migrations
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, migration := range m.Migrations {
+		if migration.Version != i+1 {
+			t.Errorf("expected version %d but got %04d_%s", i+1, migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestBaselineAdoptsExistingTables(t *testing.T) {
+	m, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deployed databases already have these tables, so 0001 must not fail
+	// on them
+	for _, line := range strings.Split(m.Migrations[0].Up, "\n") {
+		if strings.HasPrefix(line, "CREATE ") && !strings.Contains(line, "IF NOT EXISTS") {
+			t.Errorf("0001 must be idempotent: %s", line)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "first" || migrations[1].Name != "second" {
+		t.Fatalf("unexpected migrations %+v", migrations)
+	}
+	if migrations[0].Up != "CREATE TABLE a ();" || migrations[0].Down != "DROP TABLE a;" {
+		t.Errorf("unexpected sql %+v", migrations[0])
+	}
+}
+
+func TestLoadRejectsBadSets(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"missing down", fstest.MapFS{
+			"0001_first.up.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{"clashing versions", fstest.MapFS{
+			"0001_first.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_first.down.sql": {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{"stray file", fstest.MapFS{
+			"README.md": {Data: []byte("hi")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	paths, err := Create(dir, "Add posters!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(paths[0]) != "0001_add_posters.up.sql" || filepath.Base(paths[1]) != "0001_add_posters.down.sql" {
+		t.Fatalf("unexpected files %v", paths)
+	}
+
+	paths, err = Create(dir, "watchlists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filepath.Base(paths[0]), "0002_") {
+		t.Errorf("expected the next version but got %v", paths)
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil || len(migrations) != 2 {
+		t.Errorf("created files don't load: %v", err)
+	}
+}
+
+// TestUpDown runs the embedded migrations against a real database; it needs
+// TEST_DATABASE_URL to point at a disposable Postgres database.
+func TestUpDown(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(m.Migrations) {
+		t.Fatalf("expected a fresh database, applied %d of %d", len(applied), len(m.Migrations))
+	}
+
+	// a second run is a no-op
+	applied, err = m.Up(t.Context())
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("expected nothing to apply but got %d, %v", len(applied), err)
+	}
+
+	rolledBack, err := m.Down(t.Context(), len(m.Migrations))
+	if err != nil || len(rolledBack) != len(m.Migrations) {
+		t.Fatalf("expected to roll everything back but got %d, %v", len(rolledBack), err)
+	}
+
+	statuses, err := m.Status(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			t.Errorf("%04d_%s still applied", s.Version, s.Name)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS movies_genres;
+DROP TABLE IF EXISTS movies;
+DROP TABLE IF EXISTS genres;
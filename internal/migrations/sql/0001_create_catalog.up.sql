@@ -0,0 +1,50 @@
+-- The original tables predate migrations and exist in every deployed
+-- database, so this must be a no-op for them: everything here is IF NOT
+-- EXISTS. On an existing install, 0001 just records the baseline and only
+-- the indexes it adds are new.
+--
+-- An install with genre names that differ only in case will fail on
+-- genres_genre_idx; merge those genres (POST /admin/genres/{id}/merge)
+-- and run the migration again.
+
+CREATE TABLE IF NOT EXISTS genres (
+    id         SERIAL PRIMARY KEY,
+    genre      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS genres_genre_idx ON genres (LOWER(genre));
+
+CREATE TABLE IF NOT EXISTS movies (
+    id          SERIAL PRIMARY KEY,
+    title       VARCHAR(512) NOT NULL,
+    release     INTEGER NOT NULL DEFAULT 0,
+    runtime     INTEGER NOT NULL DEFAULT 0,
+    mpaa        VARCHAR(10) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    imdb        REAL NOT NULL DEFAULT 0,
+    imdb_id     VARCHAR(20) NOT NULL DEFAULT '',
+    poster      VARCHAR(255) NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS movies_genres (
+    id       SERIAL PRIMARY KEY,
+    movie_id INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    genre_id INTEGER NOT NULL REFERENCES genres (id) ON DELETE CASCADE,
+    UNIQUE (movie_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS movies_genres_genre_id_idx ON movies_genres (genre_id);
+
+CREATE TABLE IF NOT EXISTS users (
+    id         SERIAL PRIMARY KEY,
+    first_name VARCHAR(255) NOT NULL,
+    last_name  VARCHAR(255) NOT NULL,
+    email      VARCHAR(255) NOT NULL UNIQUE,
+    password   VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,14 @@
+-- server-side refresh token sessions; a family is every session descended
+-- from one login, so reuse of a rotated token can revoke the lot
+CREATE TABLE IF NOT EXISTS sessions (
+    id          TEXT PRIMARY KEY,
+    family_id   TEXT NOT NULL,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    revoked_at  TIMESTAMPTZ,
+    replaced_by TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS sessions_family_id_idx ON sessions (family_id);
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    VARCHAR(50) NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
+
+-- before roles every user could reach the admin api, so existing users keep
+-- that access; on a fresh install there is nobody yet and -admin-email picks
+-- the first admin instead
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'admin' FROM users
+WHERE NOT EXISTS (SELECT 1 FROM user_roles);
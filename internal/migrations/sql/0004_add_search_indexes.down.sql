@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS movies_title_trgm_idx;
+DROP INDEX IF EXISTS movies_search_idx;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS movies_imdb_id_idx;
@@ -0,0 +1,2 @@
+-- bulk imports look movies up by imdb_id to decide between insert and update
+CREATE INDEX IF NOT EXISTS movies_imdb_id_idx ON movies (imdb_id);
@@ -0,0 +1,14 @@
+-- a user's watchlist, in the order they chose; a movie counts as watched
+-- once watched_at is set
+CREATE TABLE IF NOT EXISTS watchlist (
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    movie_id   INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    notes      TEXT NOT NULL DEFAULT '',
+    watched_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_movie_id_idx ON watchlist (movie_id);
@@ -0,0 +1,40 @@
+-- users' comments on movies and their moderation: comments wait in a queue
+-- until an admin approves them, users report abusive ones, and every
+-- decision is kept with the admin who made it
+CREATE TABLE IF NOT EXISTS comments (
+    id          SERIAL PRIMARY KEY,
+    movie_id    INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    body        TEXT NOT NULL,
+    status      VARCHAR(20) NOT NULL DEFAULT 'pending'
+                CHECK (status IN ('pending', 'approved', 'rejected', 'flagged')),
+    flag_reason TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS comments_movie_id_idx ON comments (movie_id, status);
+CREATE INDEX IF NOT EXISTS comments_status_idx ON comments (status, created_at);
+
+CREATE TABLE IF NOT EXISTS comment_reports (
+    id         SERIAL PRIMARY KEY,
+    comment_id INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason     TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (comment_id, user_id)
+);
+
+-- admin is the sub claim of the admin's token, kept as text so the record
+-- outlives the user
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id          SERIAL PRIMARY KEY,
+    comment_id  INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    admin       VARCHAR(255) NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status   VARCHAR(20) NOT NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS moderation_actions_comment_id_idx ON moderation_actions (comment_id);
@@ -0,0 +1,26 @@
+-- the people who make movies and their credits: directors, writers and
+-- actors, the last with the character they played and their billing
+CREATE TABLE IF NOT EXISTS people (
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    birth_year INTEGER NOT NULL DEFAULT 0,
+    bio        TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people (LOWER(name));
+
+CREATE TABLE IF NOT EXISTS credits (
+    id             SERIAL PRIMARY KEY,
+    movie_id       INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    person_id      INTEGER NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+    role           VARCHAR(20) NOT NULL CHECK (role IN ('director', 'writer', 'actor')),
+    character_name VARCHAR(255) NOT NULL DEFAULT '',
+    billing        INTEGER NOT NULL DEFAULT 0 CHECK (billing >= 0),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (movie_id, person_id, role, character_name)
+);
+
+CREATE INDEX IF NOT EXISTS credits_person_id_idx ON credits (person_id);
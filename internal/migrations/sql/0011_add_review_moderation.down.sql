@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS review_actions;
+DROP INDEX IF EXISTS reviews_status_idx;
+ALTER TABLE reviews DROP COLUMN IF EXISTS flag_reason;
+ALTER TABLE reviews DROP COLUMN IF EXISTS status;
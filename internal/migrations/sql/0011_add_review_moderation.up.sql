@@ -0,0 +1,19 @@
+-- reviews are screened by the comments' pre-filter: flagged reviews wait for
+-- a moderator, whose decisions are kept as they are for comments
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved'
+    CHECK (status IN ('pending', 'approved', 'rejected', 'flagged'));
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS flag_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS reviews_status_idx ON reviews (status, created_at);
+
+CREATE TABLE IF NOT EXISTS review_actions (
+    id          SERIAL PRIMARY KEY,
+    review_id   INTEGER NOT NULL REFERENCES reviews (id) ON DELETE CASCADE,
+    admin       VARCHAR(255) NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status   VARCHAR(20) NOT NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS review_actions_review_id_idx ON review_actions (review_id);
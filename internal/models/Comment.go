@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// A comment is in one of these moderation states. New comments are pending
+// unless the pre-filter flags them; only approved comments are public.
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+	CommentRejected = "rejected"
+	CommentFlagged  = "flagged"
+)
+
+// ValidCommentStatus reports whether status is one of the comment states.
+func ValidCommentStatus(status string) bool {
+	switch status {
+	case CommentPending, CommentApproved, CommentRejected, CommentFlagged:
+		return true
+	}
+	return false
+}
+
+// Comment is a user's comment on a movie. FlagReason says why it was flagged,
+// by the pre-filter or by a report, and Reports counts the abuse reports
+// against it.
+type Comment struct {
+	ID         int       `json:"id"`
+	MovieID    int       `json:"movie_id"`
+	UserID     int       `json:"user_id"`
+	Author     string    `json:"author"`
+	Body       string    `json:"body"`
+	Status     string    `json:"status"`
+	FlagReason string    `json:"flag_reason,omitempty"`
+	Reports    int       `json:"reports"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CommentReport is a user's report of an abusive comment. A user reports a
+// comment once.
+type CommentReport struct {
+	ID        int       `json:"id"`
+	CommentID int       `json:"comment_id"`
+	UserID    int       `json:"user_id"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModerationAction records an admin moving a comment, or a review, from one
+// state to another. Admin is the subject of the admin's token.
+type ModerationAction struct {
+	ID         int       `json:"id"`
+	CommentID  int       `json:"comment_id,omitempty"`
+	ReviewID   int       `json:"review_id,omitempty"`
+	Admin      string    `json:"admin"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CommentFilter describes a page of comments. A zero MovieID means every
+// movie and no Statuses means every state. Comments come newest first unless
+// OldestFirst is set, as the moderation queue wants them.
+type CommentFilter struct {
+	MovieID     int
+	Statuses    []string
+	OldestFirst bool
+	Page        int
+	PageSize    int
+}
+
+// Offset returns the number of rows to skip to reach Page.
+func (f CommentFilter) Offset() int {
+	return (f.Page - 1) * f.PageSize
+}
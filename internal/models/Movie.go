@@ -3,20 +3,37 @@ package models
 import "time"
 
 type Movie struct {
-	ID             int       `json:"id"`
-	Title          string    `json:"title"`
-	Poster         string    `json:"poster"`
-	RuntimeHours   int       `json:"runtime"`
-	RuntimeMinutes int       `json:"runtime_minutes"`
-	IMDb           float32   `json:"imdb"`
-	IMDbID         string    `json:"imdbId"`
-	Release        int       `json:"release"`
-	MPAA           string    `json:"mpaa"`
-	Description    string    `json:"description"`
-	CreatedAt      time.Time `json:"-"`
-	UpdatedAt      time.Time `json:"-"`
-	Genres         []*Genre  `json:"genres,omitempty"`
-	GenresArray    []int     `json:"genres_array,omitempty"`
+	ID             int             `json:"id"`
+	Title          string          `json:"title"`
+	Poster         string          `json:"poster"`
+	PosterVariants *PosterVariants `json:"poster_variants,omitempty"`
+	RuntimeHours   int             `json:"runtime"`
+	RuntimeMinutes int             `json:"runtime_minutes"`
+	IMDb           float32         `json:"imdb"`
+	IMDbID         string          `json:"imdbId"`
+	Release        int             `json:"release"`
+	MPAA           string          `json:"mpaa"`
+	Description    string          `json:"description"`
+	CreatedAt      time.Time       `json:"-"`
+	UpdatedAt      time.Time       `json:"-"`
+	Genres         []*Genre        `json:"genres,omitempty"`
+	GenresArray    []int           `json:"genres_array,omitempty"`
+
+	// Community is only set on the public movie listing and movie page
+	Community *CommunityRating `json:"community,omitempty"`
+
+	// InWatchlist is only set for requests made by a logged in user
+	InWatchlist *bool `json:"in_watchlist,omitempty"`
+
+	// Cast is only set on the movie page, with the TopBilledCast actors
+	Cast []*Credit `json:"cast,omitempty"`
+}
+
+// Runtime is the total runtime in minutes. Movies are read with the stored
+// runtime split into RuntimeHours and RuntimeMinutes, and written back by
+// putting the two together again.
+func (m Movie) Runtime() int {
+	return m.RuntimeHours*60 + m.RuntimeMinutes
 }
 
 type Genre struct {
@@ -26,3 +43,11 @@ type Genre struct {
 	CreatedAt time.Time `json:"-"`
 	UpdatedAt time.Time `json:"-"`
 }
+
+// GenreCount is a genre with the number of movies filed under it, as listed
+// by GET /genres.
+type GenreCount struct {
+	ID     int    `json:"id"`
+	Genre  string `json:"genre"`
+	Movies int    `json:"movie_count"`
+}
@@ -0,0 +1,39 @@
+package models
+
+// MovieFilter describes a page of the public movie listing. Zero values mean
+// "no filter" for every field except Page and PageSize.
+type MovieFilter struct {
+	Page     int
+	PageSize int
+
+	// Sort is one of the keys in MovieSortColumns; Desc flips the order.
+	Sort string
+	Desc bool
+
+	GenreID    int
+	MPAA       []string
+	YearFrom   int
+	YearTo     int
+	MinIMDb    float32
+	RuntimeMin int // in minutes
+	RuntimeMax int // in minutes
+}
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// MovieSortColumns maps the sort keys accepted by the API to their column.
+var MovieSortColumns = map[string]string{
+	"title":      "title",
+	"release":    "release",
+	"imdb":       "imdb",
+	"runtime":    "runtime",
+	"created_at": "created_at",
+}
+
+// Offset returns the number of rows to skip to reach Page.
+func (f MovieFilter) Offset() int {
+	return (f.Page - 1) * f.PageSize
+}
@@ -0,0 +1,15 @@
+package models
+
+// MovieSearchResult is a movie matched by a search, with its relevance and
+// the matching text highlighted with <mark> tags. TitleHighlight and Snippet
+// are HTML-escaped and safe to render as markup.
+type MovieSearchResult struct {
+	Movie
+	Rank           float32 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+
+	// Fuzzy is set when the result came from the typo-tolerant fallback
+	// rather than the full-text index.
+	Fuzzy bool `json:"fuzzy"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// A credit is for one of these jobs on a movie.
+const (
+	CreditDirector = "director"
+	CreditWriter   = "writer"
+	CreditActor    = "actor"
+)
+
+// CreditRoles are the jobs a credit can be for, in the order a movie's
+// credits are listed.
+var CreditRoles = []string{CreditDirector, CreditWriter, CreditActor}
+
+// ValidCreditRole reports whether role is one of CreditRoles.
+func ValidCreditRole(role string) bool {
+	for _, r := range CreditRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// TopBilledCast is how many actors GetMovie lists with a movie.
+const TopBilledCast = 5
+
+// Person is somebody who worked on movies. Filmography is only set on the
+// person's own page.
+type Person struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	BirthYear   int       `json:"birth_year,omitempty"`
+	Bio         string    `json:"bio"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+	Filmography []*Credit `json:"filmography,omitempty"`
+}
+
+// Credit is a person's job on a movie. Actors have the character they played
+// and their billing, from 1 for the top of the bill; 0 is unbilled and comes
+// last. Credits are read with the person's name and the movie's title and
+// release year.
+type Credit struct {
+	ID         int    `json:"id"`
+	MovieID    int    `json:"movie_id"`
+	MovieTitle string `json:"title,omitempty"`
+	Release    int    `json:"release,omitempty"`
+	PersonID   int    `json:"person_id"`
+	Name       string `json:"name,omitempty"`
+	Role       string `json:"role"`
+	Character  string `json:"character,omitempty"`
+	Billing    int    `json:"billing,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PosterSizes are the widths, in pixels, that posters are resized to, by the
+// name of the variant. Posters narrower than a size are not enlarged.
+var PosterSizes = map[string]int{
+	"thumbnail": 160,
+	"card":      342,
+	"full":      780,
+}
+
+// PosterVariants are resized copies of a movie's poster, for use in srcset.
+type PosterVariants struct {
+	Thumbnail PosterVariant `json:"thumbnail"`
+	Card      PosterVariant `json:"card"`
+	Full      PosterVariant `json:"full"`
+}
+
+// PosterVariant is one size of a poster as a JPEG and as a WebP image. Width
+// is the largest the image can be, as the source may be narrower.
+type PosterVariant struct {
+	Width int    `json:"width"`
+	JPEG  string `json:"jpeg"`
+	WebP  string `json:"webp"`
+}
+
+// NewPosterVariants returns the urls of the variants of the poster stored
+// under name, served from baseURL/<size>/<name without extension>.jpg and
+// .webp. It returns nil when there is no poster or no baseURL.
+func NewPosterVariants(baseURL, name string) *PosterVariants {
+	name = strings.TrimSpace(name)
+	if baseURL == "" || name == "" {
+		return nil
+	}
+
+	stem := url.PathEscape(strings.TrimSuffix(name, path.Ext(name)))
+	variant := func(size string) PosterVariant {
+		prefix := baseURL + size + "/" + stem
+		return PosterVariant{
+			Width: PosterSizes[size],
+			JPEG:  prefix + ".jpg",
+			WebP:  prefix + ".webp",
+		}
+	}
+
+	return &PosterVariants{
+		Thumbnail: variant("thumbnail"),
+		Card:      variant("card"),
+		Full:      variant("full"),
+	}
+}
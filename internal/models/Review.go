@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Ratings go from MinRating to MaxRating.
+const (
+	MinRating = 1
+	MaxRating = 10
+)
+
+// Review is a user's rating of a movie, with an optional written review in
+// Body. Every user reviews a movie at most once.
+//
+// Reviews go through the comment states: they are approved when written
+// unless the pre-filter flags them, and only approved reviews are listed.
+// The rating counts towards the community rating either way.
+type Review struct {
+	ID         int       `json:"id"`
+	MovieID    int       `json:"movie_id"`
+	UserID     int       `json:"user_id"`
+	Author     string    `json:"author"`
+	Rating     int       `json:"rating"`
+	Body       string    `json:"body"`
+	Status     string    `json:"status"`
+	FlagReason string    `json:"flag_reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ReviewFilter describes a page of reviews, like CommentFilter does for
+// comments.
+type ReviewFilter struct {
+	MovieID     int
+	Statuses    []string
+	OldestFirst bool
+	Page        int
+	PageSize    int
+}
+
+// Offset returns the number of rows to skip to reach Page.
+func (f ReviewFilter) Offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// CommunityRating is the average of the ratings users gave a movie, rounded
+// to one decimal, and the number of ratings. Average is 0 without any votes.
+type CommunityRating struct {
+	Average float32 `json:"average"`
+	Votes   int     `json:"votes"`
+}
@@ -0,0 +1,40 @@
+package models
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+const (
+	PermissionCatalogRead   = "catalog:read"
+	PermissionCatalogWrite  = "catalog:write"
+	PermissionCatalogDelete = "catalog:delete"
+
+	PermissionCommentsModerate = "comments:moderate"
+)
+
+var rolePermissions = map[string][]string{
+	RoleViewer: {PermissionCatalogRead},
+	RoleEditor: {PermissionCatalogRead, PermissionCatalogWrite},
+	RoleAdmin:  {PermissionCatalogRead, PermissionCatalogWrite, PermissionCatalogDelete, PermissionCommentsModerate},
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether any of roles grants permission.
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+
+	return false
+}
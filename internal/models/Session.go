@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Session is the server-side record of a refresh token. Every refresh token
+// issued from the same login shares a FamilyID, so that reuse of an already
+// rotated token can revoke the whole chain.
+type Session struct {
+	ID         string     `json:"id"`
+	FamilyID   string     `json:"family_id"`
+	UserID     int        `json:"user_id"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy string     `json:"replaced_by,omitempty"`
+	CreatedAt  time.Time  `json:"-"`
+}
+
+// Rotated reports whether the token has already been exchanged for a new one.
+func (s *Session) Rotated() bool {
+	return s.ReplacedBy != ""
+}
+
+// Active reports whether the session can still be used to refresh.
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
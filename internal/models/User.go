@@ -1,8 +1,8 @@
 package models
 
 import (
-	"errors"
 	"time"
+	"watch-a-movie/internal/password"
 )
 
 type User struct {
@@ -10,26 +10,16 @@ type User struct {
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"`
+	Roles     []string  `json:"roles"`
+	Disabled  bool      `json:"disabled"`
 	CreatedAt time.Time `json:"-"`
 	UpdatedAt time.Time `json:"-"`
 }
 
+// ValidatePassword checks plainText against the stored password, whichever
+// algorithm it was hashed with. Legacy cleartext rows are still accepted so
+// they can be upgraded on the next successful login.
 func (u *User) ValidatePassword(plainText string) (bool, error) {
-	// $2a$12$LQv3c1yqBWVHxkd0LHAkCOYz6TtxMQJqhN8/LewdBPj6kVKj1nHKS
-	//err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(plainText))
-	//if err != nil {
-	//	switch {
-	//	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-	//		return false, nil
-	//	default:
-	//		log.Println(err)
-	//		return false, err
-	//	}
-	//}
-	if u.Password != plainText {
-		return false, errors.New("wrong password")
-	}
-
-	return true, nil
+	return password.Verify(plainText, u.Password)
 }
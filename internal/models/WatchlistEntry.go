@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// WatchlistEntry is a movie on a user's watchlist. Entries are ordered by
+// Position, which counts from 1. Watched is read from WatchedAt, which is set
+// once the movie has been watched.
+type WatchlistEntry struct {
+	MovieID   int        `json:"movie_id"`
+	Movie     *Movie     `json:"movie,omitempty"`
+	Position  int        `json:"position"`
+	Notes     string     `json:"notes"`
+	Watched   bool       `json:"watched"`
+	WatchedAt *time.Time `json:"watched_at"`
+	AddedAt   time.Time  `json:"added_at"`
+}
@@ -0,0 +1,92 @@
+// Package moderation screens text users submit against a list of words and
+// patterns, so that matching comments wait for an admin flagged.
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Filter is a list of rules. The zero Filter, and a nil one, match nothing.
+type Filter struct {
+	rules []rule
+}
+
+type rule struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// ParseFilter reads one rule per line. A rule is either a word or phrase,
+// matched as whole words regardless of case, or a regular expression between
+// slashes, e.g. /fr[e3]{2}\s+money/, matched as written. Blank lines and lines
+// starting with # are skipped.
+func ParseFilter(r io.Reader) (*Filter, error) {
+	var f Filter
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		expr := `(?i)\b` + regexp.QuoteMeta(line) + `\b`
+		if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			expr = line[1 : len(line)-1]
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		f.rules = append(f.rules, rule{source: line, re: re})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// LoadFilter reads the rules in the file at path; see ParseFilter.
+func LoadFilter(path string) (*Filter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	f, err := ParseFilter(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Len returns the number of rules.
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.rules)
+}
+
+// Match returns the first rule, as written in the list, that text matches.
+// It returns false if text matches none.
+func (f *Filter) Match(text string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+
+	for _, r := range f.rules {
+		if r.re.MatchString(text) {
+			return r.source, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,55 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := ParseFilter(strings.NewReader(`
+# words match whole, in any case
+spoiler
+buy now
+
+/fr[e3]{2}\s+money/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 3 {
+		t.Fatalf("expected 3 rules but got %d", f.Len())
+	}
+
+	tests := []struct {
+		text string
+		rule string
+	}{
+		{"Huge SPOILER ahead", "spoiler"},
+		{"no spoilers here", ""},
+		{"Buy now, while it lasts", "buy now"},
+		{"get fr33   money fast", `/fr[e3]{2}\s+money/`},
+		{"Get Free Money", ""},
+		{"a fine movie", ""},
+	}
+
+	for _, tt := range tests {
+		rule, ok := f.Match(tt.text)
+		if rule != tt.rule || ok != (tt.rule != "") {
+			t.Errorf("%q: expected %q but got %q, %v", tt.text, tt.rule, rule, ok)
+		}
+	}
+}
+
+func TestFilterBadPattern(t *testing.T) {
+	_, err := ParseFilter(strings.NewReader("fine\n/(unclosed/\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2 but got %v", err)
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if _, ok := f.Match("anything"); ok || f.Len() != 0 {
+		t.Error("expected a nil filter to match nothing")
+	}
+}
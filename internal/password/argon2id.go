@@ -0,0 +1,125 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var errInvalidArgon2idHash = errors.New("invalid argon2id hash")
+
+type Argon2idParams struct {
+	Memory  uint32 // in KiB
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:  64 * 1024,
+	Time:    1,
+	Threads: 4,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+type Argon2id struct {
+	Params Argon2idParams
+}
+
+func NewArgon2id(params Argon2idParams) Argon2id {
+	return Argon2id{Params: params}
+}
+
+// Hash returns the hash in the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+func (a Argon2id) Hash(plainText string) (string, error) {
+	salt := make([]byte, a.Params.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(plainText), salt, a.Params.Time, a.Params.Memory, a.Params.Threads, a.Params.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		a.Params.Memory,
+		a.Params.Time,
+		a.Params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify uses the parameters stored in the encoded hash, not the ones on a,
+// so hashes made with older settings keep working.
+func (a Argon2id) Verify(plainText, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(plainText), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a Argon2id) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory < a.Params.Memory ||
+		params.Time < a.Params.Time ||
+		params.Threads < a.Params.Threads ||
+		params.KeyLen < a.Params.KeyLen
+}
+
+func isArgon2id(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errInvalidArgon2idHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return params, nil, nil, errInvalidArgon2idHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return params, nil, nil, errInvalidArgon2idHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errInvalidArgon2idHash
+	}
+	params.SaltLen = uint32(len(salt))
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, errInvalidArgon2idHash
+	}
+	params.KeyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,65 @@
+package password
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const DefaultBcryptCost = 12
+
+type Bcrypt struct {
+	Cost int
+}
+
+func NewBcrypt(cost int) Bcrypt {
+	if cost < bcrypt.MinCost {
+		cost = DefaultBcryptCost
+	}
+	return Bcrypt{Cost: cost}
+}
+
+func (b Bcrypt) Hash(plainText string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plainText), b.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (b Bcrypt) Verify(plainText, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plainText))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// NeedsRehash is true for anything that isn't a bcrypt hash at the
+// configured cost or higher.
+func (b Bcrypt) NeedsRehash(encoded string) bool {
+	if !isBcrypt(encoded) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+
+	return cost < b.Cost
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
@@ -0,0 +1,55 @@
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownAlgorithm is returned when an encoded hash or a requested hasher
+// name doesn't match any of the supported algorithms.
+var ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+
+// Hasher hashes passwords into self-describing encoded strings (algorithm and
+// parameters included), so that stored hashes can be checked and upgraded
+// independently of the hasher that is currently configured.
+type Hasher interface {
+	Hash(plainText string) (string, error)
+	Verify(plainText, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+// New returns the hasher registered under name, with its default parameters.
+func New(name string) (Hasher, error) {
+	switch strings.ToLower(name) {
+	case "bcrypt":
+		return NewBcrypt(DefaultBcryptCost), nil
+	case "argon2id", "argon2":
+		return NewArgon2id(DefaultArgon2idParams), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, name)
+	}
+}
+
+// Verify checks plainText against an encoded hash produced by any supported
+// algorithm. Values that don't look like a known encoding are treated as
+// legacy cleartext passwords and compared in constant time.
+func Verify(plainText, encoded string) (bool, error) {
+	switch {
+	case isBcrypt(encoded):
+		return Bcrypt{}.Verify(plainText, encoded)
+	case isArgon2id(encoded):
+		return Argon2id{}.Verify(plainText, encoded)
+	case strings.HasPrefix(encoded, "$"):
+		return false, ErrUnknownAlgorithm
+	default:
+		return subtle.ConstantTimeCompare([]byte(plainText), []byte(encoded)) == 1, nil
+	}
+}
+
+// IsLegacy reports whether encoded is a cleartext password from before
+// hashing was introduced.
+func IsLegacy(encoded string) bool {
+	return !strings.HasPrefix(encoded, "$")
+}
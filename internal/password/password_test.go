@@ -0,0 +1,87 @@
+package password
+
+import "testing"
+
+func TestHashers(t *testing.T) {
+	hashers := map[string]Hasher{
+		"bcrypt":   NewBcrypt(4),
+		"argon2id": NewArgon2id(Argon2idParams{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}),
+	}
+
+	for name, h := range hashers {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := h.Hash("secret123")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ok, err := Verify("secret123", encoded)
+			if err != nil || !ok {
+				t.Errorf("expected the right password to verify, got %v %v", ok, err)
+			}
+
+			ok, err = Verify("secret124", encoded)
+			if err != nil || ok {
+				t.Errorf("expected the wrong password to fail, got %v %v", ok, err)
+			}
+
+			if h.NeedsRehash(encoded) {
+				t.Error("fresh hash should not need a rehash")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weakBcrypt, _ := NewBcrypt(4).Hash("secret123")
+	weakArgon, _ := NewArgon2id(Argon2idParams{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}).Hash("secret123")
+
+	tests := []struct {
+		name    string
+		hasher  Hasher
+		encoded string
+		want    bool
+	}{
+		{"cleartext", NewBcrypt(4), "secret123", true},
+		{"cheaper bcrypt", NewBcrypt(5), weakBcrypt, true},
+		{"bcrypt to argon2id", NewArgon2id(DefaultArgon2idParams), weakBcrypt, true},
+		{"weaker argon2id", NewArgon2id(DefaultArgon2idParams), weakArgon, true},
+		{"argon2id to bcrypt", NewBcrypt(4), weakArgon, true},
+		{"same bcrypt", NewBcrypt(4), weakBcrypt, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyLegacy(t *testing.T) {
+	ok, err := Verify("secret123", "secret123")
+	if err != nil || !ok {
+		t.Errorf("expected cleartext match, got %v %v", ok, err)
+	}
+
+	ok, _ = Verify("secret12", "secret123")
+	if ok {
+		t.Error("expected cleartext mismatch")
+	}
+
+	_, err = Verify("secret123", "$scrypt$whatever")
+	if err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"bcrypt", "argon2id", "Argon2id"} {
+		if _, err := New(name); err != nil {
+			t.Errorf("New(%q): %s", name, err)
+		}
+	}
+
+	if _, err := New("md5"); err == nil {
+		t.Error("expected an error for md5")
+	}
+}
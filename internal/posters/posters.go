@@ -0,0 +1,203 @@
+// Package posters makes the resized variants of posters listed in
+// models.PosterSizes, as JPEG and WebP images, and caches them on disk.
+package posters
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/storage"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	"golang.org/x/sync/singleflight"
+
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ErrNotFound is returned for variants of posters that don't exist, and for
+// names that aren't variants at all.
+var ErrNotFound = errors.New("posters: no such poster")
+
+// jpegQuality is good enough for posters at the sizes they are shown at.
+const jpegQuality = 85
+
+// sourceExts are the extensions a poster can be stored under, in the order
+// they are tried when looking for the original of a variant.
+var sourceExts = []string{".jpg", ".jpeg", ".png", ".webp"}
+
+// Cache keeps the variants of the posters in Store under Dir, as
+// Dir/<size>/<poster name without extension>.jpg and .webp. Variants are made
+// for every size at once, either by Generate when a poster is uploaded or by
+// Variant the first time one of them is asked for.
+//
+// A cached variant is never remade, which is safe for uploaded posters as
+// their names change with their content. Clear the cache after replacing a
+// poster file in place.
+type Cache struct {
+	Dir   string
+	Store storage.PosterStore
+
+	// group makes concurrent requests for a new poster generate it once
+	group singleflight.Group
+}
+
+// path is where the variant file of size is cached.
+func (c *Cache) path(size, file string) string {
+	return filepath.Join(c.Dir, size, file)
+}
+
+// Variant returns the path of the cached file for a variant named as in
+// models.NewPosterVariants, making the variants of its poster first if
+// needed.
+func (c *Cache) Variant(ctx context.Context, size, file string) (string, error) {
+	ext := filepath.Ext(file)
+	stem := strings.TrimSuffix(file, ext)
+
+	_, ok := models.PosterSizes[size]
+	if !ok || (ext != ".jpg" && ext != ".webp") || !validStem(stem) {
+		return "", ErrNotFound
+	}
+
+	path := c.path(size, file)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	_, err, _ := c.group.Do(stem, func() (interface{}, error) {
+		data, err := c.original(ctx, stem)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.Generate(stem, data)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// original reads the poster the variants named stem are made from.
+func (c *Cache) original(ctx context.Context, stem string) ([]byte, error) {
+	for _, ext := range sourceExts {
+		data, err := c.Store.Get(ctx, stem+ext)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		return data, err
+	}
+	return nil, ErrNotFound
+}
+
+// Generate makes and caches every variant of the poster image in data, stored
+// under a name with the given stem.
+func (c *Cache) Generate(stem string, data []byte) error {
+	if !validStem(stem) {
+		return ErrNotFound
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	for size, width := range models.PosterSizes {
+		img := resize(src, width)
+
+		err := c.write(size, stem+".jpg", func(w io.Writer) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+		})
+		if err != nil {
+			return err
+		}
+
+		// nativewebp only writes lossless images, which is what there is
+		// without cgo
+		err = c.write(size, stem+".webp", func(w io.Writer) error {
+			return nativewebp.Encode(w, img, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove deletes the cached variants of the poster with the given stem.
+func (c *Cache) Remove(stem string) error {
+	if !validStem(stem) {
+		return nil
+	}
+
+	for size := range models.PosterSizes {
+		for _, ext := range []string{".jpg", ".webp"} {
+			err := os.Remove(c.path(size, stem+ext))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write encodes a variant to a temporary file and renames it into place, so
+// that a variant is either missing or complete.
+func (c *Cache) write(size, file string, encode func(io.Writer) error) error {
+	dir := filepath.Join(c.Dir, size)
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".variant-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = encode(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(dir, file))
+}
+
+// resize scales src down to width, keeping its aspect ratio, onto a white
+// background, as JPEG has no transparency. Images no wider than width keep
+// their size.
+func resize(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > width {
+		h = (h*width + w/2) / w
+		w = width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	return dst
+}
+
+// validStem reports whether stem can name a poster in the store and a file in
+// the cache.
+func validStem(stem string) bool {
+	return stem != "" && !strings.HasPrefix(stem, ".") && !strings.ContainsAny(stem, `/\`)
+}
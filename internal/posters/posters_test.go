@@ -0,0 +1,124 @@
+package posters
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"watch-a-movie/internal/storage"
+
+	"golang.org/x/image/webp"
+)
+
+func pngPoster(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, x%h, color.NRGBA{B: 200, A: 255})
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// countingStore counts the reads of a LocalStore.
+type countingStore struct {
+	storage.LocalStore
+	gets atomic.Int32
+}
+
+func (s *countingStore) Get(ctx context.Context, name string) ([]byte, error) {
+	s.gets.Add(1)
+	return s.LocalStore.Get(ctx, name)
+}
+
+func TestVariant(t *testing.T) {
+	store := &countingStore{LocalStore: storage.LocalStore{Dir: t.TempDir()}}
+	store.Put(t.Context(), "heat.png", pngPoster(t, 400, 600), "image/png")
+
+	cache := &Cache{Dir: t.TempDir(), Store: store}
+
+	// concurrent first requests make the variants once
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Variant(t.Context(), "card", "heat.webp")
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	path, err := cache.Variant(t.Context(), "thumbnail", "heat.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, err := webp.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 160 || config.Height != 240 {
+		t.Errorf("expected a 160x240 thumbnail but got %dx%d", config.Width, config.Height)
+	}
+
+	// the poster is narrower than full, which keeps its size
+	path, err = cache.Variant(t.Context(), "full", "heat.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "jpeg" || config.Width != 400 || config.Height != 600 {
+		t.Errorf("expected a 400x600 jpeg but got %s %dx%d, %v", format, config.Width, config.Height, err)
+	}
+
+	// heat.jpg, heat.jpeg and heat.png were tried once
+	if got := store.gets.Load(); got != 3 {
+		t.Errorf("expected the original to be read once but got %d reads", got)
+	}
+
+	err = cache.Remove("heat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cache.Dir, "card", "heat.webp")); !os.IsNotExist(err) {
+		t.Errorf("expected the variants to be removed but got %v", err)
+	}
+}
+
+func TestVariantNotFound(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir(), Store: storage.LocalStore{Dir: t.TempDir()}}
+
+	for _, v := range []struct{ size, file string }{
+		{"card", "missing.jpg"},
+		{"huge", "heat.jpg"},
+		{"card", "heat.png"},
+		{"card", ".jpg"},
+		{"card", "..jpg"},
+	} {
+		_, err := cache.Variant(t.Context(), v.size, v.file)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s/%s: expected ErrNotFound but got %v", v.size, v.file, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package recommend
+
+import "math"
+
+const (
+	// DefaultMinCoRaters is how many users must have rated both of two movies
+	// before their ratings say how alike they are.
+	DefaultMinCoRaters = 3
+	// DefaultWeight is the share of co-rating similarity in Hybrid.
+	DefaultWeight = 0.5
+)
+
+// CoRatings recommends movies that were rated like the ones the user liked.
+// Two movies are as alike as the adjusted cosine similarity of their ratings,
+// each rating less its user's average, over the users who rated both; movies
+// rated less alike than not count as not alike at all. Pairs rated by fewer
+// than MinCoRaters users are left out, so CoRatings recommends nothing until
+// there are enough ratings.
+type CoRatings struct {
+	MinCoRaters int
+}
+
+func (c CoRatings) Recommend(data *Data, userID, k int) []Recommendation {
+	return rank(data, userID, k, coRatingSimilarity(data, c.MinCoRaters))
+}
+
+// Hybrid recommends like Genres, with co-rating similarity blended in where
+// there are enough ratings: two movies are then Weight parts as alike as
+// CoRatings says to 1-Weight parts as alike as Genres says.
+type Hybrid struct {
+	MinCoRaters int
+	Weight      float64
+}
+
+func (h Hybrid) Recommend(data *Data, userID, k int) []Recommendation {
+	coRated := coRatingSimilarity(data, h.MinCoRaters)
+
+	return rank(data, userID, k, func(a, b int) (float64, bool) {
+		genres := jaccard(data.Genres[a], data.Genres[b])
+		if s, ok := coRated(a, b); ok {
+			return h.Weight*s + (1-h.Weight)*genres, true
+		}
+		return genres, true
+	})
+}
+
+// coRatingSimilarity returns the similarity CoRatings uses, over the ratings
+// in data.
+func coRatingSimilarity(data *Data, minCoRaters int) similarity {
+	deviations := data.deviations()
+
+	return func(a, b int) (float64, bool) {
+		da, db := deviations[a], deviations[b]
+		if len(db) < len(da) {
+			da, db = db, da
+		}
+
+		n := 0
+		var dot, normA, normB float64
+		for userID, x := range da {
+			y, ok := db[userID]
+			if !ok {
+				continue
+			}
+			n++
+			dot += x * y
+			normA += x * x
+			normB += y * y
+		}
+
+		if n < max(minCoRaters, 1) {
+			return 0, false
+		}
+		if normA == 0 || normB == 0 {
+			return 0, true
+		}
+		return max(0, dot/math.Sqrt(normA*normB)), true
+	}
+}
+
+// deviations returns every movie's ratings, by user, less that user's
+// average, worked out once if data is Cached.
+func (d *Data) deviations() map[int]map[int]float64 {
+	if d.shared == nil {
+		return ratingDeviations(d.Ratings)
+	}
+
+	d.shared.once.Do(func() {
+		d.shared.deviations = ratingDeviations(d.Ratings)
+	})
+	return d.shared.deviations
+}
+
+func ratingDeviations(byUser map[int]map[int]int) map[int]map[int]float64 {
+	deviations := make(map[int]map[int]float64)
+	for userID, ratings := range byUser {
+		var sum float64
+		for _, r := range ratings {
+			sum += float64(r)
+		}
+		mean := sum / float64(len(ratings))
+
+		for movieID, r := range ratings {
+			if deviations[movieID] == nil {
+				deviations[movieID] = make(map[int]float64)
+			}
+			deviations[movieID][userID] = float64(r) - mean
+		}
+	}
+	return deviations
+}
@@ -0,0 +1,90 @@
+package recommend
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Split holds out a fraction of every user's ratings to test recommenders
+// against, and returns the rest as data to recommend from. The held-out
+// ratings are picked at random from seed, so a seed always gives the same
+// split. Users keep at least one rating, and users with a single rating keep
+// it; genres and watchlists are shared with data.
+func Split(data *Data, fraction float64, seed uint64) (*Data, map[int]map[int]int) {
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	train := &Data{
+		Genres:     data.Genres,
+		Ratings:    make(map[int]map[int]int, len(data.Ratings)),
+		Watchlists: data.Watchlists,
+	}
+	test := make(map[int]map[int]int)
+
+	for _, userID := range sortedKeys(data.Ratings) {
+		ratings := data.Ratings[userID]
+		movies := sortedKeys(ratings)
+		rng.Shuffle(len(movies), func(i, j int) { movies[i], movies[j] = movies[j], movies[i] })
+
+		n := int(math.Round(fraction * float64(len(movies))))
+		n = min(max(n, 1), len(movies)-1)
+
+		train.Ratings[userID] = make(map[int]int)
+		for i, movieID := range movies {
+			if i < n {
+				if test[userID] == nil {
+					test[userID] = make(map[int]int)
+				}
+				test[userID][movieID] = ratings[movieID]
+			} else {
+				train.Ratings[userID][movieID] = ratings[movieID]
+			}
+		}
+	}
+
+	return train, test
+}
+
+// Evaluation is how well a recommender did against held-out ratings.
+type Evaluation struct {
+	K int `json:"k"`
+	// Users counts the users it was tested on: those who liked a held-out
+	// movie and liked something else to go on.
+	Users int `json:"users"`
+	// Precision is the share of the K movies recommended to each user that
+	// the user liked in the held-out ratings, averaged over Users.
+	Precision float64 `json:"precision"`
+}
+
+// Evaluate measures the precision at k of r recommending from train against
+// the ratings held out in test.
+func Evaluate(r Recommender, train *Data, test map[int]map[int]int, k int) Evaluation {
+	eval := Evaluation{K: k}
+
+	var sum float64
+	for _, userID := range sortedKeys(test) {
+		relevant := make(map[int]bool)
+		for movieID, rating := range test[userID] {
+			if rating >= LikedRating {
+				relevant[movieID] = true
+			}
+		}
+		if len(relevant) == 0 || len(liked(train, userID)) == 0 {
+			continue
+		}
+
+		hits := 0
+		for _, rec := range r.Recommend(train, userID, k) {
+			if relevant[rec.MovieID] {
+				hits++
+			}
+		}
+
+		eval.Users++
+		sum += float64(hits) / float64(k)
+	}
+
+	if eval.Users > 0 {
+		eval.Precision = sum / float64(eval.Users)
+	}
+	return eval
+}
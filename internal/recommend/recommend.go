@@ -0,0 +1,198 @@
+// Package recommend suggests movies to users from the ones they liked: the
+// movies they rated highly or put on their watchlist.
+package recommend
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// LikedRating is the lowest rating that counts as liking a movie.
+const LikedRating = 7
+
+// maxBecause caps the liked movies a recommendation is explained by.
+const maxBecause = 3
+
+// Data is what recommenders work from: a snapshot of the catalog's genres and
+// of the users' ratings and watchlists.
+type Data struct {
+	// Genres has the genre ids of every movie that can be recommended.
+	Genres map[int][]int
+	// Ratings are by user, then by movie.
+	Ratings map[int]map[int]int
+	// Watchlists have the movies on each user's watchlist.
+	Watchlists map[int][]int
+
+	// shared is what Cached works out once from Ratings, for every copy
+	shared *shared
+}
+
+type shared struct {
+	once       sync.Once
+	deviations map[int]map[int]float64
+}
+
+// Cached returns a copy of data that works out what recommenders need from
+// Ratings the first time it is needed, and keeps it for the copy and for the
+// copies WithWatchlists makes of it. Changes to Genres or Ratings afterwards
+// aren't seen; they call for a new Cached copy.
+func (d *Data) Cached() *Data {
+	c := *d
+	c.shared = &shared{}
+	return &c
+}
+
+// WithWatchlists returns a copy of data with other watchlists, keeping what
+// Cached worked out.
+func (d *Data) WithWatchlists(watchlists map[int][]int) *Data {
+	c := *d
+	c.Watchlists = watchlists
+	return &c
+}
+
+// Recommendation is a movie recommended to a user. Because has the movies the
+// user liked that it is most like, most alike first.
+type Recommendation struct {
+	MovieID int     `json:"movie_id"`
+	Score   float64 `json:"score"`
+	Because []int   `json:"because"`
+}
+
+// Recommender recommends up to k movies to a user, best first, leaving out
+// the movies the user rated or put on their watchlist. Recommenders are
+// deterministic: the same data gives the same recommendations.
+type Recommender interface {
+	Recommend(data *Data, userID, k int) []Recommendation
+}
+
+// Names are the recommenders New knows, the default first.
+var Names = []string{"hybrid", "genres", "co-ratings"}
+
+// New returns the recommender called name, one of Names, with its defaults.
+func New(name string) (Recommender, error) {
+	switch name {
+	case "hybrid":
+		return Hybrid{MinCoRaters: DefaultMinCoRaters, Weight: DefaultWeight}, nil
+	case "genres":
+		return Genres{}, nil
+	case "co-ratings":
+		return CoRatings{MinCoRaters: DefaultMinCoRaters}, nil
+	}
+	return nil, fmt.Errorf("unknown recommender %q", name)
+}
+
+// Genres recommends movies that share genres with the ones the user liked. Two
+// movies are as alike as the Jaccard index of their genres: the genres they
+// share over the genres either has.
+type Genres struct{}
+
+func (Genres) Recommend(data *Data, userID, k int) []Recommendation {
+	return rank(data, userID, k, func(a, b int) (float64, bool) {
+		return jaccard(data.Genres[a], data.Genres[b]), true
+	})
+}
+
+// jaccard returns the Jaccard index of two sets of ids, 0 if both are empty.
+func jaccard(a, b []int) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for _, id := range a {
+		if slices.Contains(b, id) {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// similarity says how alike two movies are, from 0 to 1, and whether it can
+// tell at all.
+type similarity func(a, b int) (float64, bool)
+
+// rank scores every movie the user hasn't rated or put on their watchlist by
+// its average similarity to the movies they liked, and returns the best k.
+// Movies similarity can't tell about for any liked movie, and movies like none
+// of them, are left out.
+func rank(data *Data, userID, k int, sim similarity) []Recommendation {
+	seeds := liked(data, userID)
+	if len(seeds) == 0 || k < 1 {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	for id := range data.Ratings[userID] {
+		seen[id] = true
+	}
+	for _, id := range data.Watchlists[userID] {
+		seen[id] = true
+	}
+
+	type match struct {
+		id    int
+		score float64
+	}
+
+	var recs []Recommendation
+	for _, id := range sortedKeys(data.Genres) {
+		if seen[id] {
+			continue
+		}
+
+		var total float64
+		var matches []match
+		for _, seed := range seeds {
+			s, ok := sim(id, seed)
+			if ok && s > 0 {
+				total += s
+				matches = append(matches, match{seed, s})
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+		because := []int{}
+		for _, m := range matches[:min(maxBecause, len(matches))] {
+			because = append(because, m.id)
+		}
+
+		recs = append(recs, Recommendation{MovieID: id, Score: total / float64(len(seeds)), Because: because})
+	}
+
+	// ties stay in movie id order
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Score > recs[j].Score })
+	return recs[:min(k, len(recs))]
+}
+
+// liked returns the movies the user rated at least LikedRating or put on
+// their watchlist, in id order.
+func liked(data *Data, userID int) []int {
+	var ids []int
+	for id, rating := range data.Ratings[userID] {
+		if rating >= LikedRating {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range data.Watchlists[userID] {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+	return ids
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,209 @@
+package recommend
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// catalog has two dramas that are also crime movies, a drama and two
+// comedies, and a movie without genres.
+var catalog = map[int][]int{
+	1: {1, 2},
+	2: {1, 2},
+	3: {1},
+	4: {3},
+	5: {3},
+	6: {},
+}
+
+func movieIDs(recs []Recommendation) []int {
+	ids := []int{}
+	for _, r := range recs {
+		ids = append(ids, r.MovieID)
+	}
+	return ids
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		a, b     []int
+		expected float64
+	}{
+		{[]int{1, 2}, []int{1, 2}, 1},
+		{[]int{1, 2}, []int{1}, 0.5},
+		{[]int{1, 2}, []int{2, 3}, 1.0 / 3},
+		{[]int{1}, []int{3}, 0},
+		{nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		if got := jaccard(tt.a, tt.b); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("jaccard(%v, %v): expected %f but got %f", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
+
+func TestGenres(t *testing.T) {
+	data := &Data{
+		Genres:     catalog,
+		Ratings:    map[int]map[int]int{1: {1: 9, 4: 2}},
+		Watchlists: map[int][]int{},
+	}
+
+	recs := Genres{}.Recommend(data, 1, 10)
+	if got := movieIDs(recs); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Fatalf("expected movies 2, 3 but got %v", got)
+	}
+	if recs[0].Score != 1 || !reflect.DeepEqual(recs[0].Because, []int{1}) {
+		t.Errorf("unexpected recommendation %+v", recs[0])
+	}
+
+	// a watchlist counts as liking, and keeps its movies out
+	data.Watchlists[1] = []int{5}
+	if got := movieIDs(Genres{}.Recommend(data, 1, 10)); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("expected movies 2, 3 but got %v", got)
+	}
+	data.Ratings[1] = map[int]int{1: 9}
+	data.Watchlists[1] = []int{4}
+	// scores average over everything liked; ties go by id
+	if got := movieIDs(Genres{}.Recommend(data, 1, 2)); !reflect.DeepEqual(got, []int{2, 5}) {
+		t.Errorf("expected movies 2, 5 but got %v", got)
+	}
+	recs = Genres{}.Recommend(data, 1, 10)
+	if last := recs[len(recs)-1]; last.MovieID != 3 || last.Score != 0.25 {
+		t.Errorf("expected the drama last at 0.25 but got %+v", last)
+	}
+
+	if recs := (Genres{}).Recommend(data, 2, 10); len(recs) != 0 {
+		t.Errorf("expected nothing for a user without ratings but got %v", movieIDs(recs))
+	}
+}
+
+// coRated has users 2 and 3 like the comedies and not movie 1, user 4 the
+// other way around, and user 1 like comedy 4.
+func coRated() *Data {
+	return &Data{
+		Genres: catalog,
+		Ratings: map[int]map[int]int{
+			1: {4: 9, 3: 5},
+			2: {4: 9, 5: 9, 1: 3},
+			3: {4: 8, 5: 8, 1: 2},
+			4: {4: 2, 5: 3, 1: 9},
+		},
+	}
+}
+
+func TestCoRatings(t *testing.T) {
+	data := coRated()
+
+	sim := coRatingSimilarity(data, 3)
+	if s, ok := sim(4, 5); !ok || s < 0.9 {
+		t.Errorf("expected the comedies to be alike but got %f, %v", s, ok)
+	}
+	if s, ok := sim(1, 4); !ok || s != 0 {
+		t.Errorf("expected movies 1 and 4 not to be alike but got %f, %v", s, ok)
+	}
+	if _, ok := sim(3, 4); ok {
+		t.Error("expected too few ratings of movie 3 to tell")
+	}
+
+	if got := movieIDs((CoRatings{MinCoRaters: 3}).Recommend(data, 1, 10)); !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("expected movie 5 but got %v", got)
+	}
+	if got := (CoRatings{MinCoRaters: 5}).Recommend(data, 1, 10); len(got) != 0 {
+		t.Errorf("expected nothing without enough ratings but got %v", movieIDs(got))
+	}
+}
+
+func TestHybrid(t *testing.T) {
+	data := coRated()
+	data.Ratings[1] = map[int]int{4: 9, 2: 8}
+
+	recs := Hybrid{MinCoRaters: 3, Weight: 0.5}.Recommend(data, 1, 10)
+	if got := movieIDs(recs); !reflect.DeepEqual(got, []int{1, 5, 3}) {
+		t.Fatalf("expected movies 1, 5, 3 but got %v", got)
+	}
+
+	// movie 1 is like movie 2 by genres, with too few ratings to say more;
+	// movie 5 is like movie 4 by genres and, not quite as much, by ratings
+	if recs[0].Score != 0.5 || !reflect.DeepEqual(recs[0].Because, []int{2}) {
+		t.Errorf("unexpected recommendation %+v", recs[0])
+	}
+	s, _ := coRatingSimilarity(data, 3)(4, 5)
+	if expected := (0.5*s + 0.5) / 2; math.Abs(recs[1].Score-expected) > 1e-9 {
+		t.Errorf("expected movie 5 to score %f but got %f", expected, recs[1].Score)
+	}
+}
+
+func TestCached(t *testing.T) {
+	data := coRated()
+	cached := data.Cached()
+
+	h := Hybrid{MinCoRaters: 3, Weight: 0.5}
+	if got, want := h.Recommend(cached, 1, 10), h.Recommend(data, 1, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v but got %+v", want, got)
+	}
+
+	// the ratings are worked out once, so later changes to them aren't seen
+	data.Ratings[2][4] = 1
+	listed := cached.WithWatchlists(map[int][]int{1: {5}})
+	if !reflect.DeepEqual(listed.deviations(), cached.deviations()) || listed.deviations()[4][2] == data.deviations()[4][2] {
+		t.Error("expected the copy to keep the cached deviations")
+	}
+	if got := movieIDs((CoRatings{MinCoRaters: 3}).Recommend(listed, 1, 10)); len(got) != 0 {
+		t.Errorf("expected nothing but the watchlisted movie 5 but got %v", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range Names {
+		if _, err := New(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := New("magic"); err == nil {
+		t.Error("expected an error for an unknown recommender")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	ratings := map[int]map[int]int{1: {}, 2: {1: 5}}
+	for i := 1; i <= 10; i++ {
+		ratings[1][i] = i
+	}
+	data := &Data{Genres: catalog, Ratings: ratings}
+
+	train, test := Split(data, 0.2, 42)
+	if len(test[1]) != 2 || len(train.Ratings[1]) != 8 {
+		t.Errorf("expected 2 of 10 ratings to be held out but got %v", test[1])
+	}
+	for id := range test[1] {
+		if _, ok := train.Ratings[1][id]; ok {
+			t.Errorf("movie %d is both held out and kept", id)
+		}
+	}
+	if len(test[2]) != 0 || len(train.Ratings[2]) != 1 {
+		t.Errorf("expected a single rating to be kept but got %v", test[2])
+	}
+
+	again, _ := Split(data, 0.2, 42)
+	if !reflect.DeepEqual(again.Ratings, train.Ratings) {
+		t.Error("expected the same split from the same seed")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	train := &Data{Genres: catalog, Ratings: map[int]map[int]int{1: {1: 9}, 2: {4: 3}}}
+	test := map[int]map[int]int{
+		1: {2: 8, 5: 9},
+		// user 2 liked nothing to go on, user 3 nothing held out
+		2: {5: 9},
+		3: {1: 2},
+	}
+
+	eval := Evaluate(Genres{}, train, test, 2)
+	if eval != (Evaluation{K: 2, Users: 1, Precision: 0.5}) {
+		t.Errorf("unexpected evaluation %+v", eval)
+	}
+}
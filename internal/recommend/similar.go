@@ -0,0 +1,148 @@
+package recommend
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// yearWindow is how many years apart two movies can be released and still
+// count as close at all.
+const yearWindow = 20
+
+// mpaaOrder is the MPAA ratings from the mildest; neighbouring ratings are
+// half compatible.
+var mpaaOrder = []string{"G", "PG", "PG-13", "R", "NC-17"}
+
+// Features are what Similar compares movies by.
+type Features struct {
+	ID     int
+	Genres []int
+	Year   int
+	MPAA   string
+	Rating float32 // IMDb, out of 10
+}
+
+// Weights say how much each feature counts in Similar. Only their
+// proportions matter.
+type Weights struct {
+	Genres float64 `json:"genres"`
+	Year   float64 `json:"year"`
+	MPAA   float64 `json:"mpaa"`
+	Rating float64 `json:"rating"`
+}
+
+// DefaultWeights let shared genres count most.
+var DefaultWeights = Weights{Genres: 0.5, Year: 0.2, MPAA: 0.1, Rating: 0.2}
+
+// ParseWeights reads weights written as comma separated key=value pairs, with
+// keys genres, year, mpaa and rating, e.g. "genres=0.6,year=0.4". Features
+// left out weigh nothing.
+func ParseWeights(s string) (Weights, error) {
+	var w Weights
+	fields := map[string]*float64{"genres": &w.Genres, "year": &w.Year, "mpaa": &w.MPAA, "rating": &w.Rating}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		dst, known := fields[strings.TrimSpace(key)]
+		if !ok || !known {
+			return w, fmt.Errorf("bad weight %q: expected genres, year, mpaa or rating=number", pair)
+		}
+
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) {
+			return w, fmt.Errorf("bad weight %q: must be a positive number", pair)
+		}
+		*dst = f
+	}
+
+	if w.sum() == 0 {
+		return w, fmt.Errorf("weights %q add up to nothing", s)
+	}
+	return w, nil
+}
+
+func (w Weights) sum() float64 {
+	return w.Genres + w.Year + w.MPAA + w.Rating
+}
+
+func (w Weights) String() string {
+	return fmt.Sprintf("genres=%g,year=%g,mpaa=%g,rating=%g", w.Genres, w.Year, w.MPAA, w.Rating)
+}
+
+// Match is a movie and how alike it is to another, from 0 to 1.
+type Match struct {
+	MovieID int
+	Score   float64
+}
+
+// Similar ranks the movies in catalog other than movie by how alike they are
+// to it and returns the k most alike, leaving out any not alike at all. Ties
+// go by movie id. Two movies are as alike as the weighted average of:
+//
+//	genres  the Jaccard index of their genres
+//	year    1 for the same year, down to 0 at yearWindow years apart
+//	mpaa    1 for the same rating, 0.5 for neighbouring ones, else 0
+//	rating  1 for the same IMDb rating, down to 0 at 10 points apart
+//
+// Unknown years, MPAA ratings and IMDb ratings, zero or empty, match nothing.
+func Similar(movie Features, catalog []Features, w Weights, k int) []Match {
+	total := w.sum()
+	if total == 0 || k < 1 {
+		return nil
+	}
+
+	var matches []Match
+	for _, other := range catalog {
+		if other.ID == movie.ID {
+			continue
+		}
+
+		score := w.Genres*jaccard(movie.Genres, other.Genres) +
+			w.Year*yearProximity(movie.Year, other.Year) +
+			w.MPAA*mpaaCompatibility(movie.MPAA, other.MPAA) +
+			w.Rating*ratingCloseness(movie.Rating, other.Rating)
+		if score > 0 {
+			matches = append(matches, Match{MovieID: other.ID, Score: score / total})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].MovieID < matches[j].MovieID
+	})
+	return matches[:min(k, len(matches))]
+}
+
+func yearProximity(a, b int) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	d := math.Abs(float64(a - b))
+	return max(0, 1-d/yearWindow)
+}
+
+func mpaaCompatibility(a, b string) float64 {
+	i, j := slices.Index(mpaaOrder, a), slices.Index(mpaaOrder, b)
+	switch {
+	case i < 0 || j < 0:
+		return 0
+	case i == j:
+		return 1
+	case i-j == 1 || j-i == 1:
+		return 0.5
+	}
+	return 0
+}
+
+func ratingCloseness(a, b float32) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(0, 1-math.Abs(float64(a-b))/10)
+}
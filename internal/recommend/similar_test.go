@@ -0,0 +1,86 @@
+package recommend
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights(" genres=0.6, year=0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != (Weights{Genres: 0.6, Year: 0.4}) {
+		t.Errorf("unexpected weights %+v", w)
+	}
+
+	again, err := ParseWeights(DefaultWeights.String())
+	if err != nil || again != DefaultWeights {
+		t.Errorf("expected the default weights back but got %+v, %v", again, err)
+	}
+
+	for _, s := range []string{"", "genres", "budget=1", "year=-1", "year=x", "genres=0,year=0"} {
+		if _, err := ParseWeights(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestFeatureSimilarities(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		{"same year", yearProximity(1995, 1995), 1},
+		{"ten years", yearProximity(1995, 2005), 0.5},
+		{"far apart", yearProximity(1950, 2020), 0},
+		{"unknown year", yearProximity(0, 0), 0},
+		{"same mpaa", mpaaCompatibility("R", "R"), 1},
+		{"next mpaa", mpaaCompatibility("PG-13", "R"), 0.5},
+		{"far mpaa", mpaaCompatibility("G", "R"), 0},
+		{"unknown mpaa", mpaaCompatibility("", ""), 0},
+		{"same rating", ratingCloseness(8, 8), 1},
+		{"close rating", ratingCloseness(8, 7), 0.9},
+		{"unknown rating", ratingCloseness(0, 0), 0},
+	}
+
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.expected) > 1e-6 {
+			t.Errorf("%s: expected %f but got %f", tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	heat := Features{ID: 1, Genres: []int{1, 2}, Year: 1995, MPAA: "R", Rating: 8.3}
+	catalog := []Features{
+		heat,
+		{ID: 2, Genres: []int{1, 2}, Year: 1995, MPAA: "R", Rating: 8.3},  // the same
+		{ID: 3, Genres: []int{1}, Year: 2015, MPAA: "PG-13", Rating: 7.3}, // some of it
+		{ID: 4, Genres: []int{3}, Year: 1950, MPAA: "G"},                  // nothing
+		{ID: 5, Genres: []int{1}, Year: 2015, MPAA: "PG-13", Rating: 7.3}, // a tie with 3
+		{ID: 6, Genres: []int{1, 2}, Year: 1995, MPAA: "NC-17", Rating: 8.3},
+	}
+
+	matches := Similar(heat, catalog, DefaultWeights, 10)
+	var ids []int
+	for _, m := range matches {
+		ids = append(ids, m.MovieID)
+	}
+	if len(ids) != 4 || ids[0] != 2 || ids[1] != 6 || ids[2] != 3 || ids[3] != 5 {
+		t.Fatalf("expected movies 2, 6, 3, 5 but got %v", ids)
+	}
+	if matches[0].Score != 1 {
+		t.Errorf("expected the same movie to score 1 but got %f", matches[0].Score)
+	}
+	if expected := (0.5*0.5 + 0.2*0 + 0.1*0.5 + 0.2*0.9); math.Abs(matches[2].Score-expected) > 1e-6 {
+		t.Errorf("expected movie 3 to score %f but got %f", expected, matches[2].Score)
+	}
+
+	// genres alone
+	matches = Similar(heat, catalog, Weights{Genres: 1}, 2)
+	if len(matches) != 2 || matches[0].MovieID != 2 || matches[1].MovieID != 6 {
+		t.Errorf("unexpected matches %+v", matches)
+	}
+}
@@ -0,0 +1,23 @@
+package dbrepo
+
+import "watch-a-movie/internal/models"
+
+// reportReason is the flag reason of a comment flagged by a report.
+func reportReason(reason string) string {
+	if reason == "" {
+		return "reported"
+	}
+	return "reported: " + reason
+}
+
+// moderatedFlagReason is the flag reason a comment keeps after action: the
+// admin's note if the admin flagged it, and none otherwise.
+func moderatedFlagReason(action models.ModerationAction) string {
+	if action.ToStatus != models.CommentFlagged {
+		return ""
+	}
+	if action.Note == "" {
+		return "flagged by " + action.Admin
+	}
+	return action.Note
+}
@@ -0,0 +1,144 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+// testComments has users 1 and 2 comment on movies 1 and 2, and user 2
+// report and user 1 moderate them.
+func testComments(t *testing.T, repo repository.DatabaseRepo) {
+	t.Helper()
+	ctx := t.Context()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	insert := func(movie, user int, status string, age time.Duration) int {
+		t.Helper()
+		at := now.Add(-age)
+		id, err := repo.InsertComment(ctx, models.Comment{MovieID: movie, UserID: user, Body: "hm", Status: status, CreatedAt: at, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	oldest := insert(1, 1, models.CommentPending, 3*time.Hour)
+	flagged := insert(1, 2, models.CommentFlagged, 2*time.Hour)
+	approved := insert(1, 2, models.CommentApproved, time.Hour)
+	insert(2, 1, models.CommentApproved, 0)
+
+	queue, total, err := repo.ListComments(ctx, models.CommentFilter{
+		Statuses:    []string{models.CommentPending, models.CommentFlagged},
+		OldestFirst: true,
+		Page:        1,
+		PageSize:    10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(queue) != 2 || queue[0].ID != oldest || queue[1].ID != flagged {
+		t.Errorf("expected the queue to be %d, %d but got %d: %+v", oldest, flagged, total, queue)
+	}
+
+	public, total, _ := repo.ListComments(ctx, models.CommentFilter{MovieID: 1, Statuses: []string{models.CommentApproved}, Page: 1, PageSize: 10})
+	if total != 1 || public[0].ID != approved {
+		t.Errorf("expected only comment %d but got %+v", approved, public)
+	}
+	all, total, _ := repo.ListComments(ctx, models.CommentFilter{Page: 2, PageSize: 3})
+	if total != 4 || len(all) != 1 || all[0].ID != oldest {
+		t.Errorf("expected the oldest of 4 comments on page 2 but got %d: %+v", total, all)
+	}
+
+	// a report flags an approved comment
+	err = repo.ReportComment(ctx, models.CommentReport{CommentID: approved, UserID: 1, Reason: "rude", CreatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.ReportComment(ctx, models.CommentReport{CommentID: approved, UserID: 1, CreatedAt: now})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate but got %v", err)
+	}
+	err = repo.ReportComment(ctx, models.CommentReport{CommentID: 999, UserID: 1, CreatedAt: now})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+
+	comment, err := repo.GetComment(ctx, approved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment.Status != models.CommentFlagged || comment.FlagReason != "reported: rude" || comment.Reports != 1 || comment.Author == "" {
+		t.Errorf("unexpected comment %+v", comment)
+	}
+	reports, err := repo.CommentReports(ctx, approved)
+	if err != nil || len(reports) != 1 || reports[0].Reason != "rude" || reports[0].UserID != 1 {
+		t.Errorf("unexpected reports %+v, %v", reports, err)
+	}
+
+	err = repo.ModerateComment(ctx, models.ModerationAction{CommentID: approved, Admin: "1", ToStatus: models.CommentApproved, Note: "fair", CreatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.ModerateComment(ctx, models.ModerationAction{CommentID: oldest, Admin: "1", ToStatus: models.CommentRejected, CreatedAt: now.Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.ModerateComment(ctx, models.ModerationAction{CommentID: 999, Admin: "1", ToStatus: models.CommentRejected, CreatedAt: now})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+
+	comment, _ = repo.GetComment(ctx, approved)
+	if comment.Status != models.CommentApproved || comment.FlagReason != "" {
+		t.Errorf("expected the comment to be approved again but got %+v", comment)
+	}
+
+	actions, total, err := repo.ModerationActions(ctx, 0, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || actions[0].CommentID != oldest || actions[0].FromStatus != models.CommentPending || actions[0].ToStatus != models.CommentRejected {
+		t.Errorf("expected the rejection first but got %d: %+v", total, actions)
+	}
+	actions, total, _ = repo.ModerationActions(ctx, approved, 1, 10)
+	if total != 1 || actions[0].Admin != "1" || actions[0].FromStatus != models.CommentFlagged || actions[0].Note != "fair" {
+		t.Errorf("unexpected actions %+v", actions)
+	}
+
+	// deleting a movie deletes its comments
+	err = repo.DeleteMovie(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetComment(ctx, approved); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+	if _, total, _ := repo.ModerationActions(ctx, 0, 1, 10); total != 0 {
+		t.Errorf("expected the actions to go with the comments but got %d", total)
+	}
+}
+
+func TestMemoryDBRepoComments(t *testing.T) {
+	repo := NewMemoryDBRepo()
+	repo.Seed(Fixtures{
+		Movies: []models.Movie{{Title: "One"}, {Title: "Two"}},
+		Users:  []models.User{{FirstName: "Ada", Email: "a@example.com"}, {FirstName: "Bob", Email: "b@example.com"}},
+	})
+
+	testComments(t, repo)
+}
+
+func TestSQLiteDBRepoComments(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	_, err := repo.InsertUser(t.Context(), models.User{FirstName: "Bob", LastName: "Builder", Email: "b@example.com", Password: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testComments(t, repo)
+}
@@ -0,0 +1,29 @@
+package dbrepo
+
+import (
+	"errors"
+	"watch-a-movie/internal/repository"
+
+	"github.com/jackc/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// uniqueViolation is the Postgres error code for a unique_violation.
+const uniqueViolation = "23505"
+
+// translate turns driver errors the handlers care about into repository
+// errors, so a duplicate key is reported the same way by every backend.
+func translate(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return repository.ErrDuplicate
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return repository.ErrDuplicate
+	}
+
+	return err
+}
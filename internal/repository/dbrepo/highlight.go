@@ -0,0 +1,28 @@
+package dbrepo
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Search highlights are returned as HTML, but titles and descriptions are
+// free text typed in by editors. Matches are therefore delimited with control
+// characters first, and only turned into <mark> tags once the text around
+// them has been escaped.
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+)
+
+var markTags = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// markup escapes s as HTML and turns the match delimiters into <mark> tags.
+func markup(s string) string {
+	return markTags.Replace(html.EscapeString(s))
+}
+
+// highlight marks every match of re in s and escapes the rest.
+func highlight(s string, re *regexp.Regexp) string {
+	return markup(re.ReplaceAllString(s, markStart+"$0"+markStop))
+}
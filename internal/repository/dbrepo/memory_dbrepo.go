@@ -0,0 +1,1648 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+// MemoryDBRepo is a DatabaseRepo that keeps everything in memory. It is safe
+// for concurrent use and is meant for tests and demos; nothing is persisted.
+//
+// Movies are stored the way the movies table stores them: RuntimeHours holds
+// the total runtime in minutes and Poster holds the bare file name.
+type MemoryDBRepo struct {
+	mu   *sync.RWMutex
+	data *memoryData
+
+	// PosterBaseURL and PosterVariantsURL make poster urls, as in
+	// PostgresDBRepo
+	PosterBaseURL     string
+	PosterVariantsURL string
+
+	// inTx is set on the copy handed to WithTx callbacks, which already
+	// holds the write lock of the repo it was made from
+	inTx bool
+}
+
+type memoryData struct {
+	movies      map[int]models.Movie
+	genres      map[int]models.Genre
+	movieGenres map[int][]int
+	users       map[int]models.User
+	userRoles   map[int][]string
+	sessions    map[string]models.Session
+
+	// watchlists are in order, by user; entries have no Movie or Position
+	watchlists map[int][]models.WatchlistEntry
+
+	// reviews are by id, with Author left empty; reviewActions are in the
+	// order they were taken
+	reviews       map[int]models.Review
+	reviewActions []models.ModerationAction
+
+	// comments and reports are by id, comments with Author and Reports left
+	// empty; moderationActions are in the order they were taken
+	comments          map[int]models.Comment
+	commentReports    map[int]models.CommentReport
+	moderationActions []models.ModerationAction
+
+	// people and credits are by id, people without a Filmography and credits
+	// without the movie or person's name
+	people  map[int]models.Person
+	credits map[int]models.Credit
+
+	nextMovieID   int
+	nextGenreID   int
+	nextUserID    int
+	nextReviewID  int
+	nextCommentID int
+	nextReportID  int
+	nextActionID  int
+	nextPersonID  int
+	nextCreditID  int
+
+	nextReviewActionID int
+}
+
+// Fixtures seeds a MemoryDBRepo. IDs of zero are assigned automatically, and
+// a movie's GenresArray decides which genres it is linked to.
+type Fixtures struct {
+	Genres []models.Genre
+	Movies []models.Movie
+	Users  []models.User
+}
+
+func NewMemoryDBRepo() *MemoryDBRepo {
+	return &MemoryDBRepo{
+		mu: &sync.RWMutex{},
+		data: &memoryData{
+			movies:         make(map[int]models.Movie),
+			genres:         make(map[int]models.Genre),
+			movieGenres:    make(map[int][]int),
+			users:          make(map[int]models.User),
+			userRoles:      make(map[int][]string),
+			sessions:       make(map[string]models.Session),
+			watchlists:     make(map[int][]models.WatchlistEntry),
+			reviews:        make(map[int]models.Review),
+			comments:       make(map[int]models.Comment),
+			commentReports: make(map[int]models.CommentReport),
+			people:         make(map[int]models.Person),
+			credits:        make(map[int]models.Credit),
+			nextMovieID:    1,
+			nextGenreID:    1,
+			nextUserID:     1,
+			nextReviewID:   1,
+			nextCommentID:  1,
+			nextReportID:   1,
+			nextActionID:   1,
+			nextPersonID:   1,
+			nextCreditID:   1,
+
+			nextReviewActionID: 1,
+		},
+	}
+}
+
+// Seed loads fixtures into the repo, on top of whatever is already there.
+func (m *MemoryDBRepo) Seed(f Fixtures) {
+	m.lock()
+	defer m.unlock()
+
+	d := m.data
+	for _, g := range f.Genres {
+		if g.ID == 0 {
+			g.ID = d.nextGenreID
+		}
+		d.nextGenreID = max(d.nextGenreID, g.ID+1)
+		d.genres[g.ID] = g
+	}
+
+	for _, mv := range f.Movies {
+		if mv.ID == 0 {
+			mv.ID = d.nextMovieID
+		}
+		d.nextMovieID = max(d.nextMovieID, mv.ID+1)
+		d.movieGenres[mv.ID] = append([]int(nil), mv.GenresArray...)
+		mv.Genres, mv.GenresArray = nil, nil
+		d.movies[mv.ID] = mv
+	}
+
+	for _, u := range f.Users {
+		if u.ID == 0 {
+			u.ID = d.nextUserID
+		}
+		d.nextUserID = max(d.nextUserID, u.ID+1)
+		d.userRoles[u.ID] = append([]string(nil), u.Roles...)
+		u.Roles = nil
+		d.users[u.ID] = u
+	}
+}
+
+func (m *MemoryDBRepo) lock() {
+	if !m.inTx {
+		m.mu.Lock()
+	}
+}
+
+func (m *MemoryDBRepo) unlock() {
+	if !m.inTx {
+		m.mu.Unlock()
+	}
+}
+
+func (m *MemoryDBRepo) rlock() {
+	if !m.inTx {
+		m.mu.RLock()
+	}
+}
+
+func (m *MemoryDBRepo) runlock() {
+	if !m.inTx {
+		m.mu.RUnlock()
+	}
+}
+
+// Connection returns nil; there is no database behind this repo.
+func (m *MemoryDBRepo) Connection() *sql.DB {
+	return nil
+}
+
+// WithTx runs fn against a copy of the data while holding the write lock,
+// and only keeps the copy if fn succeeds.
+func (m *MemoryDBRepo) WithTx(ctx context.Context, fn func(repo repository.DatabaseRepo) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &MemoryDBRepo{mu: m.mu, data: m.data.clone(), PosterBaseURL: m.PosterBaseURL, PosterVariantsURL: m.PosterVariantsURL, inTx: true}
+
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	m.data = tx.data
+	return nil
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := *d
+
+	c.movies = make(map[int]models.Movie, len(d.movies))
+	for k, v := range d.movies {
+		c.movies[k] = v
+	}
+	c.genres = make(map[int]models.Genre, len(d.genres))
+	for k, v := range d.genres {
+		c.genres[k] = v
+	}
+	c.movieGenres = make(map[int][]int, len(d.movieGenres))
+	for k, v := range d.movieGenres {
+		c.movieGenres[k] = append([]int(nil), v...)
+	}
+	c.users = make(map[int]models.User, len(d.users))
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	c.userRoles = make(map[int][]string, len(d.userRoles))
+	for k, v := range d.userRoles {
+		c.userRoles[k] = append([]string(nil), v...)
+	}
+	c.sessions = make(map[string]models.Session, len(d.sessions))
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+
+	c.watchlists = make(map[int][]models.WatchlistEntry, len(d.watchlists))
+	for k, v := range d.watchlists {
+		c.watchlists[k] = append([]models.WatchlistEntry(nil), v...)
+	}
+	c.reviews = make(map[int]models.Review, len(d.reviews))
+	for k, v := range d.reviews {
+		c.reviews[k] = v
+	}
+	c.reviewActions = append([]models.ModerationAction(nil), d.reviewActions...)
+	c.comments = make(map[int]models.Comment, len(d.comments))
+	for k, v := range d.comments {
+		c.comments[k] = v
+	}
+	c.commentReports = make(map[int]models.CommentReport, len(d.commentReports))
+	for k, v := range d.commentReports {
+		c.commentReports[k] = v
+	}
+	c.moderationActions = append([]models.ModerationAction(nil), d.moderationActions...)
+	c.people = make(map[int]models.Person, len(d.people))
+	for k, v := range d.people {
+		c.people[k] = v
+	}
+	c.credits = make(map[int]models.Credit, len(d.credits))
+	for k, v := range d.credits {
+		c.credits[k] = v
+	}
+	return &c
+}
+
+func (m *MemoryDBRepo) posterBaseURL() string {
+	if m.PosterBaseURL != "" {
+		return m.PosterBaseURL
+	}
+	return DefaultPosterBaseURL
+}
+
+func (m *MemoryDBRepo) posterName(poster string) string {
+	return strings.TrimPrefix(strings.TrimSpace(poster), m.posterBaseURL())
+}
+
+// present returns the movie the way the Postgres repo would: runtime split
+// into hours and minutes and the poster turned into urls.
+func (m *MemoryDBRepo) present(movie models.Movie) *models.Movie {
+	movie.PosterVariants = models.NewPosterVariants(m.PosterVariantsURL, movie.Poster)
+	if movie.Poster != "" {
+		movie.Poster = m.posterBaseURL() + strings.TrimSpace(movie.Poster)
+	}
+
+	movie.RuntimeMinutes = movie.RuntimeHours % 60
+	movie.RuntimeHours = movie.RuntimeHours / 60
+
+	return &movie
+}
+
+// genresOf returns the genres of a movie ordered by name. Callers hold the lock.
+func (d *memoryData) genresOf(movieID int) []*models.Genre {
+	var genres []*models.Genre
+	for _, id := range d.movieGenres[movieID] {
+		g, ok := d.genres[id]
+		if !ok {
+			continue
+		}
+		genres = append(genres, &models.Genre{ID: g.ID, Genre: g.Genre})
+	}
+
+	sort.Slice(genres, func(i, j int) bool { return genres[i].Genre < genres[j].Genre })
+
+	return genres
+}
+
+func (d *memoryData) sortedMovies() []models.Movie {
+	movies := make([]models.Movie, 0, len(d.movies))
+	for _, mv := range d.movies {
+		movies = append(movies, mv)
+	}
+
+	sort.Slice(movies, func(i, j int) bool {
+		if movies[i].Title != movies[j].Title {
+			return movies[i].Title < movies[j].Title
+		}
+		return movies[i].ID < movies[j].ID
+	})
+
+	return movies
+}
+
+func (m *MemoryDBRepo) AllMovies(ctx context.Context) ([]*models.Movie, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var movies []*models.Movie
+	for _, mv := range m.data.sortedMovies() {
+		movies = append(movies, m.present(mv))
+	}
+
+	return movies, nil
+}
+
+func (m *MemoryDBRepo) ListMovies(ctx context.Context, filter models.MovieFilter) ([]*models.Movie, int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var matched []models.Movie
+	for _, mv := range m.data.sortedMovies() {
+		if m.data.matches(mv, filter) {
+			matched = append(matched, mv)
+		}
+	}
+
+	less := func(a, b models.Movie) bool { return a.Title < b.Title }
+	switch filter.Sort {
+	case "release":
+		less = func(a, b models.Movie) bool { return a.Release < b.Release }
+	case "imdb":
+		less = func(a, b models.Movie) bool { return a.IMDb < b.IMDb }
+	case "runtime":
+		less = func(a, b models.Movie) bool { return a.RuntimeHours < b.RuntimeHours }
+	case "created_at":
+		less = func(a, b models.Movie) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if filter.Desc {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	movies := []*models.Movie{}
+	for i := filter.Offset(); i < len(matched) && len(movies) < filter.PageSize; i++ {
+		movies = append(movies, m.present(matched[i]))
+	}
+
+	return movies, len(matched), nil
+}
+
+// EachMovie calls fn with every movie matching filter in title order. The
+// movies are copied out first, so that fn runs without holding the lock.
+func (m *MemoryDBRepo) EachMovie(ctx context.Context, filter models.MovieFilter, fn func(movie *models.Movie) error) error {
+	m.rlock()
+	var movies []*models.Movie
+	for _, mv := range m.data.sortedMovies() {
+		if m.data.matches(mv, filter) {
+			movie := m.present(mv)
+			movie.Genres = m.data.genresOf(mv.ID)
+			movies = append(movies, movie)
+		}
+	}
+	m.runlock()
+
+	for _, movie := range movies {
+		err := fn(movie)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether a stored movie passes the filters in filter.
+// Callers hold the lock.
+func (d *memoryData) matches(mv models.Movie, filter models.MovieFilter) bool {
+	switch {
+	case filter.GenreID > 0 && !containsInt(d.movieGenres[mv.ID], filter.GenreID):
+		return false
+	case len(filter.MPAA) > 0 && !containsString(filter.MPAA, mv.MPAA):
+		return false
+	case filter.YearFrom > 0 && mv.Release < filter.YearFrom:
+		return false
+	case filter.YearTo > 0 && mv.Release > filter.YearTo:
+		return false
+	case filter.MinIMDb > 0 && mv.IMDb < filter.MinIMDb:
+		return false
+	case filter.RuntimeMin > 0 && mv.RuntimeHours < filter.RuntimeMin:
+		return false
+	case filter.RuntimeMax > 0 && mv.RuntimeHours > filter.RuntimeMax:
+		return false
+	}
+	return true
+}
+
+// SearchMovies does a case-insensitive substring match on the title and
+// description, ranking title matches first.
+func (m *MemoryDBRepo) SearchMovies(ctx context.Context, query string, limit int) ([]*models.MovieSearchResult, error) {
+	m.rlock()
+	defer m.runlock()
+
+	re, err := regexp.Compile("(?i)" + regexp.QuoteMeta(strings.TrimSpace(query)))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*models.MovieSearchResult{}
+	for _, mv := range m.data.sortedMovies() {
+		var rank float32
+		if re.MatchString(mv.Title) {
+			rank += 1
+		}
+		if re.MatchString(mv.Description) {
+			rank += 0.5
+		}
+		if rank == 0 {
+			continue
+		}
+
+		results = append(results, &models.MovieSearchResult{
+			Movie:          *m.present(mv),
+			Rank:           rank,
+			TitleHighlight: highlight(mv.Title, re),
+			Snippet:        highlight(mv.Description, re),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func (m *MemoryDBRepo) GetMovieByID(ctx context.Context, id int) (*models.Movie, error) {
+	m.rlock()
+	defer m.runlock()
+
+	mv, ok := m.data.movies[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return m.present(mv), nil
+}
+
+func (m *MemoryDBRepo) MovieIDByIMDbID(ctx context.Context, imdbID string) (int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	id := 0
+	for _, mv := range m.data.movies {
+		if mv.IMDbID == imdbID && (id == 0 || mv.ID < id) {
+			id = mv.ID
+		}
+	}
+	if id == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	return id, nil
+}
+
+func (m *MemoryDBRepo) OneMovie(ctx context.Context, id int) (*models.Movie, error) {
+	m.rlock()
+	defer m.runlock()
+
+	mv, ok := m.data.movies[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	movie := m.present(mv)
+	movie.Genres = m.data.genresOf(id)
+
+	return movie, nil
+}
+
+func (m *MemoryDBRepo) OneMovieForEdit(ctx context.Context, id int) (*models.Movie, []*models.Genre, error) {
+	m.rlock()
+	defer m.runlock()
+
+	mv, ok := m.data.movies[id]
+	if !ok {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	movie := m.present(mv)
+	movie.Genres = m.data.genresOf(id)
+	for _, g := range movie.Genres {
+		movie.GenresArray = append(movie.GenresArray, g.ID)
+	}
+
+	var allGenres []*models.Genre
+	for _, g := range m.data.genres {
+		allGenres = append(allGenres, &models.Genre{ID: g.ID, Genre: g.Genre})
+	}
+	sort.Slice(allGenres, func(i, j int) bool { return allGenres[i].Genre < allGenres[j].Genre })
+
+	return movie, allGenres, nil
+}
+
+func (m *MemoryDBRepo) InsertMovie(ctx context.Context, movie models.Movie) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	movie.ID = m.data.nextMovieID
+	m.data.nextMovieID++
+
+	movie.Poster = m.posterName(movie.Poster)
+	movie.RuntimeHours, movie.RuntimeMinutes = movie.Runtime(), 0
+	movie.Genres, movie.GenresArray = nil, nil
+	m.data.movies[movie.ID] = movie
+
+	return movie.ID, nil
+}
+
+func (m *MemoryDBRepo) UpdateMovie(ctx context.Context, movie models.Movie) error {
+	m.lock()
+	defer m.unlock()
+
+	old, ok := m.data.movies[movie.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	movie.Poster = m.posterName(movie.Poster)
+	movie.RuntimeHours, movie.RuntimeMinutes = movie.Runtime(), 0
+	movie.CreatedAt = old.CreatedAt
+	movie.Genres, movie.GenresArray = nil, nil
+	m.data.movies[movie.ID] = movie
+
+	return nil
+}
+
+func (m *MemoryDBRepo) UpdateMoviePoster(ctx context.Context, id int, poster string) error {
+	m.lock()
+	defer m.unlock()
+
+	movie, ok := m.data.movies[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	movie.Poster = m.posterName(poster)
+	movie.UpdatedAt = time.Now()
+	m.data.movies[id] = movie
+
+	return nil
+}
+
+func (m *MemoryDBRepo) UpdateMovieGenres(ctx context.Context, id int, genreIDs []int) error {
+	m.lock()
+	defer m.unlock()
+
+	var ids []int
+	for _, n := range genreIDs {
+		if !containsInt(ids, n) {
+			ids = append(ids, n)
+		}
+	}
+	m.data.movieGenres[id] = ids
+
+	return nil
+}
+
+func (m *MemoryDBRepo) DeleteMovie(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.movies[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(m.data.movies, id)
+	delete(m.data.movieGenres, id)
+	for userID, list := range m.data.watchlists {
+		m.data.watchlists[userID] = slices.DeleteFunc(list, func(e models.WatchlistEntry) bool { return e.MovieID == id })
+	}
+	for reviewID, review := range m.data.reviews {
+		if review.MovieID == id {
+			m.data.deleteReview(reviewID)
+		}
+	}
+	for commentID, comment := range m.data.comments {
+		if comment.MovieID == id {
+			m.data.deleteComment(commentID)
+		}
+	}
+	for creditID, credit := range m.data.credits {
+		if credit.MovieID == id {
+			delete(m.data.credits, creditID)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryDBRepo) AllGenres(ctx context.Context) ([]*models.GenreCount, error) {
+	m.rlock()
+	defer m.runlock()
+
+	counts := make(map[int]int)
+	for _, ids := range m.data.movieGenres {
+		for _, id := range ids {
+			counts[id]++
+		}
+	}
+
+	var genres []*models.GenreCount
+	for _, g := range m.data.genres {
+		genres = append(genres, &models.GenreCount{ID: g.ID, Genre: g.Genre, Movies: counts[g.ID]})
+	}
+	sort.Slice(genres, func(i, j int) bool { return genres[i].Genre < genres[j].Genre })
+
+	return genres, nil
+}
+
+func (m *MemoryDBRepo) InsertGenre(ctx context.Context, genre models.Genre) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	if m.data.genreNameTaken(genre) {
+		return 0, repository.ErrDuplicate
+	}
+
+	genre.ID = m.data.nextGenreID
+	m.data.nextGenreID++
+	m.data.genres[genre.ID] = genre
+
+	return genre.ID, nil
+}
+
+func (m *MemoryDBRepo) UpdateGenre(ctx context.Context, genre models.Genre) error {
+	m.lock()
+	defer m.unlock()
+
+	old, ok := m.data.genres[genre.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	if m.data.genreNameTaken(genre) {
+		return repository.ErrDuplicate
+	}
+
+	old.Genre = genre.Genre
+	old.UpdatedAt = genre.UpdatedAt
+	m.data.genres[genre.ID] = old
+
+	return nil
+}
+
+// genreNameTaken mirrors the case-insensitive unique index on genre names.
+func (d *memoryData) genreNameTaken(genre models.Genre) bool {
+	for _, g := range d.genres {
+		if g.ID != genre.ID && strings.EqualFold(g.Genre, genre.Genre) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryDBRepo) DeleteGenre(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.genres[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(m.data.genres, id)
+	for movieID, ids := range m.data.movieGenres {
+		m.data.movieGenres[movieID] = removeInt(ids, id)
+	}
+
+	return nil
+}
+
+func (m *MemoryDBRepo) MergeGenres(ctx context.Context, fromID, intoID int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.genres[fromID]; !ok {
+		return sql.ErrNoRows
+	}
+
+	for movieID, ids := range m.data.movieGenres {
+		if !containsInt(ids, fromID) {
+			continue
+		}
+		ids = removeInt(ids, fromID)
+		if !containsInt(ids, intoID) {
+			ids = append(ids, intoID)
+		}
+		m.data.movieGenres[movieID] = ids
+	}
+	delete(m.data.genres, fromID)
+
+	return nil
+}
+
+func (m *MemoryDBRepo) UnknownGenreIDs(ctx context.Context, ids []int) ([]int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var unknown []int
+	for _, id := range ids {
+		if _, ok := m.data.genres[id]; !ok && !containsInt(unknown, id) {
+			unknown = append(unknown, id)
+		}
+	}
+
+	return unknown, nil
+}
+
+func (m *MemoryDBRepo) MovieGenreIDs(ctx context.Context) (map[int][]int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	genres := make(map[int][]int, len(m.data.movies))
+	for id := range m.data.movies {
+		ids := append([]int{}, m.data.movieGenres[id]...)
+		sort.Ints(ids)
+		genres[id] = ids
+	}
+
+	return genres, nil
+}
+
+func (m *MemoryDBRepo) userWithRoles(u models.User) *models.User {
+	u.Roles = append([]string{}, m.data.userRoles[u.ID]...)
+	sort.Strings(u.Roles)
+	return &u
+}
+
+func (m *MemoryDBRepo) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	m.rlock()
+	defer m.runlock()
+
+	u, ok := m.data.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return m.userWithRoles(u), nil
+}
+
+func (m *MemoryDBRepo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.rlock()
+	defer m.runlock()
+
+	for _, u := range m.data.users {
+		if strings.EqualFold(u.Email, email) {
+			return m.userWithRoles(u), nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryDBRepo) UpdateUserPassword(ctx context.Context, id int, hash string) error {
+	m.lock()
+	defer m.unlock()
+
+	u, ok := m.data.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	u.Password = hash
+	u.UpdatedAt = time.Now()
+	m.data.users[id] = u
+
+	return nil
+}
+
+func (m *MemoryDBRepo) InsertUser(ctx context.Context, user models.User) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	for _, u := range m.data.users {
+		if strings.EqualFold(u.Email, user.Email) {
+			return 0, repository.ErrDuplicate
+		}
+	}
+
+	user.ID = m.data.nextUserID
+	m.data.nextUserID++
+
+	m.data.userRoles[user.ID] = append([]string(nil), user.Roles...)
+	user.Roles = nil
+	m.data.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+func (m *MemoryDBRepo) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	m.lock()
+	defer m.unlock()
+
+	u, ok := m.data.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now()
+	u.Disabled = disabled
+	u.UpdatedAt = now
+	m.data.users[id] = u
+
+	if disabled {
+		for sid, s := range m.data.sessions {
+			if s.UserID == id && s.RevokedAt == nil {
+				s.RevokedAt = &now
+				m.data.sessions[sid] = s
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryDBRepo) AllUsers(ctx context.Context) ([]*models.User, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var users []*models.User
+	for _, u := range m.data.users {
+		user := m.userWithRoles(u)
+		user.Password = ""
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].LastName != users[j].LastName {
+			return users[i].LastName < users[j].LastName
+		}
+		return users[i].FirstName < users[j].FirstName
+	})
+
+	return users, nil
+}
+
+func (m *MemoryDBRepo) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	m.rlock()
+	defer m.runlock()
+
+	roles := append([]string{}, m.data.userRoles[userID]...)
+	sort.Strings(roles)
+
+	return roles, nil
+}
+
+func (m *MemoryDBRepo) SetUserRoles(ctx context.Context, userID int, roles []string) error {
+	m.lock()
+	defer m.unlock()
+
+	m.data.userRoles[userID] = append([]string(nil), roles...)
+
+	return nil
+}
+
+func (m *MemoryDBRepo) InsertSession(ctx context.Context, session models.Session) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.sessions[session.ID]; ok {
+		return fmt.Errorf("duplicate session %s", session.ID)
+	}
+	m.data.sessions[session.ID] = session
+
+	return nil
+}
+
+func (m *MemoryDBRepo) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	m.rlock()
+	defer m.runlock()
+
+	s, ok := m.data.sessions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &s, nil
+}
+
+func (m *MemoryDBRepo) RotateSession(ctx context.Context, oldID string, next models.Session) error {
+	m.lock()
+	defer m.unlock()
+
+	old, ok := m.data.sessions[oldID]
+	if !ok || old.RevokedAt != nil {
+		return repository.ErrSessionRotated
+	}
+
+	now := time.Now()
+	old.RevokedAt = &now
+	old.ReplacedBy = next.ID
+	m.data.sessions[oldID] = old
+	m.data.sessions[next.ID] = next
+
+	return nil
+}
+
+func (m *MemoryDBRepo) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	m.lock()
+	defer m.unlock()
+
+	now := time.Now()
+	for id, s := range m.data.sessions {
+		if s.FamilyID == familyID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+			m.data.sessions[id] = s
+		}
+	}
+
+	return nil
+}
+
+func containsInt(s []int, n int) bool {
+	for _, v := range s {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func removeInt(s []int, n int) []int {
+	var out []int
+	for _, v := range s {
+		if v != n {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (m *MemoryDBRepo) Watchlist(ctx context.Context, userID int) ([]*models.WatchlistEntry, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var entries []*models.WatchlistEntry
+	for i, e := range m.data.watchlists[userID] {
+		e.Movie = m.present(m.data.movies[e.MovieID])
+		e.Position = i + 1
+		e.Watched = e.WatchedAt != nil
+		entries = append(entries, &e)
+	}
+
+	return entries, nil
+}
+
+func (m *MemoryDBRepo) WatchlistMovieIDs(ctx context.Context, userID int) (map[int]bool, error) {
+	m.rlock()
+	defer m.runlock()
+
+	ids := make(map[int]bool)
+	for _, e := range m.data.watchlists[userID] {
+		ids[e.MovieID] = true
+	}
+	return ids, nil
+}
+
+func (m *MemoryDBRepo) SaveWatchlistEntry(ctx context.Context, userID int, entry models.WatchlistEntry) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.movies[entry.MovieID]; !ok {
+		return sql.ErrNoRows
+	}
+
+	list := m.data.watchlists[userID]
+	byMovie := make(map[int]models.WatchlistEntry, len(list)+1)
+	var ids []int
+	for _, e := range list {
+		byMovie[e.MovieID] = e
+		ids = append(ids, e.MovieID)
+	}
+
+	saved := models.WatchlistEntry{
+		MovieID:   entry.MovieID,
+		Notes:     entry.Notes,
+		WatchedAt: entry.WatchedAt,
+		AddedAt:   time.Now(),
+	}
+	if old, ok := byMovie[entry.MovieID]; ok {
+		saved.AddedAt = old.AddedAt
+	}
+	byMovie[entry.MovieID] = saved
+
+	list = nil
+	for _, id := range placeInList(ids, entry.MovieID, entry.Position) {
+		list = append(list, byMovie[id])
+	}
+	m.data.watchlists[userID] = list
+
+	return nil
+}
+
+func (m *MemoryDBRepo) RemoveFromWatchlist(ctx context.Context, userID, movieID int) error {
+	m.lock()
+	defer m.unlock()
+
+	list := m.data.watchlists[userID]
+	kept := slices.DeleteFunc(slices.Clone(list), func(e models.WatchlistEntry) bool { return e.MovieID == movieID })
+	if len(kept) == len(list) {
+		return sql.ErrNoRows
+	}
+
+	m.data.watchlists[userID] = kept
+	return nil
+}
+
+// findReview returns the id of a user's review of a movie, or 0. Callers hold
+// the lock.
+func (d *memoryData) findReview(movieID, userID int) int {
+	for id, r := range d.reviews {
+		if r.MovieID == movieID && r.UserID == userID {
+			return id
+		}
+	}
+	return 0
+}
+
+// presentReview fills in the author of review. Callers hold the lock.
+func (d *memoryData) presentReview(review models.Review) *models.Review {
+	user := d.users[review.UserID]
+	review.Author = authorName(user.FirstName, user.LastName)
+	return &review
+}
+
+func (m *MemoryDBRepo) InsertReview(ctx context.Context, review models.Review) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.movies[review.MovieID]; !ok {
+		return 0, fmt.Errorf("movie %d does not exist", review.MovieID)
+	}
+	if _, ok := m.data.users[review.UserID]; !ok {
+		return 0, fmt.Errorf("user %d does not exist", review.UserID)
+	}
+	if m.data.findReview(review.MovieID, review.UserID) != 0 {
+		return 0, repository.ErrDuplicate
+	}
+
+	review.ID = m.data.nextReviewID
+	m.data.nextReviewID++
+	review.Author = ""
+	m.data.reviews[review.ID] = review
+
+	return review.ID, nil
+}
+
+func (m *MemoryDBRepo) UpdateReview(ctx context.Context, review models.Review) error {
+	m.lock()
+	defer m.unlock()
+
+	id := m.data.findReview(review.MovieID, review.UserID)
+	if id == 0 {
+		return sql.ErrNoRows
+	}
+
+	r := m.data.reviews[id]
+	r.Rating = review.Rating
+	r.Body = review.Body
+	r.Status = review.Status
+	r.FlagReason = review.FlagReason
+	r.UpdatedAt = review.UpdatedAt
+	m.data.reviews[id] = r
+
+	return nil
+}
+
+func (m *MemoryDBRepo) DeleteReview(ctx context.Context, movieID, userID int) error {
+	m.lock()
+	defer m.unlock()
+
+	id := m.data.findReview(movieID, userID)
+	if id == 0 {
+		return sql.ErrNoRows
+	}
+
+	m.data.deleteReview(id)
+	return nil
+}
+
+// deleteReview deletes a review with its moderation actions. Callers hold the
+// lock.
+func (d *memoryData) deleteReview(id int) {
+	delete(d.reviews, id)
+	d.reviewActions = slices.DeleteFunc(d.reviewActions, func(a models.ModerationAction) bool { return a.ReviewID == id })
+}
+
+func (m *MemoryDBRepo) GetReview(ctx context.Context, movieID, userID int) (*models.Review, error) {
+	m.rlock()
+	defer m.runlock()
+
+	id := m.data.findReview(movieID, userID)
+	if id == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return m.data.presentReview(m.data.reviews[id]), nil
+}
+
+func (m *MemoryDBRepo) GetReviewByID(ctx context.Context, id int) (*models.Review, error) {
+	m.rlock()
+	defer m.runlock()
+
+	review, ok := m.data.reviews[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return m.data.presentReview(review), nil
+}
+
+func (m *MemoryDBRepo) ListReviews(ctx context.Context, filter models.ReviewFilter) ([]*models.Review, int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var matched []models.Review
+	for _, r := range m.data.reviews {
+		if filter.MovieID != 0 && r.MovieID != filter.MovieID {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, r.Status) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if filter.OldestFirst {
+			a, b = b, a
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	reviews := []*models.Review{}
+	start := min(filter.Offset(), len(matched))
+	end := min(start+filter.PageSize, len(matched))
+	for _, r := range matched[start:end] {
+		reviews = append(reviews, m.data.presentReview(r))
+	}
+
+	return reviews, len(matched), nil
+}
+
+func (m *MemoryDBRepo) ModerateReview(ctx context.Context, action models.ModerationAction) error {
+	m.lock()
+	defer m.unlock()
+
+	review, ok := m.data.reviews[action.ReviewID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	action.FromStatus = review.Status
+	review.Status = action.ToStatus
+	review.FlagReason = moderatedFlagReason(action)
+	review.UpdatedAt = action.CreatedAt
+	m.data.reviews[review.ID] = review
+
+	action.ID = m.data.nextReviewActionID
+	m.data.nextReviewActionID++
+	m.data.reviewActions = append(m.data.reviewActions, action)
+
+	return nil
+}
+
+func (m *MemoryDBRepo) ReviewActions(ctx context.Context, reviewID int) ([]*models.ModerationAction, error) {
+	m.rlock()
+	defer m.runlock()
+
+	actions := []*models.ModerationAction{}
+	for _, a := range slices.Backward(m.data.reviewActions) {
+		if a.ReviewID == reviewID {
+			actions = append(actions, &a)
+		}
+	}
+
+	return actions, nil
+}
+
+func (m *MemoryDBRepo) CommunityRatings(ctx context.Context, movieIDs []int) (map[int]models.CommunityRating, error) {
+	m.rlock()
+	defer m.runlock()
+
+	wanted := make(map[int]bool, len(movieIDs))
+	for _, id := range movieIDs {
+		wanted[id] = true
+	}
+
+	sums := make(map[int]int)
+	votes := make(map[int]int)
+	for _, r := range m.data.reviews {
+		if wanted[r.MovieID] {
+			sums[r.MovieID] += r.Rating
+			votes[r.MovieID]++
+		}
+	}
+
+	ratings := make(map[int]models.CommunityRating, len(votes))
+	for id, n := range votes {
+		ratings[id] = communityRating(sums[id], n)
+	}
+	return ratings, nil
+}
+
+// deleteComment deletes a comment with its reports and moderation actions.
+// Callers hold the lock.
+func (d *memoryData) deleteComment(id int) {
+	delete(d.comments, id)
+	for reportID, r := range d.commentReports {
+		if r.CommentID == id {
+			delete(d.commentReports, reportID)
+		}
+	}
+	d.moderationActions = slices.DeleteFunc(d.moderationActions, func(a models.ModerationAction) bool { return a.CommentID == id })
+}
+
+// presentComment fills in the author and report count of comment. Callers
+// hold the lock.
+func (d *memoryData) presentComment(comment models.Comment) *models.Comment {
+	user := d.users[comment.UserID]
+	comment.Author = authorName(user.FirstName, user.LastName)
+	for _, r := range d.commentReports {
+		if r.CommentID == comment.ID {
+			comment.Reports++
+		}
+	}
+	return &comment
+}
+
+func (m *MemoryDBRepo) InsertComment(ctx context.Context, comment models.Comment) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.movies[comment.MovieID]; !ok {
+		return 0, fmt.Errorf("movie %d does not exist", comment.MovieID)
+	}
+	if _, ok := m.data.users[comment.UserID]; !ok {
+		return 0, fmt.Errorf("user %d does not exist", comment.UserID)
+	}
+
+	comment.ID = m.data.nextCommentID
+	m.data.nextCommentID++
+	comment.Author = ""
+	comment.Reports = 0
+	m.data.comments[comment.ID] = comment
+
+	return comment.ID, nil
+}
+
+func (m *MemoryDBRepo) GetComment(ctx context.Context, id int) (*models.Comment, error) {
+	m.rlock()
+	defer m.runlock()
+
+	comment, ok := m.data.comments[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return m.data.presentComment(comment), nil
+}
+
+func (m *MemoryDBRepo) ListComments(ctx context.Context, filter models.CommentFilter) ([]*models.Comment, int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var matched []models.Comment
+	for _, c := range m.data.comments {
+		if filter.MovieID != 0 && c.MovieID != filter.MovieID {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, c.Status) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if filter.OldestFirst {
+			a, b = b, a
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	comments := []*models.Comment{}
+	start := min(filter.Offset(), len(matched))
+	end := min(start+filter.PageSize, len(matched))
+	for _, c := range matched[start:end] {
+		comments = append(comments, m.data.presentComment(c))
+	}
+
+	return comments, len(matched), nil
+}
+
+func (m *MemoryDBRepo) ReportComment(ctx context.Context, report models.CommentReport) error {
+	m.lock()
+	defer m.unlock()
+
+	comment, ok := m.data.comments[report.CommentID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	for _, r := range m.data.commentReports {
+		if r.CommentID == report.CommentID && r.UserID == report.UserID {
+			return repository.ErrDuplicate
+		}
+	}
+
+	report.ID = m.data.nextReportID
+	m.data.nextReportID++
+	m.data.commentReports[report.ID] = report
+
+	if comment.Status == models.CommentApproved {
+		comment.Status = models.CommentFlagged
+		comment.FlagReason = reportReason(report.Reason)
+		comment.UpdatedAt = report.CreatedAt
+		m.data.comments[comment.ID] = comment
+	}
+
+	return nil
+}
+
+func (m *MemoryDBRepo) CommentReports(ctx context.Context, commentID int) ([]*models.CommentReport, error) {
+	m.rlock()
+	defer m.runlock()
+
+	reports := []*models.CommentReport{}
+	for _, r := range m.data.commentReports {
+		if r.CommentID == commentID {
+			reports = append(reports, &r)
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].CreatedAt.Equal(reports[j].CreatedAt) {
+			return reports[i].CreatedAt.Before(reports[j].CreatedAt)
+		}
+		return reports[i].ID < reports[j].ID
+	})
+
+	return reports, nil
+}
+
+func (m *MemoryDBRepo) ModerateComment(ctx context.Context, action models.ModerationAction) error {
+	m.lock()
+	defer m.unlock()
+
+	comment, ok := m.data.comments[action.CommentID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	action.FromStatus = comment.Status
+	comment.Status = action.ToStatus
+	comment.FlagReason = moderatedFlagReason(action)
+	comment.UpdatedAt = action.CreatedAt
+	m.data.comments[comment.ID] = comment
+
+	action.ID = m.data.nextActionID
+	m.data.nextActionID++
+	m.data.moderationActions = append(m.data.moderationActions, action)
+
+	return nil
+}
+
+func (m *MemoryDBRepo) ModerationActions(ctx context.Context, commentID, page, pageSize int) ([]*models.ModerationAction, int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	var matched []models.ModerationAction
+	for _, a := range slices.Backward(m.data.moderationActions) {
+		if commentID == 0 || a.CommentID == commentID {
+			matched = append(matched, a)
+		}
+	}
+
+	actions := []*models.ModerationAction{}
+	start := min((page-1)*pageSize, len(matched))
+	end := min(start+pageSize, len(matched))
+	for i := range matched[start:end] {
+		actions = append(actions, &matched[start+i])
+	}
+
+	return actions, len(matched), nil
+}
+
+func (m *MemoryDBRepo) AllRatings(ctx context.Context) (map[int]map[int]int, error) {
+	m.rlock()
+	defer m.runlock()
+
+	ratings := make(map[int]map[int]int)
+	for _, r := range m.data.reviews {
+		if ratings[r.UserID] == nil {
+			ratings[r.UserID] = make(map[int]int)
+		}
+		ratings[r.UserID][r.MovieID] = r.Rating
+	}
+
+	return ratings, nil
+}
+
+func (m *MemoryDBRepo) InsertPerson(ctx context.Context, person models.Person) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	person.ID = m.data.nextPersonID
+	m.data.nextPersonID++
+	person.Filmography = nil
+	m.data.people[person.ID] = person
+
+	return person.ID, nil
+}
+
+func (m *MemoryDBRepo) UpdatePerson(ctx context.Context, person models.Person) error {
+	m.lock()
+	defer m.unlock()
+
+	old, ok := m.data.people[person.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	person.CreatedAt = old.CreatedAt
+	person.Filmography = nil
+	m.data.people[person.ID] = person
+
+	return nil
+}
+
+func (m *MemoryDBRepo) DeletePerson(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.people[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(m.data.people, id)
+	for creditID, credit := range m.data.credits {
+		if credit.PersonID == id {
+			delete(m.data.credits, creditID)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryDBRepo) GetPerson(ctx context.Context, id int) (*models.Person, error) {
+	m.rlock()
+	defer m.runlock()
+
+	person, ok := m.data.people[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &person, nil
+}
+
+func (m *MemoryDBRepo) SearchPeople(ctx context.Context, query string, limit int) ([]*models.Person, error) {
+	m.rlock()
+	defer m.runlock()
+
+	query = strings.ToLower(query)
+	people := []*models.Person{}
+	for _, p := range m.data.people {
+		if strings.Contains(strings.ToLower(p.Name), query) {
+			people = append(people, &p)
+		}
+	}
+	sort.Slice(people, func(i, j int) bool {
+		if people[i].Name != people[j].Name {
+			return people[i].Name < people[j].Name
+		}
+		return people[i].ID < people[j].ID
+	})
+
+	return people[:min(limit, len(people))], nil
+}
+
+// presentCredit fills in the movie and person names of credit. Callers hold
+// the lock.
+func (d *memoryData) presentCredit(credit models.Credit) *models.Credit {
+	movie := d.movies[credit.MovieID]
+	credit.MovieTitle, credit.Release = movie.Title, movie.Release
+	credit.Name = d.people[credit.PersonID].Name
+	return &credit
+}
+
+func (m *MemoryDBRepo) PersonCredits(ctx context.Context, personID int) ([]*models.Credit, error) {
+	m.rlock()
+	defer m.runlock()
+
+	credits := []*models.Credit{}
+	for _, c := range m.data.credits {
+		if c.PersonID == personID {
+			credits = append(credits, m.data.presentCredit(c))
+		}
+	}
+	slices.SortFunc(credits, func(a, b *models.Credit) int {
+		if a.Release != b.Release {
+			return b.Release - a.Release
+		}
+		if a.MovieTitle != b.MovieTitle {
+			return strings.Compare(a.MovieTitle, b.MovieTitle)
+		}
+		if a.MovieID != b.MovieID {
+			return a.MovieID - b.MovieID
+		}
+		return compareCredits(*a, *b)
+	})
+
+	return credits, nil
+}
+
+func (m *MemoryDBRepo) MovieCredits(ctx context.Context, movieID int) ([]*models.Credit, error) {
+	m.rlock()
+	defer m.runlock()
+
+	credits := []*models.Credit{}
+	for _, c := range m.data.credits {
+		if c.MovieID == movieID {
+			credits = append(credits, m.data.presentCredit(c))
+		}
+	}
+	slices.SortFunc(credits, func(a, b *models.Credit) int { return compareCredits(*a, *b) })
+
+	return credits, nil
+}
+
+func (m *MemoryDBRepo) GetCredit(ctx context.Context, id int) (*models.Credit, error) {
+	m.rlock()
+	defer m.runlock()
+
+	credit, ok := m.data.credits[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return m.data.presentCredit(credit), nil
+}
+
+// findCredit returns the id of another credit with the same movie, person,
+// role and character as credit, or 0. Callers hold the lock.
+func (d *memoryData) findCredit(credit models.Credit) int {
+	for id, c := range d.credits {
+		if id != credit.ID && c.MovieID == credit.MovieID && c.PersonID == credit.PersonID &&
+			c.Role == credit.Role && c.Character == credit.Character {
+			return id
+		}
+	}
+	return 0
+}
+
+func (m *MemoryDBRepo) InsertCredit(ctx context.Context, credit models.Credit) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.movies[credit.MovieID]; !ok {
+		return 0, fmt.Errorf("movie %d does not exist", credit.MovieID)
+	}
+	if _, ok := m.data.people[credit.PersonID]; !ok {
+		return 0, fmt.Errorf("person %d does not exist", credit.PersonID)
+	}
+	credit.ID = 0
+	if m.data.findCredit(credit) != 0 {
+		return 0, repository.ErrDuplicate
+	}
+
+	credit.ID = m.data.nextCreditID
+	m.data.nextCreditID++
+	credit.MovieTitle, credit.Release, credit.Name = "", 0, ""
+	m.data.credits[credit.ID] = credit
+
+	return credit.ID, nil
+}
+
+func (m *MemoryDBRepo) UpdateCredit(ctx context.Context, credit models.Credit) error {
+	m.lock()
+	defer m.unlock()
+
+	c, ok := m.data.credits[credit.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	c.Role = credit.Role
+	c.Character = credit.Character
+	c.Billing = credit.Billing
+	if m.data.findCredit(c) != 0 {
+		return repository.ErrDuplicate
+	}
+	m.data.credits[c.ID] = c
+
+	return nil
+}
+
+func (m *MemoryDBRepo) DeleteCredit(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.data.credits[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.data.credits, id)
+
+	return nil
+}
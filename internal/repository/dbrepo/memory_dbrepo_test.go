@@ -0,0 +1,100 @@
+package dbrepo
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+func TestMemoryDBRepoWithTxRollsBack(t *testing.T) {
+	repo := NewMemoryDBRepo()
+	repo.Seed(Fixtures{Genres: []models.Genre{{ID: 1, Genre: "Drama"}}})
+
+	boom := errors.New("boom")
+	err := repo.WithTx(t.Context(), func(tx repository.DatabaseRepo) error {
+		id, err := tx.InsertMovie(t.Context(), models.Movie{Title: "Half written"})
+		if err != nil {
+			return err
+		}
+
+		// visible inside the transaction
+		if _, err := tx.OneMovie(t.Context(), id); err != nil {
+			t.Errorf("expected the movie inside the transaction: %s", err)
+		}
+
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom but got %v", err)
+	}
+
+	movies, _ := repo.AllMovies(t.Context())
+	if len(movies) != 0 {
+		t.Errorf("expected the insert to be rolled back but found %d movies", len(movies))
+	}
+
+	err = repo.WithTx(t.Context(), func(tx repository.DatabaseRepo) error {
+		id, err := tx.InsertMovie(t.Context(), models.Movie{Title: "Committed"})
+		if err != nil {
+			return err
+		}
+		return tx.UpdateMovieGenres(t.Context(), id, []int{1, 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie, err := repo.OneMovie(t.Context(), 1)
+	if err != nil || len(movie.Genres) != 1 {
+		t.Errorf("expected the committed movie with one genre, got %+v %v", movie, err)
+	}
+}
+
+func TestMemoryDBRepoConcurrentUse(t *testing.T) {
+	repo := NewMemoryDBRepo()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = repo.WithTx(t.Context(), func(tx repository.DatabaseRepo) error {
+				_, err := tx.InsertMovie(t.Context(), models.Movie{Title: "Movie"})
+				return err
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, _ = repo.ListMovies(t.Context(), models.MovieFilter{Page: 1, PageSize: 10})
+		}()
+	}
+	wg.Wait()
+
+	movies, _ := repo.AllMovies(t.Context())
+	if len(movies) != 20 {
+		t.Errorf("expected 20 movies but got %d", len(movies))
+	}
+}
+
+func TestMemoryDBRepoGenreNamesAreUnique(t *testing.T) {
+	repo := NewMemoryDBRepo()
+	repo.Seed(Fixtures{Genres: []models.Genre{{ID: 1, Genre: "Drama"}, {ID: 2, Genre: "Crime"}}})
+
+	_, err := repo.InsertGenre(t.Context(), models.Genre{Genre: "drama"})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("insert: expected ErrDuplicate but got %v", err)
+	}
+
+	err = repo.UpdateGenre(t.Context(), models.Genre{ID: 2, Genre: "DRAMA"})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("update: expected ErrDuplicate but got %v", err)
+	}
+
+	// renaming a genre to a different case of its own name is fine
+	err = repo.UpdateGenre(t.Context(), models.Genre{ID: 1, Genre: "DRAMA"})
+	if err != nil {
+		t.Errorf("update own name: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package dbrepo
+
+import (
+	"slices"
+	"strings"
+	"watch-a-movie/internal/models"
+)
+
+// likePattern matches values containing query with LIKE ... ESCAPE '\'.
+func likePattern(query string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query) + "%"
+}
+
+// creditOrder is the SQL ordering credits of one movie are listed in, from
+// credits C: by role in the order of models.CreditRoles, then billed actors
+// by billing, then the unbilled.
+const creditOrder = `
+	CASE C.ROLE WHEN 'director' THEN 0 WHEN 'writer' THEN 1 ELSE 2 END,
+	C.BILLING = 0, C.BILLING, C.ID
+`
+
+// compareCredits orders credits of one movie like creditOrder.
+func compareCredits(a, b models.Credit) int {
+	ra, rb := slices.Index(models.CreditRoles, a.Role), slices.Index(models.CreditRoles, b.Role)
+	if ra != rb {
+		return ra - rb
+	}
+	if (a.Billing == 0) != (b.Billing == 0) {
+		if a.Billing == 0 {
+			return 1
+		}
+		return -1
+	}
+	if a.Billing != b.Billing {
+		return a.Billing - b.Billing
+	}
+	return a.ID - b.ID
+}
@@ -0,0 +1,177 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+// testPeople credits people on movie 1, The Godfather (1972), and movie 2,
+// The Godfather Part II (1974).
+func testPeople(t *testing.T, repo repository.DatabaseRepo) {
+	t.Helper()
+	ctx := t.Context()
+
+	var ids []int
+	for _, p := range []models.Person{
+		{Name: "Al Pacino", BirthYear: 1940},
+		{Name: "Francis Ford Coppola", BirthYear: 1939},
+		{Name: "Marlon Brando", BirthYear: 1924},
+		{Name: "100% Unknown"},
+	} {
+		id, err := repo.InsertPerson(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	pacino, coppola, brando, unknown := ids[0], ids[1], ids[2], ids[3]
+
+	for _, c := range []models.Credit{
+		{MovieID: 1, PersonID: pacino, Role: models.CreditActor, Character: "Michael Corleone", Billing: 2},
+		{MovieID: 1, PersonID: brando, Role: models.CreditActor, Character: "Don Vito Corleone", Billing: 1},
+		{MovieID: 1, PersonID: unknown, Role: models.CreditActor, Character: "Extra"},
+		{MovieID: 1, PersonID: coppola, Role: models.CreditWriter},
+		{MovieID: 1, PersonID: coppola, Role: models.CreditDirector},
+		{MovieID: 2, PersonID: pacino, Role: models.CreditActor, Character: "Michael Corleone", Billing: 1},
+	} {
+		_, err := repo.InsertCredit(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the same person can't be credited twice for the same part
+	_, err := repo.InsertCredit(ctx, models.Credit{MovieID: 1, PersonID: coppola, Role: models.CreditDirector})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate but got %v", err)
+	}
+
+	credits, err := repo.MovieCredits(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range credits {
+		names = append(names, c.Role+" "+c.Name)
+	}
+	want := []string{"director Francis Ford Coppola", "writer Francis Ford Coppola", "actor Marlon Brando", "actor Al Pacino", "actor 100% Unknown"}
+	if !slices.Equal(names, want) {
+		t.Errorf("expected %v but got %v", want, names)
+	}
+
+	filmography, err := repo.PersonCredits(ctx, pacino)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filmography) != 2 || filmography[0].MovieID != 2 || filmography[0].Release != 1974 ||
+		filmography[1].MovieTitle != "The Godfather" || filmography[1].Character != "Michael Corleone" {
+		t.Errorf("unexpected filmography %+v", filmography)
+	}
+
+	people, err := repo.SearchPeople(ctx, "CORLEONE", 10)
+	if err != nil || len(people) != 0 {
+		t.Errorf("expected nobody but got %v, %v", people, err)
+	}
+	people, _ = repo.SearchPeople(ctx, "a", 2)
+	if len(people) != 2 || people[0].Name != "Al Pacino" || people[1].Name != "Francis Ford Coppola" {
+		t.Errorf("expected the first two people by name but got %+v", people)
+	}
+	people, _ = repo.SearchPeople(ctx, "0%", 10)
+	if len(people) != 1 || people[0].ID != unknown {
+		t.Errorf("expected %% to match as written but got %+v", people)
+	}
+
+	michael := filmography[0]
+	michael.Billing = 3
+	michael.Character = "Michael"
+	err = repo.UpdateCredit(ctx, *michael)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetCredit(ctx, michael.ID)
+	if err != nil || got.Billing != 3 || got.Character != "Michael" || got.Name != "Al Pacino" {
+		t.Errorf("unexpected credit %+v, %v", got, err)
+	}
+
+	writer := credits[1]
+	writer.Role = models.CreditDirector
+	err = repo.UpdateCredit(ctx, *writer)
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate but got %v", err)
+	}
+
+	person, err := repo.GetPerson(ctx, brando)
+	if err != nil {
+		t.Fatal(err)
+	}
+	person.Bio = "Stella!"
+	err = repo.UpdatePerson(ctx, *person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	person, err = repo.GetPerson(ctx, brando)
+	if err != nil || person.Bio != "Stella!" || person.BirthYear != 1924 {
+		t.Errorf("unexpected person %+v, %v", person, err)
+	}
+
+	// deleting a person deletes their credits
+	err = repo.DeletePerson(ctx, coppola)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credits, _ = repo.MovieCredits(ctx, 1)
+	if len(credits) != 3 {
+		t.Errorf("expected 3 credits left but got %d", len(credits))
+	}
+
+	err = repo.DeleteCredit(ctx, credits[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		repo.DeleteCredit(ctx, credits[0].ID),
+		repo.UpdateCredit(ctx, *credits[0]),
+		repo.DeletePerson(ctx, coppola),
+		repo.UpdatePerson(ctx, models.Person{ID: coppola, Name: "Nobody"}),
+	} {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected sql.ErrNoRows but got %v", err)
+		}
+	}
+	if _, err := repo.GetPerson(ctx, coppola); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+	if _, err := repo.GetCredit(ctx, credits[0].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+
+	// and so does deleting a movie
+	err = repo.DeleteMovie(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filmography, _ = repo.PersonCredits(ctx, pacino)
+	if len(filmography) != 1 || filmography[0].MovieID != 1 {
+		t.Errorf("unexpected filmography %+v", filmography)
+	}
+}
+
+func TestMemoryDBRepoPeople(t *testing.T) {
+	repo := NewMemoryDBRepo()
+	repo.Seed(Fixtures{
+		Movies: []models.Movie{
+			{Title: "The Godfather", Release: 1972},
+			{Title: "The Godfather Part II", Release: 1974},
+		},
+	})
+
+	testPeople(t, repo)
+}
+
+func TestSQLiteDBRepoPeople(t *testing.T) {
+	testPeople(t, newTestSQLiteDBRepo(t))
+}
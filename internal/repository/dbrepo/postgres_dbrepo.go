@@ -3,23 +3,169 @@ package dbrepo
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strings"
 	"time"
 	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
 )
 
 type PostgresDBRepo struct {
 	DB *sql.DB
+
+	// Timeout bounds every query; dbTimeout is used when it is zero.
+	Timeout time.Duration
+
+	// PosterBaseURL is prepended to poster file names to make the urls
+	// returned with movies; DefaultPosterBaseURL is used when it is empty.
+	PosterBaseURL string
+
+	// PosterVariantsURL is where resized posters are served from; movies
+	// come without poster variants when it is empty.
+	PosterVariantsURL string
+
+	// tx is set on the copies of the repo handed out by WithTx
+	tx *sql.Tx
 }
 
 const dbTimeout = time.Second * 3
 
+// DefaultPosterBaseURL serves posters from the api's own static directory.
+const DefaultPosterBaseURL = "http://localhost:8080/static/images/"
+
+// NewPostgresDBRepo connects to the Postgres database at dsn.
+func NewPostgresDBRepo(dsn string, timeout time.Duration) (*PostgresDBRepo, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresDBRepo{DB: db, Timeout: timeout}, nil
+}
+
 func (m *PostgresDBRepo) Connection() *sql.DB {
 	return m.DB
 }
 
-func (m *PostgresDBRepo) AllMovies() ([]*models.Movie, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (m *PostgresDBRepo) timeout() time.Duration {
+	if m.Timeout > 0 {
+		return m.Timeout
+	}
+	return dbTimeout
+}
+
+func (m *PostgresDBRepo) posterBaseURL() string {
+	if m.PosterBaseURL != "" {
+		return m.PosterBaseURL
+	}
+	return DefaultPosterBaseURL
+}
+
+// posterURL turns a stored poster file name into its url.
+func (m *PostgresDBRepo) posterURL(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	return m.posterBaseURL() + name
+}
+
+// presentPoster turns the stored poster file name of movie into its url and
+// the urls of its variants.
+func (m *PostgresDBRepo) presentPoster(movie *models.Movie) {
+	movie.PosterVariants = models.NewPosterVariants(m.PosterVariantsURL, movie.Poster)
+	movie.Poster = m.posterURL(movie.Poster)
+}
+
+// posterName undoes posterURL, for posters that are sent back as they were
+// read.
+func (m *PostgresDBRepo) posterName(poster string) string {
+	return strings.TrimPrefix(strings.TrimSpace(poster), m.posterBaseURL())
+}
+
+// querier is the part of *sql.DB and *sql.Tx that the queries need.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the transaction m is bound to, if any, or the pool.
+func (m *PostgresDBRepo) conn() querier {
+	if m.tx != nil {
+		return m.tx
+	}
+	return m.DB
+}
+
+// WithTx runs fn against a copy of the repository bound to a single
+// transaction, committing if fn returns nil and rolling back otherwise.
+// Nested calls join the outer transaction.
+func (m *PostgresDBRepo) WithTx(ctx context.Context, fn func(repo repository.DatabaseRepo) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(&PostgresDBRepo{DB: m.DB, Timeout: m.Timeout, PosterBaseURL: m.PosterBaseURL, PosterVariantsURL: m.PosterVariantsURL, tx: tx})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scopedTx is a transaction that may belong to an enclosing WithTx, in which
+// case Commit and Rollback are left to the owner.
+type scopedTx struct {
+	*sql.Tx
+	owned bool
+}
+
+func (t scopedTx) Commit() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t scopedTx) Rollback() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// begin starts a transaction for a method that needs several statements to
+// apply together, joining the WithTx transaction if there is one.
+func (m *PostgresDBRepo) begin(ctx context.Context) (scopedTx, error) {
+	if m.tx != nil {
+		return scopedTx{Tx: m.tx}, nil
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return scopedTx{}, err
+	}
+
+	return scopedTx{Tx: tx, owned: true}, nil
+}
+
+func (m *PostgresDBRepo) AllMovies(ctx context.Context) ([]*models.Movie, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	query := `
@@ -32,7 +178,7 @@ func (m *PostgresDBRepo) AllMovies() ([]*models.Movie, error) {
     	    title
     `
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.conn().QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -58,10 +204,7 @@ func (m *PostgresDBRepo) AllMovies() ([]*models.Movie, error) {
 		if err != nil {
 			return nil, err
 		}
-		if movie.Poster != "" {
-			cleanPoster := strings.TrimSpace(movie.Poster)
-			movie.Poster = "http://localhost:8080/static/images/" + cleanPoster
-		}
+		m.presentPoster(&movie)
 
 		movie.RuntimeMinutes = movie.RuntimeHours % 60
 		movie.RuntimeHours = movie.RuntimeHours / 60
@@ -72,8 +215,335 @@ func (m *PostgresDBRepo) AllMovies() ([]*models.Movie, error) {
 	return movies, nil
 }
 
-func (m *PostgresDBRepo) OneMovie(id int) (*models.Movie, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// ListMovies returns one page of movies matching filter, along with the total
+// number of matching movies across all pages.
+func (m *PostgresDBRepo) ListMovies(ctx context.Context, filter models.MovieFilter) ([]*models.Movie, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	whereClause, args := movieFilterWhere(filter)
+
+	var total int
+	query := `SELECT COUNT(*) FROM MOVIES m ` + whereClause
+
+	err := m.conn().QueryRowContext(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := models.MovieSortColumns[filter.Sort]
+	if !ok {
+		column = "title"
+	}
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+
+	// the sort column and direction come from a whitelist, never from input
+	query = fmt.Sprintf(`
+		SELECT 
+			m.id, m.title, m.runtime, m.imdb, m.release, m.mpaa, m.description, 
+			COALESCE(m.poster, ''), m.created_at, m.updated_at, m.imdb_id
+		FROM 
+			MOVIES m
+		%s
+		ORDER BY
+			m.%s %s, m.id
+		LIMIT $%d OFFSET $%d
+	`, whereClause, column, direction, len(args)+1, len(args)+2)
+
+	args = append(args, filter.PageSize, filter.Offset())
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	movies := []*models.Movie{}
+
+	for rows.Next() {
+		var movie models.Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&movie.RuntimeHours,
+			&movie.IMDb,
+			&movie.Release,
+			&movie.MPAA,
+			&movie.Description,
+			&movie.Poster,
+			&movie.CreatedAt,
+			&movie.UpdatedAt,
+			&movie.IMDbID,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		m.presentPoster(&movie)
+
+		movie.RuntimeMinutes = movie.RuntimeHours % 60
+		movie.RuntimeHours = movie.RuntimeHours / 60
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return movies, total, nil
+}
+
+// movieFilterWhere builds the WHERE clause, on MOVIES aliased as m, for the
+// filters in filter, and its arguments. Paging and sorting are left to the
+// caller.
+func movieFilterWhere(filter models.MovieFilter) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+
+	// add appends a condition whose placeholder is the next argument
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.GenreID > 0 {
+		add("EXISTS (SELECT 1 FROM MOVIES_GENRES mg WHERE mg.movie_id = m.id AND mg.genre_id = $%d)", filter.GenreID)
+	}
+	if len(filter.MPAA) > 0 {
+		var placeholders []string
+		for _, rating := range filter.MPAA {
+			args = append(args, rating)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		where = append(where, "m.mpaa IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if filter.YearFrom > 0 {
+		add("m.release >= $%d", filter.YearFrom)
+	}
+	if filter.YearTo > 0 {
+		add("m.release <= $%d", filter.YearTo)
+	}
+	if filter.MinIMDb > 0 {
+		add("m.imdb >= $%d", filter.MinIMDb)
+	}
+	if filter.RuntimeMin > 0 {
+		add("m.runtime >= $%d", filter.RuntimeMin)
+	}
+	if filter.RuntimeMax > 0 {
+		add("m.runtime <= $%d", filter.RuntimeMax)
+	}
+
+	if len(where) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(where, " AND "), args
+}
+
+// EachMovie calls fn with every movie matching filter, genres included, in
+// title order, reading them from a single query one row at a time so that the
+// whole catalog is never held in memory. Paging and sorting in filter are
+// ignored, and so is the repository timeout: a large export is bounded by ctx
+// alone. Iteration stops at the first error from fn, which is returned.
+func (m *PostgresDBRepo) EachMovie(ctx context.Context, filter models.MovieFilter, fn func(movie *models.Movie) error) error {
+	whereClause, args := movieFilterWhere(filter)
+
+	// one row per movie and genre; rows of the same movie are adjacent
+	query := `
+		SELECT
+			m.id, m.title, m.runtime, m.imdb, m.release, m.mpaa, m.description,
+			COALESCE(m.poster, ''), m.created_at, m.updated_at, m.imdb_id,
+			g.id, g.genre
+		FROM
+			MOVIES m
+			LEFT JOIN MOVIES_GENRES mg ON mg.movie_id = m.id
+			LEFT JOIN GENRES g ON g.id = mg.genre_id
+		` + whereClause + `
+		ORDER BY
+			m.title, m.id, g.genre
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *models.Movie
+	for rows.Next() {
+		var movie models.Movie
+		var genreID sql.NullInt64
+		var genre sql.NullString
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&movie.RuntimeHours,
+			&movie.IMDb,
+			&movie.Release,
+			&movie.MPAA,
+			&movie.Description,
+			&movie.Poster,
+			&movie.CreatedAt,
+			&movie.UpdatedAt,
+			&movie.IMDbID,
+			&genreID,
+			&genre,
+		)
+		if err != nil {
+			return err
+		}
+
+		if current == nil || current.ID != movie.ID {
+			if current != nil {
+				err = fn(current)
+				if err != nil {
+					return err
+				}
+			}
+
+			m.presentPoster(&movie)
+			movie.RuntimeMinutes = movie.RuntimeHours % 60
+			movie.RuntimeHours = movie.RuntimeHours / 60
+			movie.Genres = []*models.Genre{}
+
+			current = &movie
+		}
+
+		if genreID.Valid {
+			current.Genres = append(current.Genres, &models.Genre{ID: int(genreID.Int64), Genre: genre.String})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if current != nil {
+		return fn(current)
+	}
+	return nil
+}
+
+// movieDocument is the weighted full-text document for a movie, with the
+// title ranked above the description. It must match the expression of the
+// search index on MOVIES for the index to be used.
+const movieDocument = `(setweight(to_tsvector('english', COALESCE(m.title, '')), 'A') ||
+	setweight(to_tsvector('english', COALESCE(m.description, '')), 'B'))`
+
+// headlineOptions makes ts_headline delimit matches for markup to turn into
+// tags after escaping.
+const headlineOptions = `'StartSel=` + markStart + `, StopSel=` + markStop + `, MaxWords=35, MinWords=15, ShortWord=2'`
+
+// SearchMovies ranks movies against query using full-text search. When that
+// finds nothing, typically because of a typo, it falls back to trigram
+// similarity on the title (requires the pg_trgm extension).
+func (m *PostgresDBRepo) SearchMovies(ctx context.Context, query string, limit int) ([]*models.MovieSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		SELECT 
+			m.id, m.title, m.runtime, m.imdb, m.release, m.mpaa, m.description, 
+			COALESCE(m.poster, ''), m.created_at, m.updated_at, m.imdb_id,
+			ts_rank(` + movieDocument + `, q),
+			ts_headline('english', m.title, q, ` + headlineOptions + `),
+			ts_headline('english', m.description, q, ` + headlineOptions + `)
+		FROM 
+			MOVIES m, websearch_to_tsquery('english', $1) q
+		WHERE
+			` + movieDocument + ` @@ q
+		ORDER BY
+			12 DESC, m.title
+		LIMIT $2
+	`
+
+	results, err := m.scanSearchResults(ctx, stmt, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > 0 {
+		for _, r := range results {
+			r.TitleHighlight = markup(r.TitleHighlight)
+			r.Snippet = markup(r.Snippet)
+		}
+		return results, nil
+	}
+
+	stmt = `
+		SELECT 
+			m.id, m.title, m.runtime, m.imdb, m.release, m.mpaa, m.description, 
+			COALESCE(m.poster, ''), m.created_at, m.updated_at, m.imdb_id,
+			similarity(m.title, $1),
+			m.title,
+			ts_headline('english', m.description, plainto_tsquery('english', $1), ` + headlineOptions + `)
+		FROM 
+			MOVIES m
+		WHERE
+			m.title % $1
+		ORDER BY
+			12 DESC, m.title
+		LIMIT $2
+	`
+
+	results, err = m.scanSearchResults(ctx, stmt, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		r.TitleHighlight = markup(r.TitleHighlight)
+		r.Snippet = markup(r.Snippet)
+		r.Fuzzy = true
+	}
+
+	return results, nil
+}
+
+func (m *PostgresDBRepo) scanSearchResults(ctx context.Context, stmt string, args ...interface{}) ([]*models.MovieSearchResult, error) {
+	rows, err := m.conn().QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.MovieSearchResult{}
+
+	for rows.Next() {
+		var r models.MovieSearchResult
+		err := rows.Scan(
+			&r.ID,
+			&r.Title,
+			&r.RuntimeHours,
+			&r.IMDb,
+			&r.Release,
+			&r.MPAA,
+			&r.Description,
+			&r.Poster,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+			&r.IMDbID,
+			&r.Rank,
+			&r.TitleHighlight,
+			&r.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+		m.presentPoster(&r.Movie)
+
+		r.RuntimeMinutes = r.RuntimeHours % 60
+		r.RuntimeHours = r.RuntimeHours / 60
+
+		results = append(results, &r)
+	}
+
+	return results, rows.Err()
+}
+
+func (m *PostgresDBRepo) OneMovie(ctx context.Context, id int) (*models.Movie, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	query := `
@@ -86,7 +556,7 @@ func (m *PostgresDBRepo) OneMovie(id int) (*models.Movie, error) {
 		    ID = $1
     `
 
-	row := m.DB.QueryRowContext(ctx, query, id)
+	row := m.conn().QueryRowContext(ctx, query, id)
 
 	var movie models.Movie
 	err := row.Scan(
@@ -106,10 +576,7 @@ func (m *PostgresDBRepo) OneMovie(id int) (*models.Movie, error) {
 		return nil, err
 	}
 
-	if movie.Poster != "" {
-		cleanPoster := strings.TrimSpace(movie.Poster)
-		movie.Poster = "http://localhost:8080/static/images/" + cleanPoster
-	}
+	m.presentPoster(&movie)
 
 	movie.RuntimeMinutes = movie.RuntimeHours % 60
 	movie.RuntimeHours = movie.RuntimeHours / 60
@@ -130,7 +597,7 @@ func (m *PostgresDBRepo) OneMovie(id int) (*models.Movie, error) {
 		    g.genre
 `
 
-	rows, err := m.DB.QueryContext(ctx, query, id)
+	rows, err := m.conn().QueryContext(ctx, query, id)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -155,8 +622,8 @@ func (m *PostgresDBRepo) OneMovie(id int) (*models.Movie, error) {
 	return &movie, err
 }
 
-func (m *PostgresDBRepo) OneMovieForEdit(id int) (*models.Movie, []*models.Genre, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (m *PostgresDBRepo) OneMovieForEdit(ctx context.Context, id int) (*models.Movie, []*models.Genre, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	query := `
@@ -169,7 +636,7 @@ func (m *PostgresDBRepo) OneMovieForEdit(id int) (*models.Movie, []*models.Genre
 		    ID = $1
     `
 
-	row := m.DB.QueryRowContext(ctx, query, id)
+	row := m.conn().QueryRowContext(ctx, query, id)
 
 	var movie models.Movie
 	err := row.Scan(
@@ -189,10 +656,7 @@ func (m *PostgresDBRepo) OneMovieForEdit(id int) (*models.Movie, []*models.Genre
 		return nil, nil, err
 	}
 
-	if movie.Poster != "" {
-		cleanPoster := strings.TrimSpace(movie.Poster)
-		movie.Poster = "http://localhost:8080/static/images/" + cleanPoster
-	}
+	m.presentPoster(&movie)
 
 	movie.RuntimeMinutes = movie.RuntimeHours % 60
 	movie.RuntimeHours = movie.RuntimeHours / 60
@@ -213,7 +677,7 @@ func (m *PostgresDBRepo) OneMovieForEdit(id int) (*models.Movie, []*models.Genre
 		    g.genre
 `
 
-	rows, err := m.DB.QueryContext(ctx, query, id)
+	rows, err := m.conn().QueryContext(ctx, query, id)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, nil, err
 	}
@@ -247,7 +711,7 @@ func (m *PostgresDBRepo) OneMovieForEdit(id int) (*models.Movie, []*models.Genre
 		ORDER BY
 		    GENRE
     `
-	gRows, err := m.DB.QueryContext(ctx, query)
+	gRows, err := m.conn().QueryContext(ctx, query)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -270,13 +734,13 @@ func (m *PostgresDBRepo) OneMovieForEdit(id int) (*models.Movie, []*models.Genre
 
 }
 
-func (m *PostgresDBRepo) GetUserByID(id int) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (m *PostgresDBRepo) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	query := `
 		SELECT
-			ID, EMAIL, FIRST_NAME, LAST_NAME, PASSWORD,
+			ID, EMAIL, FIRST_NAME, LAST_NAME, PASSWORD, DISABLED,
             CREATED_AT, UPDATED_AT 
 		FROM
 		    USERS
@@ -285,7 +749,7 @@ func (m *PostgresDBRepo) GetUserByID(id int) (*models.User, error) {
 	`
 
 	var user models.User
-	row := m.DB.QueryRowContext(ctx, query, id)
+	row := m.conn().QueryRowContext(ctx, query, id)
 
 	err := row.Scan(
 		&user.ID,
@@ -293,6 +757,7 @@ func (m *PostgresDBRepo) GetUserByID(id int) (*models.User, error) {
 		&user.FirstName,
 		&user.LastName,
 		&user.Password,
+		&user.Disabled,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -301,25 +766,30 @@ func (m *PostgresDBRepo) GetUserByID(id int) (*models.User, error) {
 		return nil, err
 	}
 
+	user.Roles, err = m.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &user, nil
 }
 
-func (m *PostgresDBRepo) GetUserByEmail(email string) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (m *PostgresDBRepo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	query := `
 		SELECT
-			ID, EMAIL, FIRST_NAME, LAST_NAME, PASSWORD,
+			ID, EMAIL, FIRST_NAME, LAST_NAME, PASSWORD, DISABLED,
             CREATED_AT, UPDATED_AT 
 		FROM
 		    USERS
 		WHERE
-		    EMAIL = $1
+		    LOWER(EMAIL) = LOWER($1)
 	`
 
 	var user models.User
-	row := m.DB.QueryRowContext(ctx, query, email)
+	row := m.conn().QueryRowContext(ctx, query, email)
 
 	err := row.Scan(
 		&user.ID,
@@ -327,6 +797,7 @@ func (m *PostgresDBRepo) GetUserByEmail(email string) (*models.User, error) {
 		&user.FirstName,
 		&user.LastName,
 		&user.Password,
+		&user.Disabled,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -335,84 +806,466 @@ func (m *PostgresDBRepo) GetUserByEmail(email string) (*models.User, error) {
 		return nil, err
 	}
 
+	user.Roles, err = m.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &user, nil
 }
 
-func (m *PostgresDBRepo) GetMovieByID(id int) (*models.Movie, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (m *PostgresDBRepo) UpdateUserPassword(ctx context.Context, id int, hash string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
-	query := `
-		SELECT
-			ID, TITLE, RUNTIME, IMDB, RELEASE, MPAA, DESCRIPTION, CREATED_AT, UPDATED_AT, POSTER, IMDB_ID
-		FROM 
-		    MOVIES
-		WHERE 
-		    ID = $1
-`
+	stmt := `
+		UPDATE
+			USERS
+		SET
+			PASSWORD = $1, UPDATED_AT = $2
+		WHERE
+		    ID = $3
+	`
 
-	var movie models.Movie
-	row := m.DB.QueryRowContext(ctx, query, id)
+	_, err := m.conn().ExecContext(ctx, stmt, hash, time.Now(), id)
+	if err != nil {
+		return err
+	}
 
-	var tempVar int
+	return nil
+}
 
-	err := row.Scan(
-		&movie.ID,
-		&movie.Title,
-		&tempVar,
-		&movie.IMDb,
-		&movie.Release,
-		&movie.MPAA,
-		&movie.Description,
-		&movie.CreatedAt,
-		&movie.UpdatedAt,
-		&movie.Poster,
-		&movie.IMDbID,
+func (m *PostgresDBRepo) InsertUser(ctx context.Context, user models.User) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		INSERT INTO
+			USERS (EMAIL, FIRST_NAME, LAST_NAME, PASSWORD, CREATED_AT, UPDATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5, $6)
+		RETURNING
+			ID
+	`
+
+	var newID int
+	err := m.conn().QueryRowContext(ctx, stmt,
+		user.Email,
+		user.FirstName,
+		user.LastName,
+		user.Password,
+		user.CreatedAt,
+		user.UpdatedAt,
+	).Scan(&newID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if len(user.Roles) > 0 {
+		err = m.SetUserRoles(ctx, newID, user.Roles)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return newID, nil
+}
+
+// SetUserDisabled disables or re-enables a user. Disabling also revokes every
+// session the user has, so they are logged out once their access token
+// expires.
+func (m *PostgresDBRepo) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE USERS SET DISABLED = $1, UPDATED_AT = $2 WHERE ID = $3`,
+		disabled, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if disabled {
+		_, err = tx.ExecContext(ctx, `UPDATE SESSIONS SET REVOKED_AT = $1 WHERE USER_ID = $2 AND REVOKED_AT IS NULL`,
+			time.Now(), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) AllUsers(ctx context.Context) ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			ID, EMAIL, FIRST_NAME, LAST_NAME, DISABLED, CREATED_AT, UPDATED_AT
+		FROM
+		    USERS
+		ORDER BY
+		    LAST_NAME, FIRST_NAME
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.FirstName,
+			&user.LastName,
+			&user.Disabled,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		user.Roles, err = m.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return users, nil
+}
+
+func (m *PostgresDBRepo) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			ROLE
+		FROM
+		    USER_ROLES
+		WHERE
+		    USER_ID = $1
+		ORDER BY
+		    ROLE
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+
+	for rows.Next() {
+		var role string
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (m *PostgresDBRepo) SetUserRoles(ctx context.Context, userID int, roles []string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		DELETE FROM
+		           USER_ROLES
+		WHERE
+		    USER_ID = $1
+	`
+
+	_, err = tx.ExecContext(ctx, stmt, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		stmt := `
+			INSERT INTO
+				USER_ROLES (USER_ID, ROLE)
+			VALUES
+			    ($1, $2)
+		`
+		_, err := tx.ExecContext(ctx, stmt, userID, role)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) InsertSession(ctx context.Context, session models.Session) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		INSERT INTO
+			SESSIONS (ID, FAMILY_ID, USER_ID, EXPIRES_AT, CREATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5)
+	`
+
+	_, err := m.conn().ExecContext(ctx, stmt,
+		session.ID,
+		session.FamilyID,
+		session.UserID,
+		session.ExpiresAt,
+		session.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *PostgresDBRepo) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			ID, FAMILY_ID, USER_ID, EXPIRES_AT, REVOKED_AT,
+			COALESCE(REPLACED_BY, ''), CREATED_AT
+		FROM
+		    SESSIONS
+		WHERE
+		    ID = $1
+	`
+
+	var session models.Session
+	row := m.conn().QueryRowContext(ctx, query, id)
+
+	err := row.Scan(
+		&session.ID,
+		&session.FamilyID,
+		&session.UserID,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+		&session.ReplacedBy,
+		&session.CreatedAt,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if movie.Poster != "" {
-		cleanPoster := strings.TrimSpace(movie.Poster)
-		movie.Poster = "http://localhost:8080/static/images/" + cleanPoster
+	return &session, nil
+}
+
+// RotateSession marks oldID as replaced by next and stores next, in one
+// transaction. Only one caller can win the rotation of a given session; the
+// others get ErrSessionRotated.
+func (m *PostgresDBRepo) RotateSession(ctx context.Context, oldID string, next models.Session) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		UPDATE
+			SESSIONS
+		SET
+			REVOKED_AT = $1, REPLACED_BY = $2
+		WHERE
+		    ID = $3 AND REVOKED_AT IS NULL
+	`
+
+	res, err := tx.ExecContext(ctx, stmt, time.Now(), next.ID, oldID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrSessionRotated
+	}
+
+	stmt = `
+		INSERT INTO
+			SESSIONS (ID, FAMILY_ID, USER_ID, EXPIRES_AT, CREATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5)
+	`
+
+	_, err = tx.ExecContext(ctx, stmt,
+		next.ID,
+		next.FamilyID,
+		next.UserID,
+		next.ExpiresAt,
+		next.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		UPDATE
+			SESSIONS
+		SET
+			REVOKED_AT = $1
+		WHERE
+		    FAMILY_ID = $2 AND REVOKED_AT IS NULL
+	`
+
+	_, err := m.conn().ExecContext(ctx, stmt, time.Now(), familyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *PostgresDBRepo) GetMovieByID(ctx context.Context, id int) (*models.Movie, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			ID, TITLE, RUNTIME, IMDB, RELEASE, MPAA, DESCRIPTION, CREATED_AT, UPDATED_AT, POSTER, IMDB_ID
+		FROM 
+		    MOVIES
+		WHERE 
+		    ID = $1
+`
+
+	var movie models.Movie
+	row := m.conn().QueryRowContext(ctx, query, id)
+
+	var tempVar int
+
+	err := row.Scan(
+		&movie.ID,
+		&movie.Title,
+		&tempVar,
+		&movie.IMDb,
+		&movie.Release,
+		&movie.MPAA,
+		&movie.Description,
+		&movie.CreatedAt,
+		&movie.UpdatedAt,
+		&movie.Poster,
+		&movie.IMDbID,
+	)
+
+	if err != nil {
+		return nil, err
 	}
 
+	m.presentPoster(&movie)
+
 	movie.RuntimeMinutes = tempVar % 60
 	movie.RuntimeHours = tempVar / 60
 
 	return &movie, nil
 }
 
-func (m *PostgresDBRepo) AllGenres() ([]*models.Genre, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// MovieIDByIMDbID returns the id of the movie with that IMDb id, the oldest
+// one if there are several, or sql.ErrNoRows.
+func (m *PostgresDBRepo) MovieIDByIMDbID(ctx context.Context, imdbID string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `SELECT ID FROM MOVIES WHERE IMDB_ID = $1 ORDER BY ID LIMIT 1`
+
+	var id int
+	err := m.conn().QueryRowContext(ctx, query, imdbID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (m *PostgresDBRepo) AllGenres(ctx context.Context) ([]*models.GenreCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	query := `
 		SELECT 
-			ID, GENRE, CREATED_AT, UPDATED_AT
+			g.ID, g.GENRE, COUNT(mg.movie_id)
 		FROM
-		    GENRES
+		    GENRES g
+		LEFT JOIN
+			MOVIES_GENRES mg
+		ON
+			(mg.genre_id = g.id)
+		GROUP BY
+			g.ID
 		ORDER BY
-		    GENRE
+		    g.GENRE
 `
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.conn().QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var genres []*models.Genre
+	var genres []*models.GenreCount
 
 	for rows.Next() {
-		var g models.Genre
+		var g models.GenreCount
 		err := rows.Scan(
 			&g.ID,
 			&g.Genre,
-			&g.CreatedAt,
-			&g.UpdatedAt,
+			&g.Movies,
 		)
 		if err != nil {
 			return nil, err
@@ -424,67 +1277,1673 @@ func (m *PostgresDBRepo) AllGenres() ([]*models.Genre, error) {
 	return genres, nil
 }
 
-func (m *PostgresDBRepo) InsertMovie(movie models.Movie) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (m *PostgresDBRepo) InsertGenre(ctx context.Context, genre models.Genre) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	stmt := `
 		INSERT INTO
-			MOVIES (title, description, release, runtime, mpaa, imdb, imdb_id, poster, created_at, updated_at)
+			GENRES (genre, created_at, updated_at)
 		VALUES 
-		    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		    ($1, $2, $3)
 		RETURNING 
 			id
 `
 
 	var newID int
-	err := m.DB.QueryRowContext(ctx, stmt,
-		movie.Title,
-		movie.Description,
-		movie.Release,
-		movie.RuntimeHours,
-		movie.MPAA,
-		movie.IMDb,
-		movie.IMDbID,
-		movie.Poster,
-		movie.CreatedAt,
-		movie.UpdatedAt,
+	err := m.conn().QueryRowContext(ctx, stmt,
+		genre.Genre,
+		genre.CreatedAt,
+		genre.UpdatedAt,
 	).Scan(&newID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
 	return newID, nil
 }
 
-func (m *PostgresDBRepo) UpdateMovieGenres(id int, genreIDs []int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (m *PostgresDBRepo) UpdateGenre(ctx context.Context, genre models.Genre) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
 	defer cancel()
 
 	stmt := `
-		DELETE FROM
-		           movies_genres
-		WHERE 
-		    movie_id = $1
+		UPDATE
+			GENRES
+		SET
+			genre = $1, updated_at = $2
+		WHERE
+		    id = $3
 `
 
-	_, err := m.DB.ExecContext(ctx, stmt, id)
+	res, err := m.conn().ExecContext(ctx, stmt, genre.Genre, genre.UpdatedAt, genre.ID)
 	if err != nil {
-		return err
+		return translate(err)
 	}
 
-	for _, n := range genreIDs {
-		stmt := `
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteGenre removes a genre and unlinks it from every movie.
+func (m *PostgresDBRepo) DeleteGenre(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movies_genres WHERE genre_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// MergeGenres moves every movie tagged with fromID over to intoID, skipping
+// movies that already have both, and then deletes fromID.
+func (m *PostgresDBRepo) MergeGenres(ctx context.Context, fromID, intoID int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		INSERT INTO
+			movies_genres (movie_id, genre_id)
+		SELECT
+			mg.movie_id, $2
+		FROM
+			movies_genres mg
+		WHERE
+			mg.genre_id = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM movies_genres x WHERE x.movie_id = mg.movie_id AND x.genre_id = $2
+			)
+`
+
+	_, err = tx.ExecContext(ctx, stmt, fromID, intoID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movies_genres WHERE genre_id = $1`, fromID)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1`, fromID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// UnknownGenreIDs returns the ids in ids that don't match a genre.
+func (m *PostgresDBRepo) UnknownGenreIDs(ctx context.Context, ids []int) ([]int, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	var placeholders []string
+	var args []interface{}
+	for _, id := range ids {
+		args = append(args, id)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	query := `SELECT id FROM genres WHERE id IN (` + strings.Join(placeholders, ", ") + `)`
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var unknown []int
+	for _, id := range ids {
+		if !found[id] {
+			unknown = append(unknown, id)
+			found[id] = true // report each id once
+		}
+	}
+
+	return unknown, nil
+}
+
+func (m *PostgresDBRepo) MovieGenreIDs(ctx context.Context) (map[int][]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			M.ID, MG.GENRE_ID
+		FROM
+		    MOVIES M
+		    LEFT JOIN MOVIES_GENRES MG ON MG.MOVIE_ID = M.ID
+		ORDER BY
+		    M.ID, MG.GENRE_ID
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := make(map[int][]int)
+	for rows.Next() {
+		var movieID int
+		var genreID sql.NullInt64
+		err := rows.Scan(&movieID, &genreID)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := genres[movieID]; !ok {
+			genres[movieID] = []int{}
+		}
+		if genreID.Valid {
+			genres[movieID] = append(genres[movieID], int(genreID.Int64))
+		}
+	}
+
+	return genres, rows.Err()
+}
+
+func (m *PostgresDBRepo) InsertMovie(ctx context.Context, movie models.Movie) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		INSERT INTO
+			MOVIES (title, description, release, runtime, mpaa, imdb, imdb_id, poster, created_at, updated_at)
+		VALUES 
+		    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING 
+			id
+`
+
+	var newID int
+	err := m.conn().QueryRowContext(ctx, stmt,
+		movie.Title,
+		movie.Description,
+		movie.Release,
+		movie.Runtime(),
+		movie.MPAA,
+		movie.IMDb,
+		movie.IMDbID,
+		m.posterName(movie.Poster),
+		movie.CreatedAt,
+		movie.UpdatedAt,
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// UpdateMovieGenres replaces the genres of a movie. The links are written in
+// a single statement, in the same transaction as the delete.
+func (m *PostgresDBRepo) UpdateMovieGenres(ctx context.Context, id int, genreIDs []int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		DELETE FROM
+		           movies_genres
+		WHERE 
+		    movie_id = $1
+`
+
+	_, err = tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	if len(genreIDs) > 0 {
+		args := []interface{}{id}
+		var values []string
+		seen := make(map[int]bool)
+		for _, n := range genreIDs {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+
+			args = append(args, n)
+			values = append(values, fmt.Sprintf("($1, $%d)", len(args)))
+		}
+
+		stmt = `
 			INSERT INTO
 				movies_genres (movie_id, genre_id)
 			VALUES 
-			    ($1, $2)
+			    ` + strings.Join(values, ", ") + `
 `
-		_, err := m.DB.ExecContext(ctx, stmt, id, n)
+		_, err = tx.ExecContext(ctx, stmt, args...)
 		if err != nil {
 			return err
 		}
 	}
 
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) UpdateMovie(ctx context.Context, movie models.Movie) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	// posters come back from the read queries with the url prefix attached
+	poster := m.posterName(movie.Poster)
+
+	stmt := `
+		UPDATE
+			MOVIES
+		SET
+			title = $1, description = $2, release = $3, runtime = $4, mpaa = $5,
+			imdb = $6, imdb_id = $7, poster = $8, updated_at = $9
+		WHERE
+		    id = $10
+`
+
+	res, err := m.conn().ExecContext(ctx, stmt,
+		movie.Title,
+		movie.Description,
+		movie.Release,
+		movie.Runtime(),
+		movie.MPAA,
+		movie.IMDb,
+		movie.IMDbID,
+		poster,
+		movie.UpdatedAt,
+		movie.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateMoviePoster sets the poster file name of a movie, returning
+// sql.ErrNoRows if there is no such movie.
+func (m *PostgresDBRepo) UpdateMoviePoster(ctx context.Context, id int, poster string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `UPDATE MOVIES SET poster = $1, updated_at = $2 WHERE id = $3`
+
+	res, err := m.conn().ExecContext(ctx, stmt, m.posterName(poster), time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (m *PostgresDBRepo) DeleteMovie(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		DELETE FROM
+		           movies_genres
+		WHERE 
+		    movie_id = $1
+`
+
+	_, err = tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	stmt = `
+		DELETE FROM
+		           movies
+		WHERE 
+		    id = $1
+`
+
+	res, err := tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) Watchlist(ctx context.Context, userID int) ([]*models.WatchlistEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			W.MOVIE_ID, W.NOTES, W.WATCHED_AT, W.CREATED_AT,
+			M.ID, M.TITLE, M.RUNTIME, M.IMDB, M.RELEASE, M.MPAA, M.DESCRIPTION, COALESCE(M.POSTER, ''), M.IMDB_ID
+		FROM
+		    WATCHLIST W
+		    JOIN MOVIES M ON M.ID = W.MOVIE_ID
+		WHERE
+		    W.USER_ID = $1
+		ORDER BY
+		    W.POSITION, W.CREATED_AT
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.WatchlistEntry
+	for rows.Next() {
+		var entry models.WatchlistEntry
+		var movie models.Movie
+		var runtime int
+
+		err := rows.Scan(
+			&entry.MovieID,
+			&entry.Notes,
+			&entry.WatchedAt,
+			&entry.AddedAt,
+			&movie.ID,
+			&movie.Title,
+			&runtime,
+			&movie.IMDb,
+			&movie.Release,
+			&movie.MPAA,
+			&movie.Description,
+			&movie.Poster,
+			&movie.IMDbID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		m.presentPoster(&movie)
+		movie.RuntimeMinutes = runtime % 60
+		movie.RuntimeHours = runtime / 60
+
+		// positions are stored with gaps left by deleted movies
+		entry.Position = len(entries) + 1
+		entry.Watched = entry.WatchedAt != nil
+		entry.Movie = &movie
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (m *PostgresDBRepo) WatchlistMovieIDs(ctx context.Context, userID int) (map[int]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	ids, err := m.watchlistOrder(ctx, m.conn(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// watchlistOrder returns the movie ids on a user's watchlist in order.
+func (m *PostgresDBRepo) watchlistOrder(ctx context.Context, q querier, userID int) ([]int, error) {
+	query := `
+		SELECT
+			MOVIE_ID
+		FROM
+		    WATCHLIST
+		WHERE
+		    USER_ID = $1
+		ORDER BY
+		    POSITION, CREATED_AT
+	`
+
+	rows, err := q.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// renumberWatchlist stores ids as the order of a user's watchlist.
+func (m *PostgresDBRepo) renumberWatchlist(ctx context.Context, q querier, userID int, ids []int) error {
+	stmt := `
+		UPDATE
+			WATCHLIST
+		SET
+		    POSITION = $1
+		WHERE
+		    USER_ID = $2 AND MOVIE_ID = $3 AND POSITION <> $1
+	`
+
+	for i, id := range ids {
+		_, err := q.ExecContext(ctx, stmt, i+1, userID, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *PostgresDBRepo) SaveWatchlistEntry(ctx context.Context, userID int, entry models.WatchlistEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM MOVIES WHERE ID = $1)`, entry.MovieID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	ids, err := m.watchlistOrder(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	ids = placeInList(ids, entry.MovieID, entry.Position)
+
+	stmt := `
+		INSERT INTO
+			WATCHLIST (USER_ID, MOVIE_ID, POSITION, NOTES, WATCHED_AT, CREATED_AT, UPDATED_AT)
+		VALUES
+		    ($1, $2, 0, $3, $4, $5, $5)
+		ON CONFLICT (USER_ID, MOVIE_ID) DO UPDATE SET
+			NOTES = EXCLUDED.NOTES,
+			WATCHED_AT = EXCLUDED.WATCHED_AT,
+			UPDATED_AT = EXCLUDED.UPDATED_AT
+	`
+
+	_, err = tx.ExecContext(ctx, stmt, userID, entry.MovieID, entry.Notes, entry.WatchedAt, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = m.renumberWatchlist(ctx, tx, userID, ids)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) RemoveFromWatchlist(ctx context.Context, userID, movieID int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		DELETE FROM
+		           WATCHLIST
+		WHERE
+		    USER_ID = $1 AND MOVIE_ID = $2
+	`
+
+	res, err := tx.ExecContext(ctx, stmt, userID, movieID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	// close the gap it left
+	ids, err := m.watchlistOrder(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	err = m.renumberWatchlist(ctx, tx, userID, ids)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) InsertReview(ctx context.Context, review models.Review) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		INSERT INTO
+			REVIEWS (MOVIE_ID, USER_ID, RATING, BODY, STATUS, FLAG_REASON, CREATED_AT, UPDATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING
+			ID
+	`
+
+	var id int
+	err := m.conn().QueryRowContext(ctx, stmt,
+		review.MovieID,
+		review.UserID,
+		review.Rating,
+		review.Body,
+		review.Status,
+		review.FlagReason,
+		review.CreatedAt,
+		review.UpdatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	return id, nil
+}
+
+func (m *PostgresDBRepo) UpdateReview(ctx context.Context, review models.Review) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		UPDATE
+			REVIEWS
+		SET
+		    RATING = $1, BODY = $2, STATUS = $3, FLAG_REASON = $4, UPDATED_AT = $5
+		WHERE
+		    MOVIE_ID = $6 AND USER_ID = $7
+	`
+
+	res, err := m.conn().ExecContext(ctx, stmt,
+		review.Rating,
+		review.Body,
+		review.Status,
+		review.FlagReason,
+		review.UpdatedAt,
+		review.MovieID,
+		review.UserID,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (m *PostgresDBRepo) DeleteReview(ctx context.Context, movieID, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		DELETE FROM
+		           REVIEWS
+		WHERE
+		    MOVIE_ID = $1 AND USER_ID = $2
+	`
+
+	res, err := m.conn().ExecContext(ctx, stmt, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// reviewColumns are the columns scanned by scanReview, from REVIEWS R joined
+// with USERS U.
+const reviewColumns = `
+	R.ID, R.MOVIE_ID, R.USER_ID, U.FIRST_NAME, U.LAST_NAME, R.RATING, R.BODY, R.STATUS, R.FLAG_REASON,
+	R.CREATED_AT, R.UPDATED_AT
+`
+
+func scanReview(row rowScanner) (*models.Review, error) {
+	var review models.Review
+	var first, last string
+
+	err := row.Scan(
+		&review.ID,
+		&review.MovieID,
+		&review.UserID,
+		&first,
+		&last,
+		&review.Rating,
+		&review.Body,
+		&review.Status,
+		&review.FlagReason,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	review.Author = authorName(first, last)
+	return &review, nil
+}
+
+func (m *PostgresDBRepo) GetReview(ctx context.Context, movieID, userID int) (*models.Review, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT` + reviewColumns + `
+		FROM
+		    REVIEWS R
+		    JOIN USERS U ON U.ID = R.USER_ID
+		WHERE
+		    R.MOVIE_ID = $1 AND R.USER_ID = $2
+	`
+
+	return scanReview(m.conn().QueryRowContext(ctx, query, movieID, userID))
+}
+
+func (m *PostgresDBRepo) GetReviewByID(ctx context.Context, id int) (*models.Review, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT` + reviewColumns + `
+		FROM
+		    REVIEWS R
+		    JOIN USERS U ON U.ID = R.USER_ID
+		WHERE
+		    R.ID = $1
+	`
+
+	return scanReview(m.conn().QueryRowContext(ctx, query, id))
+}
+
+func (m *PostgresDBRepo) ListReviews(ctx context.Context, filter models.ReviewFilter) ([]*models.Review, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.MovieID != 0 {
+		where = append(where, "R.MOVIE_ID = "+arg(filter.MovieID))
+	}
+	if len(filter.Statuses) > 0 {
+		var placeholders []string
+		for _, s := range filter.Statuses {
+			placeholders = append(placeholders, arg(s))
+		}
+		where = append(where, "R.STATUS IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	err := m.conn().QueryRowContext(ctx, `SELECT COUNT(*) FROM REVIEWS R `+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order := "DESC"
+	if filter.OldestFirst {
+		order = "ASC"
+	}
+
+	query := `
+		SELECT` + reviewColumns + `
+		FROM
+		    REVIEWS R
+		    JOIN USERS U ON U.ID = R.USER_ID
+		` + whereClause + `
+		ORDER BY
+		    R.CREATED_AT ` + order + `, R.ID ` + order + `
+		LIMIT ` + arg(filter.PageSize) + ` OFFSET ` + arg(filter.Offset())
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reviews := []*models.Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reviews = append(reviews, review)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return reviews, total, nil
+}
+
+func (m *PostgresDBRepo) ModerateReview(ctx context.Context, action models.ModerationAction) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `SELECT STATUS FROM REVIEWS WHERE ID = $1`, action.ReviewID).Scan(&action.FromStatus)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		UPDATE
+			REVIEWS
+		SET
+		    STATUS = $1, FLAG_REASON = $2, UPDATED_AT = $3
+		WHERE
+		    ID = $4
+	`
+
+	_, err = tx.ExecContext(ctx, stmt, action.ToStatus, moderatedFlagReason(action), action.CreatedAt, action.ReviewID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `
+		INSERT INTO
+			REVIEW_ACTIONS (REVIEW_ID, ADMIN, FROM_STATUS, TO_STATUS, NOTE, CREATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = tx.ExecContext(ctx, stmt,
+		action.ReviewID,
+		action.Admin,
+		action.FromStatus,
+		action.ToStatus,
+		action.Note,
+		action.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) ReviewActions(ctx context.Context, reviewID int) ([]*models.ModerationAction, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			ID, REVIEW_ID, ADMIN, FROM_STATUS, TO_STATUS, NOTE, CREATED_AT
+		FROM
+		    REVIEW_ACTIONS
+		WHERE
+		    REVIEW_ID = $1
+		ORDER BY
+		    CREATED_AT DESC, ID DESC
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*models.ModerationAction{}
+	for rows.Next() {
+		var a models.ModerationAction
+		err := rows.Scan(&a.ID, &a.ReviewID, &a.Admin, &a.FromStatus, &a.ToStatus, &a.Note, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, &a)
+	}
+
+	return actions, rows.Err()
+}
+
+func (m *PostgresDBRepo) CommunityRatings(ctx context.Context, movieIDs []int) (map[int]models.CommunityRating, error) {
+	ratings := make(map[int]models.CommunityRating)
+	if len(movieIDs) == 0 {
+		return ratings, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	var placeholders []string
+	var args []interface{}
+	for _, id := range movieIDs {
+		args = append(args, id)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	// summed rather than averaged, so both backends return integers
+	query := `
+		SELECT
+			MOVIE_ID, SUM(RATING), COUNT(*)
+		FROM
+		    REVIEWS
+		WHERE
+		    MOVIE_ID IN (` + strings.Join(placeholders, ", ") + `)
+		GROUP BY
+			MOVIE_ID
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, sum, votes int
+		err := rows.Scan(&id, &sum, &votes)
+		if err != nil {
+			return nil, err
+		}
+		ratings[id] = communityRating(sum, votes)
+	}
+
+	return ratings, rows.Err()
+}
+
+func (m *PostgresDBRepo) InsertComment(ctx context.Context, comment models.Comment) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		INSERT INTO
+			COMMENTS (MOVIE_ID, USER_ID, BODY, STATUS, FLAG_REASON, CREATED_AT, UPDATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING
+			ID
+	`
+
+	var id int
+	err := m.conn().QueryRowContext(ctx, stmt,
+		comment.MovieID,
+		comment.UserID,
+		comment.Body,
+		comment.Status,
+		comment.FlagReason,
+		comment.CreatedAt,
+		comment.UpdatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	return id, nil
+}
+
+// commentColumns are the columns scanned by scanComment, from COMMENTS C
+// joined with USERS U.
+const commentColumns = `
+	C.ID, C.MOVIE_ID, C.USER_ID, U.FIRST_NAME, U.LAST_NAME, C.BODY, C.STATUS, C.FLAG_REASON,
+	(SELECT COUNT(*) FROM COMMENT_REPORTS CR WHERE CR.COMMENT_ID = C.ID),
+	C.CREATED_AT, C.UPDATED_AT
+`
+
+func scanComment(row rowScanner) (*models.Comment, error) {
+	var comment models.Comment
+	var first, last string
+
+	err := row.Scan(
+		&comment.ID,
+		&comment.MovieID,
+		&comment.UserID,
+		&first,
+		&last,
+		&comment.Body,
+		&comment.Status,
+		&comment.FlagReason,
+		&comment.Reports,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.Author = authorName(first, last)
+	return &comment, nil
+}
+
+func (m *PostgresDBRepo) GetComment(ctx context.Context, id int) (*models.Comment, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT` + commentColumns + `
+		FROM
+		    COMMENTS C
+		    JOIN USERS U ON U.ID = C.USER_ID
+		WHERE
+		    C.ID = $1
+	`
+
+	return scanComment(m.conn().QueryRowContext(ctx, query, id))
+}
+
+func (m *PostgresDBRepo) ListComments(ctx context.Context, filter models.CommentFilter) ([]*models.Comment, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.MovieID != 0 {
+		where = append(where, "C.MOVIE_ID = "+arg(filter.MovieID))
+	}
+	if len(filter.Statuses) > 0 {
+		var placeholders []string
+		for _, s := range filter.Statuses {
+			placeholders = append(placeholders, arg(s))
+		}
+		where = append(where, "C.STATUS IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	err := m.conn().QueryRowContext(ctx, `SELECT COUNT(*) FROM COMMENTS C `+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order := "DESC"
+	if filter.OldestFirst {
+		order = "ASC"
+	}
+
+	query := `
+		SELECT` + commentColumns + `
+		FROM
+		    COMMENTS C
+		    JOIN USERS U ON U.ID = C.USER_ID
+		` + whereClause + `
+		ORDER BY
+		    C.CREATED_AT ` + order + `, C.ID ` + order + `
+		LIMIT ` + arg(filter.PageSize) + ` OFFSET ` + arg(filter.Offset())
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		comments = append(comments, comment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return comments, total, nil
+}
+
+func (m *PostgresDBRepo) ReportComment(ctx context.Context, report models.CommentReport) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT STATUS FROM COMMENTS WHERE ID = $1`, report.CommentID).Scan(&status)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		INSERT INTO
+			COMMENT_REPORTS (COMMENT_ID, USER_ID, REASON, CREATED_AT)
+		VALUES
+		    ($1, $2, $3, $4)
+	`
+
+	_, err = tx.ExecContext(ctx, stmt, report.CommentID, report.UserID, report.Reason, report.CreatedAt)
+	if err != nil {
+		return translate(err)
+	}
+
+	if status == models.CommentApproved {
+		stmt = `
+			UPDATE
+				COMMENTS
+			SET
+			    STATUS = $1, FLAG_REASON = $2, UPDATED_AT = $3
+			WHERE
+			    ID = $4
+		`
+
+		_, err = tx.ExecContext(ctx, stmt, models.CommentFlagged, reportReason(report.Reason), report.CreatedAt, report.CommentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) CommentReports(ctx context.Context, commentID int) ([]*models.CommentReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT
+			ID, COMMENT_ID, USER_ID, REASON, CREATED_AT
+		FROM
+		    COMMENT_REPORTS
+		WHERE
+		    COMMENT_ID = $1
+		ORDER BY
+		    CREATED_AT, ID
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*models.CommentReport{}
+	for rows.Next() {
+		var r models.CommentReport
+		err := rows.Scan(&r.ID, &r.CommentID, &r.UserID, &r.Reason, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, &r)
+	}
+
+	return reports, rows.Err()
+}
+
+func (m *PostgresDBRepo) ModerateComment(ctx context.Context, action models.ModerationAction) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `SELECT STATUS FROM COMMENTS WHERE ID = $1`, action.CommentID).Scan(&action.FromStatus)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		UPDATE
+			COMMENTS
+		SET
+		    STATUS = $1, FLAG_REASON = $2, UPDATED_AT = $3
+		WHERE
+		    ID = $4
+	`
+
+	_, err = tx.ExecContext(ctx, stmt, action.ToStatus, moderatedFlagReason(action), action.CreatedAt, action.CommentID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `
+		INSERT INTO
+			MODERATION_ACTIONS (COMMENT_ID, ADMIN, FROM_STATUS, TO_STATUS, NOTE, CREATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = tx.ExecContext(ctx, stmt,
+		action.CommentID,
+		action.Admin,
+		action.FromStatus,
+		action.ToStatus,
+		action.Note,
+		action.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresDBRepo) ModerationActions(ctx context.Context, commentID, page, pageSize int) ([]*models.ModerationAction, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	// a zero commentID matches every comment
+	where := `WHERE $1 = 0 OR COMMENT_ID = $1`
+
+	var total int
+	err := m.conn().QueryRowContext(ctx, `SELECT COUNT(*) FROM MODERATION_ACTIONS `+where, commentID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT
+			ID, COMMENT_ID, ADMIN, FROM_STATUS, TO_STATUS, NOTE, CREATED_AT
+		FROM
+		    MODERATION_ACTIONS
+		` + where + `
+		ORDER BY
+		    CREATED_AT DESC, ID DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, commentID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	actions := []*models.ModerationAction{}
+	for rows.Next() {
+		var a models.ModerationAction
+		err := rows.Scan(&a.ID, &a.CommentID, &a.Admin, &a.FromStatus, &a.ToStatus, &a.Note, &a.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		actions = append(actions, &a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return actions, total, nil
+}
+
+func (m *PostgresDBRepo) AllRatings(ctx context.Context) (map[int]map[int]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	rows, err := m.conn().QueryContext(ctx, `SELECT USER_ID, MOVIE_ID, RATING FROM REVIEWS`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := make(map[int]map[int]int)
+	for rows.Next() {
+		var userID, movieID, rating int
+		err := rows.Scan(&userID, &movieID, &rating)
+		if err != nil {
+			return nil, err
+		}
+
+		if ratings[userID] == nil {
+			ratings[userID] = make(map[int]int)
+		}
+		ratings[userID][movieID] = rating
+	}
+
+	return ratings, rows.Err()
+}
+
+func (m *PostgresDBRepo) InsertPerson(ctx context.Context, person models.Person) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		INSERT INTO
+			PEOPLE (NAME, BIRTH_YEAR, BIO, CREATED_AT, UPDATED_AT)
+		VALUES
+		    ($1, $2, $3, $4, $5)
+		RETURNING
+			ID
+	`
+
+	var id int
+	err := m.conn().QueryRowContext(ctx, stmt,
+		person.Name,
+		person.BirthYear,
+		person.Bio,
+		person.CreatedAt,
+		person.UpdatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	return id, nil
+}
+
+func (m *PostgresDBRepo) UpdatePerson(ctx context.Context, person models.Person) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		UPDATE
+			PEOPLE
+		SET
+		    NAME = $1, BIRTH_YEAR = $2, BIO = $3, UPDATED_AT = $4
+		WHERE
+		    ID = $5
+	`
+
+	res, err := m.conn().ExecContext(ctx, stmt, person.Name, person.BirthYear, person.Bio, person.UpdatedAt, person.ID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (m *PostgresDBRepo) DeletePerson(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	res, err := m.conn().ExecContext(ctx, `DELETE FROM PEOPLE WHERE ID = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// personColumns are the columns scanned by scanPerson.
+const personColumns = `
+	ID, NAME, BIRTH_YEAR, BIO, CREATED_AT, UPDATED_AT
+`
+
+func scanPerson(row rowScanner) (*models.Person, error) {
+	var person models.Person
+
+	err := row.Scan(
+		&person.ID,
+		&person.Name,
+		&person.BirthYear,
+		&person.Bio,
+		&person.CreatedAt,
+		&person.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &person, nil
+}
+
+func (m *PostgresDBRepo) GetPerson(ctx context.Context, id int) (*models.Person, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `SELECT` + personColumns + `FROM PEOPLE WHERE ID = $1`
+
+	return scanPerson(m.conn().QueryRowContext(ctx, query, id))
+}
+
+func (m *PostgresDBRepo) SearchPeople(ctx context.Context, query string, limit int) ([]*models.Person, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		SELECT` + personColumns + `
+		FROM
+		    PEOPLE
+		WHERE
+		    LOWER(NAME) LIKE $1 ESCAPE '\'
+		ORDER BY
+		    NAME, ID
+		LIMIT $2
+	`
+
+	rows, err := m.conn().QueryContext(ctx, stmt, likePattern(strings.ToLower(query)), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := []*models.Person{}
+	for rows.Next() {
+		person, err := scanPerson(rows)
+		if err != nil {
+			return nil, err
+		}
+		people = append(people, person)
+	}
+
+	return people, rows.Err()
+}
+
+// creditColumns are the columns scanned by scanCredit, from CREDITS C joined
+// with MOVIES M and PEOPLE P.
+const creditColumns = `
+	C.ID, C.MOVIE_ID, M.TITLE, M.RELEASE, C.PERSON_ID, P.NAME, C.ROLE, C.CHARACTER_NAME, C.BILLING
+`
+
+const creditJoins = `
+	CREDITS C
+	JOIN MOVIES M ON M.ID = C.MOVIE_ID
+	JOIN PEOPLE P ON P.ID = C.PERSON_ID
+`
+
+func scanCredit(row rowScanner) (*models.Credit, error) {
+	var credit models.Credit
+
+	err := row.Scan(
+		&credit.ID,
+		&credit.MovieID,
+		&credit.MovieTitle,
+		&credit.Release,
+		&credit.PersonID,
+		&credit.Name,
+		&credit.Role,
+		&credit.Character,
+		&credit.Billing,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credit, nil
+}
+
+func (m *PostgresDBRepo) queryCredits(ctx context.Context, query string, args ...interface{}) ([]*models.Credit, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*models.Credit{}
+	for rows.Next() {
+		credit, err := scanCredit(rows)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, credit)
+	}
+
+	return credits, rows.Err()
+}
+
+func (m *PostgresDBRepo) PersonCredits(ctx context.Context, personID int) ([]*models.Credit, error) {
+	query := `
+		SELECT` + creditColumns + `
+		FROM` + creditJoins + `
+		WHERE
+		    C.PERSON_ID = $1
+		ORDER BY
+		    M.RELEASE DESC, M.TITLE, M.ID, ` + creditOrder
+
+	return m.queryCredits(ctx, query, personID)
+}
+
+func (m *PostgresDBRepo) MovieCredits(ctx context.Context, movieID int) ([]*models.Credit, error) {
+	query := `
+		SELECT` + creditColumns + `
+		FROM` + creditJoins + `
+		WHERE
+		    C.MOVIE_ID = $1
+		ORDER BY` + creditOrder
+
+	return m.queryCredits(ctx, query, movieID)
+}
+
+func (m *PostgresDBRepo) GetCredit(ctx context.Context, id int) (*models.Credit, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query := `
+		SELECT` + creditColumns + `
+		FROM` + creditJoins + `
+		WHERE
+		    C.ID = $1
+	`
+
+	return scanCredit(m.conn().QueryRowContext(ctx, query, id))
+}
+
+func (m *PostgresDBRepo) InsertCredit(ctx context.Context, credit models.Credit) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		INSERT INTO
+			CREDITS (MOVIE_ID, PERSON_ID, ROLE, CHARACTER_NAME, BILLING)
+		VALUES
+		    ($1, $2, $3, $4, $5)
+		RETURNING
+			ID
+	`
+
+	var id int
+	err := m.conn().QueryRowContext(ctx, stmt,
+		credit.MovieID,
+		credit.PersonID,
+		credit.Role,
+		credit.Character,
+		credit.Billing,
+	).Scan(&id)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	return id, nil
+}
+
+func (m *PostgresDBRepo) UpdateCredit(ctx context.Context, credit models.Credit) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	stmt := `
+		UPDATE
+			CREDITS
+		SET
+		    ROLE = $1, CHARACTER_NAME = $2, BILLING = $3, UPDATED_AT = $4
+		WHERE
+		    ID = $5
+	`
+
+	res, err := m.conn().ExecContext(ctx, stmt, credit.Role, credit.Character, credit.Billing, time.Now(), credit.ID)
+	if err != nil {
+		return translate(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (m *PostgresDBRepo) DeleteCredit(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	res, err := m.conn().ExecContext(ctx, `DELETE FROM CREDITS WHERE ID = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
@@ -0,0 +1,33 @@
+package dbrepo
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+	"watch-a-movie/internal/models"
+)
+
+// authorName is how reviewers are shown: their first name and the initial of
+// their last name.
+func authorName(first, last string) string {
+	last = strings.TrimSpace(last)
+	if last == "" {
+		return first
+	}
+	r, _ := utf8.DecodeRuneInString(last)
+	return first + " " + string(r) + "."
+}
+
+// communityRating averages votes ratings adding up to sum.
+func communityRating(sum, votes int) models.CommunityRating {
+	if votes == 0 {
+		return models.CommunityRating{}
+	}
+	avg := math.Round(float64(sum)/float64(votes)*10) / 10
+	return models.CommunityRating{Average: float32(avg), Votes: votes}
+}
+
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
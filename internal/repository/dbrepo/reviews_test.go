@@ -0,0 +1,176 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+// testReviews has users 1 and 2 review movies 1 and 2. User 1 is shown as
+// author.
+func testReviews(t *testing.T, repo repository.DatabaseRepo, author string) {
+	t.Helper()
+	ctx := t.Context()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	review := func(movie, user, rating int, age time.Duration) models.Review {
+		at := now.Add(-age)
+		return models.Review{MovieID: movie, UserID: user, Rating: rating, Body: "fine", Status: models.CommentApproved, CreatedAt: at, UpdatedAt: at}
+	}
+
+	for _, r := range []models.Review{review(1, 1, 9, time.Hour), review(1, 2, 6, 0), review(2, 1, 7, 0)} {
+		_, err := repo.InsertReview(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// one review per user per movie
+	_, err := repo.InsertReview(ctx, review(1, 1, 3, 0))
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate but got %v", err)
+	}
+
+	ratings, err := repo.CommunityRatings(ctx, []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratings[1] != (models.CommunityRating{Average: 7.5, Votes: 2}) || ratings[2].Votes != 1 {
+		t.Errorf("unexpected ratings %+v", ratings)
+	}
+	if _, ok := ratings[3]; ok {
+		t.Errorf("expected no rating for movie 3")
+	}
+
+	reviews, total, err := repo.ListReviews(ctx, models.ReviewFilter{MovieID: 1, Page: 1, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(reviews) != 1 || reviews[0].UserID != 2 {
+		t.Fatalf("expected the newest of 2 reviews but got %d, %+v", total, reviews)
+	}
+	reviews, _, _ = repo.ListReviews(ctx, models.ReviewFilter{MovieID: 1, Page: 2, PageSize: 1})
+	if len(reviews) != 1 || reviews[0].UserID != 1 || reviews[0].Author != author {
+		t.Errorf("expected the review of %s on page 2 but got %+v", author, reviews)
+	}
+
+	updated := review(1, 1, 4, 0)
+	updated.Body = "less good the second time"
+	err = repo.UpdateReview(ctx, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetReview(ctx, 1, 1)
+	if err != nil || got.Rating != 4 || got.Body != updated.Body || !got.CreatedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("unexpected review %+v, %v", got, err)
+	}
+
+	err = repo.DeleteReview(ctx, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		repo.DeleteReview(ctx, 1, 2),
+		repo.UpdateReview(ctx, review(1, 2, 5, 0)),
+	} {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected sql.ErrNoRows but got %v", err)
+		}
+	}
+	if _, err := repo.GetReview(ctx, 1, 2); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+
+	ratings, _ = repo.CommunityRatings(ctx, []int{1})
+	if ratings[1] != (models.CommunityRating{Average: 4, Votes: 1}) {
+		t.Errorf("unexpected rating %+v", ratings[1])
+	}
+
+	all, err := repo.AllRatings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || len(all[1]) != 2 || all[1][1] != 4 || all[1][2] != 7 {
+		t.Errorf("unexpected ratings %v", all)
+	}
+
+	// a flagged review leaves the movie's list for the moderation queue
+	flagged := review(1, 2, 2, 0)
+	flagged.Body = "spam spam spam"
+	flagged.Status, flagged.FlagReason = models.CommentFlagged, "matched spam"
+	id, err := repo.InsertReview(ctx, flagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved := models.ReviewFilter{MovieID: 1, Statuses: []string{models.CommentApproved}, Page: 1, PageSize: 10}
+	reviews, total, _ = repo.ListReviews(ctx, approved)
+	if total != 1 || reviews[0].UserID != 1 {
+		t.Errorf("expected only the approved review but got %d, %+v", total, reviews)
+	}
+	queue := models.ReviewFilter{Statuses: []string{models.CommentFlagged}, OldestFirst: true, Page: 1, PageSize: 10}
+	reviews, _, _ = repo.ListReviews(ctx, queue)
+	if len(reviews) != 1 || reviews[0].ID != id || reviews[0].FlagReason != "matched spam" {
+		t.Errorf("expected the flagged review but got %+v", reviews)
+	}
+
+	err = repo.ModerateReview(ctx, models.ModerationAction{ReviewID: id, Admin: "1", ToStatus: models.CommentApproved, Note: "fair enough", CreatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.GetReviewByID(ctx, id)
+	if err != nil || got.Status != models.CommentApproved || got.FlagReason != "" {
+		t.Errorf("unexpected review %+v, %v", got, err)
+	}
+	actions, err := repo.ReviewActions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].ReviewID != id || actions[0].FromStatus != models.CommentFlagged || actions[0].Note != "fair enough" {
+		t.Errorf("unexpected actions %+v", actions)
+	}
+
+	err = repo.ModerateReview(ctx, models.ModerationAction{ReviewID: 99, Admin: "1", ToStatus: models.CommentRejected, CreatedAt: now})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+	if _, err := repo.GetReviewByID(ctx, 99); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+
+	// deleting the review deletes what was done to it
+	err = repo.DeleteReview(ctx, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, _ = repo.ReviewActions(ctx, id)
+	if len(actions) != 0 {
+		t.Errorf("expected no actions left but got %+v", actions)
+	}
+}
+
+func TestMemoryDBRepoReviews(t *testing.T) {
+	repo := NewMemoryDBRepo()
+	repo.Seed(Fixtures{
+		Movies: []models.Movie{{Title: "One"}, {Title: "Two"}, {Title: "Three"}},
+		Users: []models.User{
+			{FirstName: "Ada", LastName: "Admin", Email: "a@example.com"},
+			{FirstName: "Bob", LastName: "Builder", Email: "b@example.com"},
+		},
+	})
+
+	testReviews(t, repo, "Ada A.")
+}
+
+func TestSQLiteDBRepoReviews(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	_, err := repo.InsertUser(t.Context(), models.User{FirstName: "Bob", LastName: "Builder", Email: "b@example.com", Password: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testReviews(t, repo, "Admin U.")
+}
@@ -0,0 +1,141 @@
+package dbrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/password"
+	"watch-a-movie/internal/repository"
+)
+
+// The admin login SeedDemo creates. It is public knowledge, so a seeded
+// database is for development and demos only.
+const (
+	DemoAdminEmail    = "admin@example.com"
+	DemoAdminPassword = "password"
+)
+
+// SeedDemo fills an empty catalog with demo movies and genres, plus an admin
+// user with the DemoAdminEmail/DemoAdminPassword login. It reports whether it
+// seeded anything; a catalog that already has movies is left alone.
+func SeedDemo(ctx context.Context, db repository.DatabaseRepo) (bool, error) {
+	movies, err := db.AllMovies(ctx)
+	if err != nil || len(movies) > 0 {
+		return false, err
+	}
+
+	hash, err := password.NewBcrypt(password.DefaultBcryptCost).Hash(DemoAdminPassword)
+	if err != nil {
+		return false, err
+	}
+
+	err = db.WithTx(ctx, func(repo repository.DatabaseRepo) error {
+		now := time.Now()
+
+		genreIDs := make(map[string]int)
+		var names []string
+		for _, movie := range seedMovies {
+			names = append(names, movie.genres...)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, ok := genreIDs[name]; ok {
+				continue
+			}
+
+			id, err := repo.InsertGenre(ctx, models.Genre{Genre: name, CreatedAt: now, UpdatedAt: now})
+			if err != nil {
+				return err
+			}
+			genreIDs[name] = id
+		}
+
+		for _, seed := range seedMovies {
+			movie := seed.movie
+			movie.CreatedAt = now
+			movie.UpdatedAt = now
+
+			id, err := repo.InsertMovie(ctx, movie)
+			if err != nil {
+				return err
+			}
+
+			var ids []int
+			for _, name := range seed.genres {
+				ids = append(ids, genreIDs[name])
+			}
+
+			err = repo.UpdateMovieGenres(ctx, id, ids)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := repo.InsertUser(ctx, models.User{
+			FirstName: "Admin",
+			LastName:  "User",
+			Email:     DemoAdminEmail,
+			Password:  hash,
+			Roles:     []string{models.RoleAdmin},
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+var seedMovies = []struct {
+	movie  models.Movie
+	genres []string
+}{
+	{models.Movie{Title: "The Godfather", Release: 1972, RuntimeHours: 2, RuntimeMinutes: 55, MPAA: "R", IMDb: 9.2, IMDbID: "tt0068646", Poster: "godfather.jpg",
+		Description: "The aging patriarch of an organized crime dynasty transfers control of his clandestine empire to his reluctant son."},
+		[]string{"Crime", "Drama"}},
+	{models.Movie{Title: "The Godfather Part II", Release: 1974, RuntimeHours: 3, RuntimeMinutes: 22, MPAA: "R", IMDb: 9.0, IMDbID: "tt0071562", Poster: "godfather2.jpg",
+		Description: "The early life and career of Vito Corleone is portrayed, while his son Michael expands and tightens his grip on the family crime syndicate."},
+		[]string{"Crime", "Drama"}},
+	{models.Movie{Title: "The Dark Knight", Release: 2008, RuntimeHours: 2, RuntimeMinutes: 32, MPAA: "PG-13", IMDb: 9.0, IMDbID: "tt0468569", Poster: "thedarkknight.jpg",
+		Description: "Batman must accept one of the greatest psychological and physical tests of his ability to fight injustice when the Joker wreaks havoc on Gotham."},
+		[]string{"Action", "Crime", "Drama"}},
+	{models.Movie{Title: "Pulp Fiction", Release: 1994, RuntimeHours: 2, RuntimeMinutes: 34, MPAA: "R", IMDb: 8.9, IMDbID: "tt0110912", Poster: "Fiction.jpg",
+		Description: "The lives of two mob hitmen, a boxer, a gangster and his wife intertwine in four tales of violence and redemption."},
+		[]string{"Crime", "Drama"}},
+	{models.Movie{Title: "Goodfellas", Release: 1990, RuntimeHours: 2, RuntimeMinutes: 25, MPAA: "R", IMDb: 8.7, IMDbID: "tt0099685", Poster: "goodfellas.jpg",
+		Description: "The story of Henry Hill and his life in the mob, covering his relationship with his wife and his partners in crime."},
+		[]string{"Biography", "Crime", "Drama"}},
+	{models.Movie{Title: "Se7en", Release: 1995, RuntimeHours: 2, RuntimeMinutes: 7, MPAA: "R", IMDb: 8.6, IMDbID: "tt0114369", Poster: "se7en.jpg",
+		Description: "Two detectives hunt a serial killer who uses the seven deadly sins as his motives."},
+		[]string{"Crime", "Drama", "Mystery"}},
+	{models.Movie{Title: "Heat", Release: 1995, RuntimeHours: 2, RuntimeMinutes: 50, MPAA: "R", IMDb: 8.3, IMDbID: "tt0113277", Poster: "heat.jpg",
+		Description: "A group of high-end professional thieves start to feel the heat from the LAPD when they unknowingly leave a clue at their latest heist."},
+		[]string{"Action", "Crime", "Drama"}},
+	{models.Movie{Title: "Scarface", Release: 1983, RuntimeHours: 2, RuntimeMinutes: 50, MPAA: "R", IMDb: 8.3, IMDbID: "tt0086250", Poster: "scarface.jpg",
+		Description: "Miami in 1980: a determined Cuban immigrant takes over a drug cartel and succumbs to greed."},
+		[]string{"Crime", "Drama"}},
+	{models.Movie{Title: "Oppenheimer", Release: 2023, RuntimeHours: 3, RuntimeMinutes: 0, MPAA: "R", IMDb: 8.3, IMDbID: "tt15398776", Poster: "oppenheimer.jpg",
+		Description: "The story of American scientist J. Robert Oppenheimer and his role in the development of the atomic bomb."},
+		[]string{"Biography", "Drama", "History"}},
+	{models.Movie{Title: "The Wolf of Wall Street", Release: 2013, RuntimeHours: 3, RuntimeMinutes: 0, MPAA: "R", IMDb: 8.2, IMDbID: "tt0993846", Poster: "thewolf.jpg",
+		Description: "Based on the true story of Jordan Belfort, from his rise to a wealthy stock-broker to his fall involving crime, corruption and the federal government."},
+		[]string{"Biography", "Comedy", "Crime"}},
+	{models.Movie{Title: "Avengers: Infinity War", Release: 2018, RuntimeHours: 2, RuntimeMinutes: 29, MPAA: "PG-13", IMDb: 8.4, IMDbID: "tt4154756", Poster: "Infinity.jpg",
+		Description: "The Avengers and their allies must be willing to sacrifice all in an attempt to defeat the powerful Thanos before he puts an end to the universe."},
+		[]string{"Action", "Adventure", "Sci-Fi"}},
+	{models.Movie{Title: "A Man Called Otto", Release: 2022, RuntimeHours: 2, RuntimeMinutes: 6, MPAA: "PG-13", IMDb: 7.5, IMDbID: "tt7405458", Poster: "MANCALLEDOTTOA_English(US).jpg",
+		Description: "Otto is a grump who's given up on life following the loss of his wife, until a young family moves in nearby."},
+		[]string{"Comedy", "Drama"}},
+	{models.Movie{Title: "Barbie", Release: 2023, RuntimeHours: 1, RuntimeMinutes: 54, MPAA: "PG-13", IMDb: 6.8, IMDbID: "tt1517268", Poster: "barbie.jpg",
+		Description: "Barbie and Ken are having the time of their lives in Barbie Land, until they get a chance to go to the real world."},
+		[]string{"Adventure", "Comedy", "Fantasy"}},
+	{models.Movie{Title: "The Garfield Movie", Release: 2024, RuntimeHours: 1, RuntimeMinutes: 41, MPAA: "PG", IMDb: 5.8, IMDbID: "tt5779228", Poster: "garfield.jpg",
+		Description: "Garfield, the world-famous, Monday-hating, lasagna-loving indoor cat, is about to have a wild outdoor adventure."},
+		[]string{"Adventure", "Animation", "Comedy"}},
+}
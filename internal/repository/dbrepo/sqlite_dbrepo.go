@@ -0,0 +1,283 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDBRepo is a DatabaseRepo backed by a single SQLite file, for local
+// development, demos and CI. The PostgresDBRepo queries are written in SQL
+// that SQLite accepts as well, so they are reused as is; only search, which
+// relies on Postgres full-text functions, is implemented separately.
+type SQLiteDBRepo struct {
+	*PostgresDBRepo
+}
+
+// NewSQLiteDBRepo opens (creating if needed) the database at path and makes
+// sure the schema exists. It starts out empty; see SeedDemo.
+func NewSQLiteDBRepo(path string, timeout time.Duration) (*SQLiteDBRepo, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; one connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	m := &SQLiteDBRepo{PostgresDBRepo: &PostgresDBRepo{DB: db, Timeout: timeout}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for _, stmt := range sqliteUpgrades {
+		_, err = db.ExecContext(ctx, stmt)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// WithTx hands fn a SQLiteDBRepo, so that search keeps working inside the
+// transaction.
+func (m *SQLiteDBRepo) WithTx(ctx context.Context, fn func(repo repository.DatabaseRepo) error) error {
+	return m.PostgresDBRepo.WithTx(ctx, func(repo repository.DatabaseRepo) error {
+		return fn(&SQLiteDBRepo{PostgresDBRepo: repo.(*PostgresDBRepo)})
+	})
+}
+
+// SearchMovies does a case-insensitive substring match on the title and
+// description, ranking title matches first.
+func (m *SQLiteDBRepo) SearchMovies(ctx context.Context, query string, limit int) ([]*models.MovieSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	query = strings.TrimSpace(query)
+	pattern := likePattern(query)
+
+	stmt := `
+		SELECT
+			m.id, m.title, m.runtime, m.imdb, m.release, m.mpaa, m.description,
+			COALESCE(m.poster, ''), m.created_at, m.updated_at, m.imdb_id,
+			(CASE WHEN m.title LIKE $1 ESCAPE '\' THEN 1.0 ELSE 0 END) +
+			(CASE WHEN m.description LIKE $1 ESCAPE '\' THEN 0.5 ELSE 0 END) AS rank,
+			m.title,
+			m.description
+		FROM
+			MOVIES m
+		WHERE
+			m.title LIKE $1 ESCAPE '\' OR m.description LIKE $1 ESCAPE '\'
+		ORDER BY
+			rank DESC, m.title
+		LIMIT $2
+	`
+
+	results, err := m.scanSearchResults(ctx, stmt, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+	for _, r := range results {
+		r.TitleHighlight = highlight(r.TitleHighlight, re)
+		r.Snippet = highlight(r.Snippet, re)
+	}
+
+	return results, nil
+}
+
+// sqliteUpgrades bring databases created by an older sqliteSchema up to date.
+// SQLite has no ADD COLUMN IF NOT EXISTS, so adding a column that is already
+// there is expected to fail and ignored. Indexes on added columns are made
+// here too, since the columns don't exist yet when the schema runs.
+var sqliteUpgrades = []string{
+	`ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT 0`,
+	`ALTER TABLE reviews ADD COLUMN status TEXT NOT NULL DEFAULT 'approved'
+		CHECK (status IN ('pending', 'approved', 'rejected', 'flagged'))`,
+	`ALTER TABLE reviews ADD COLUMN flag_reason TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS reviews_status_idx ON reviews (status, created_at)`,
+}
+
+// sqliteSchema mirrors the Postgres schema in internal/migrations; keep the
+// two in step when adding a migration.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS genres (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	genre      TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS genres_genre_idx ON genres (genre COLLATE NOCASE);
+
+CREATE TABLE IF NOT EXISTS movies (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	title       TEXT NOT NULL,
+	release     INTEGER,
+	runtime     INTEGER,
+	mpaa        TEXT,
+	description TEXT,
+	imdb        REAL,
+	imdb_id     TEXT,
+	poster      TEXT,
+	created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS movies_imdb_id_idx ON movies (imdb_id);
+
+CREATE TABLE IF NOT EXISTS movies_genres (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	movie_id INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	genre_id INTEGER NOT NULL REFERENCES genres (id) ON DELETE CASCADE,
+	UNIQUE (movie_id, genre_id)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	first_name TEXT NOT NULL,
+	last_name  TEXT NOT NULL,
+	email      TEXT NOT NULL UNIQUE,
+	password   TEXT NOT NULL,
+	disabled   BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+	user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	role    TEXT NOT NULL,
+	PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id          TEXT PRIMARY KEY,
+	family_id   TEXT NOT NULL,
+	user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	expires_at  DATETIME NOT NULL,
+	revoked_at  DATETIME,
+	replaced_by TEXT,
+	created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS sessions_family_id_idx ON sessions (family_id);
+
+CREATE TABLE IF NOT EXISTS watchlist (
+	user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	movie_id   INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	position   INTEGER NOT NULL,
+	notes      TEXT NOT NULL DEFAULT '',
+	watched_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_movie_id_idx ON watchlist (movie_id);
+
+CREATE TABLE IF NOT EXISTS reviews (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	movie_id    INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	rating      INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 10),
+	body        TEXT NOT NULL DEFAULT '',
+	status      TEXT NOT NULL DEFAULT 'approved'
+	            CHECK (status IN ('pending', 'approved', 'rejected', 'flagged')),
+	flag_reason TEXT NOT NULL DEFAULT '',
+	created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
+
+CREATE TABLE IF NOT EXISTS review_actions (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	review_id   INTEGER NOT NULL REFERENCES reviews (id) ON DELETE CASCADE,
+	admin       TEXT NOT NULL,
+	from_status TEXT NOT NULL,
+	to_status   TEXT NOT NULL,
+	note        TEXT NOT NULL DEFAULT '',
+	created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS review_actions_review_id_idx ON review_actions (review_id);
+
+CREATE TABLE IF NOT EXISTS comments (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	movie_id    INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	body        TEXT NOT NULL,
+	status      TEXT NOT NULL DEFAULT 'pending'
+	            CHECK (status IN ('pending', 'approved', 'rejected', 'flagged')),
+	flag_reason TEXT NOT NULL DEFAULT '',
+	created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS comments_movie_id_idx ON comments (movie_id, status);
+CREATE INDEX IF NOT EXISTS comments_status_idx ON comments (status, created_at);
+
+CREATE TABLE IF NOT EXISTS comment_reports (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	comment_id INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+	user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	reason     TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (comment_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	comment_id  INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+	admin       TEXT NOT NULL,
+	from_status TEXT NOT NULL,
+	to_status   TEXT NOT NULL,
+	note        TEXT NOT NULL DEFAULT '',
+	created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS moderation_actions_comment_id_idx ON moderation_actions (comment_id);
+
+CREATE TABLE IF NOT EXISTS people (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	name       TEXT NOT NULL,
+	birth_year INTEGER NOT NULL DEFAULT 0,
+	bio        TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people (LOWER(name));
+
+CREATE TABLE IF NOT EXISTS credits (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	movie_id       INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	person_id      INTEGER NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+	role           TEXT NOT NULL CHECK (role IN ('director', 'writer', 'actor')),
+	character_name TEXT NOT NULL DEFAULT '',
+	billing        INTEGER NOT NULL DEFAULT 0 CHECK (billing >= 0),
+	created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (movie_id, person_id, role, character_name)
+);
+
+CREATE INDEX IF NOT EXISTS credits_person_id_idx ON credits (person_id);
+`
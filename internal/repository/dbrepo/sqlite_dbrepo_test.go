@@ -0,0 +1,332 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+func newTestSQLiteDBRepo(t *testing.T) *SQLiteDBRepo {
+	t.Helper()
+
+	repo, err := NewSQLiteDBRepo(filepath.Join(t.TempDir(), "movies.db"), time.Second*3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.DB.Close() })
+
+	_, err = SeedDemo(t.Context(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repo
+}
+
+func TestSQLiteDBRepoSeeds(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	movies, err := repo.AllMovies(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != len(seedMovies) {
+		t.Fatalf("expected %d movies but got %d", len(seedMovies), len(movies))
+	}
+
+	user, err := repo.GetUserByEmail(t.Context(), DemoAdminEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := user.ValidatePassword(DemoAdminPassword)
+	if err != nil || !ok {
+		t.Errorf("seeded admin password does not verify: %v", err)
+	}
+	if len(user.Roles) != 1 || user.Roles[0] != models.RoleAdmin {
+		t.Errorf("expected the admin role but got %v", user.Roles)
+	}
+}
+
+func TestSQLiteDBRepoOnlySeedsWhenAsked(t *testing.T) {
+	repo, err := NewSQLiteDBRepo(filepath.Join(t.TempDir(), "movies.db"), time.Second*3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.DB.Close()
+
+	// opening a database never creates the well-known admin login
+	_, err = repo.GetUserByEmail(t.Context(), DemoAdminEmail)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected an empty database but got %v", err)
+	}
+
+	for i, want := range []bool{true, false} {
+		seeded, err := SeedDemo(t.Context(), repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seeded != want {
+			t.Errorf("seed %d: expected %v but got %v", i+1, want, seeded)
+		}
+	}
+
+	movies, _ := repo.AllMovies(t.Context())
+	if len(movies) != len(seedMovies) {
+		t.Errorf("expected %d movies but got %d", len(seedMovies), len(movies))
+	}
+}
+
+func TestSQLiteDBRepoListAndSearch(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	movies, total, err := repo.ListMovies(t.Context(), models.MovieFilter{Page: 1, PageSize: 5, Sort: "release", MPAA: []string{"R"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 5 || total <= 5 {
+		t.Fatalf("expected a full first page of more than 5 R rated movies but got %d of %d", len(movies), total)
+	}
+	for _, movie := range movies {
+		if movie.MPAA != "R" {
+			t.Errorf("%s is rated %s", movie.Title, movie.MPAA)
+		}
+	}
+
+	results, err := repo.SearchMovies(t.Context(), "godfather", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected both Godfather movies but got %d results", len(results))
+	}
+	if results[0].TitleHighlight == results[0].Title {
+		t.Errorf("expected the match to be highlighted in %q", results[0].TitleHighlight)
+	}
+}
+
+func TestSQLiteDBRepoWithTxRollsBack(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	boom := errors.New("boom")
+	err := repo.WithTx(t.Context(), func(tx repository.DatabaseRepo) error {
+		_, err := tx.InsertGenre(t.Context(), models.Genre{Genre: "Documentary"})
+		if err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom but got %v", err)
+	}
+
+	genres, err := repo.AllGenres(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, genre := range genres {
+		if genre.Genre == "Documentary" {
+			t.Error("expected the insert to be rolled back")
+		}
+	}
+}
+
+func TestSQLiteDBRepoUpdateKeepsRuntime(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	movie, _, err := repo.OneMovieForEdit(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.UpdateMovie(t.Context(), *movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.OneMovie(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Runtime() != movie.Runtime() || updated.Poster != movie.Poster {
+		t.Errorf("expected runtime %d and poster %q but got %d and %q",
+			movie.Runtime(), movie.Poster, updated.Runtime(), updated.Poster)
+	}
+}
+
+func TestSQLiteDBRepoInsertUserDuplicate(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	_, err := repo.InsertUser(t.Context(), models.User{
+		FirstName: "Second",
+		LastName:  "Admin",
+		Email:     DemoAdminEmail,
+		Password:  "x",
+	})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate but got %v", err)
+	}
+}
+
+func TestSQLiteDBRepoSearchEscapesHighlights(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	_, err := repo.InsertMovie(t.Context(), models.Movie{Title: `<script>alert(1)</script> Alien`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := repo.SearchMovies(t.Context(), "alien", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].TitleHighlight != `&lt;script&gt;alert(1)&lt;/script&gt; <mark>Alien</mark>` {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestSQLiteDBRepoGenreNamesAreUnique(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	_, err := repo.InsertGenre(t.Context(), models.Genre{Genre: "drama"})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate but got %v", err)
+	}
+}
+
+func TestSQLiteDBRepoMovieIDByIMDbID(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	id, err := repo.MovieIDByIMDbID(t.Context(), "tt0068646")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie, err := repo.GetMovieByID(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "The Godfather" {
+		t.Errorf("expected The Godfather but got %s", movie.Title)
+	}
+
+	_, err = repo.MovieIDByIMDbID(t.Context(), "tt0000000")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+}
+
+func TestSQLiteDBRepoMovieGenreIDs(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+	ctx := t.Context()
+
+	id, err := repo.InsertMovie(ctx, models.Movie{Title: "No Genres", Release: 2000, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genres, err := repo.MovieGenreIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids, ok := genres[id]; !ok || len(ids) != 0 {
+		t.Errorf("expected an empty list for the new movie but got %v, %v", ids, ok)
+	}
+
+	godfather, err := repo.MovieIDByIMDbID(ctx, "tt0068646")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie, err := repo.OneMovie(ctx, godfather)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(genres[godfather]) == 0 || len(genres[godfather]) != len(movie.Genres) {
+		t.Errorf("expected the genres of %+v but got %v", movie.Genres, genres[godfather])
+	}
+	for i, id := range genres[godfather][1:] {
+		if id <= genres[godfather][i] {
+			t.Errorf("expected genre ids in order but got %v", genres[godfather])
+		}
+	}
+}
+
+func TestSQLiteDBRepoEachMovie(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+
+	var titles []string
+	err := repo.EachMovie(t.Context(), models.MovieFilter{MPAA: []string{"R"}, YearTo: 1975}, func(movie *models.Movie) error {
+		if len(movie.Genres) == 0 {
+			t.Errorf("%s: expected genres", movie.Title)
+		}
+		titles = append(titles, movie.Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(titles) != 2 || titles[0] != "The Godfather" || titles[1] != "The Godfather Part II" {
+		t.Errorf("unexpected movies %q", titles)
+	}
+
+	// an error from fn stops the iteration
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.EachMovie(t.Context(), models.MovieFilter{}, func(movie *models.Movie) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected to stop after one movie but got %d calls and %v", calls, err)
+	}
+}
+
+func TestSQLiteDBRepoPosterBaseURL(t *testing.T) {
+	repo := newTestSQLiteDBRepo(t)
+	repo.PosterBaseURL = "https://cdn.example.com/posters/"
+
+	id, err := repo.MovieIDByIMDbID(t.Context(), "tt0068646")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie, err := repo.GetMovieByID(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Poster != "https://cdn.example.com/posters/godfather.jpg" {
+		t.Fatalf("unexpected poster url %s", movie.Poster)
+	}
+
+	// sending the url back stores the file name again, inside a transaction
+	// as well
+	err = repo.WithTx(t.Context(), func(tx repository.DatabaseRepo) error {
+		return tx.UpdateMovie(t.Context(), *movie)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	err = repo.DB.QueryRow(`SELECT poster FROM movies WHERE id = $1`, id).Scan(&stored)
+	if err != nil || stored != "godfather.jpg" {
+		t.Errorf("expected godfather.jpg to be stored but got %q, %v", stored, err)
+	}
+
+	err = repo.UpdateMoviePoster(t.Context(), id, "1-0123456789abcdef.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie, _ = repo.GetMovieByID(t.Context(), id)
+	if movie.Poster != "https://cdn.example.com/posters/1-0123456789abcdef.png" {
+		t.Errorf("unexpected poster url %s", movie.Poster)
+	}
+
+	if err := repo.UpdateMoviePoster(t.Context(), 9999, "x.png"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package dbrepo
+
+// placeInList returns ids, the movies of a watchlist in order, with movieID
+// moved to position, counting from 1, or added there if it isn't on the list.
+// Positions past either end are clamped, and a position of zero keeps the
+// movie where it is, or adds it at the end.
+func placeInList(ids []int, movieID, position int) []int {
+	list := make([]int, 0, len(ids)+1)
+	current := 0
+	for i, id := range ids {
+		if id == movieID {
+			current = i + 1
+			continue
+		}
+		list = append(list, id)
+	}
+
+	if position == 0 {
+		position = current
+	}
+	if position < 1 || position > len(list)+1 {
+		position = len(list) + 1
+	}
+
+	list = append(list, 0)
+	copy(list[position:], list[position-1:])
+	list[position-1] = movieID
+
+	return list
+}
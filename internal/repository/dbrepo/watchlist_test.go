@@ -0,0 +1,135 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"watch-a-movie/internal/models"
+	"watch-a-movie/internal/repository"
+)
+
+func TestPlaceInList(t *testing.T) {
+	tests := []struct {
+		ids      []int
+		movie    int
+		position int
+		expected string
+	}{
+		{nil, 7, 0, "[7]"},
+		{[]int{1, 2, 3}, 7, 0, "[1 2 3 7]"},
+		{[]int{1, 2, 3}, 7, 1, "[7 1 2 3]"},
+		{[]int{1, 2, 3}, 7, 99, "[1 2 3 7]"},
+		{[]int{1, 2, 3}, 2, 0, "[1 2 3]"},
+		{[]int{1, 2, 3}, 3, 1, "[3 1 2]"},
+		{[]int{1, 2, 3}, 1, 3, "[2 3 1]"},
+		{[]int{1, 2, 3}, 1, 99, "[2 3 1]"},
+	}
+
+	for _, tt := range tests {
+		got := fmt.Sprint(placeInList(tt.ids, tt.movie, tt.position))
+		if got != tt.expected {
+			t.Errorf("placeInList(%v, %d, %d): expected %s but got %s", tt.ids, tt.movie, tt.position, tt.expected, got)
+		}
+	}
+}
+
+// testWatchlist runs through a watchlist of user's with movies 1, 2 and 3.
+func testWatchlist(t *testing.T, repo repository.DatabaseRepo, user int) {
+	t.Helper()
+	ctx := t.Context()
+
+	order := func() string {
+		t.Helper()
+		entries, err := repo.Watchlist(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s string
+		for i, e := range entries {
+			if e.Position != i+1 || e.Movie == nil || e.Movie.ID != e.MovieID {
+				t.Errorf("unexpected entry %+v", e)
+			}
+			s += fmt.Sprint(e.MovieID)
+		}
+		return s
+	}
+
+	for _, id := range []int{1, 2, 3} {
+		err := repo.SaveWatchlistEntry(ctx, user, models.WatchlistEntry{MovieID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := order(); got != "123" {
+		t.Errorf("expected 123 but got %s", got)
+	}
+
+	watched := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+	err := repo.SaveWatchlistEntry(ctx, user, models.WatchlistEntry{MovieID: 3, Position: 1, Notes: "with popcorn", WatchedAt: &watched})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := order(); got != "312" {
+		t.Errorf("expected 312 but got %s", got)
+	}
+
+	entries, _ := repo.Watchlist(ctx, user)
+	first := entries[0]
+	if first.Notes != "with popcorn" || !first.Watched || first.WatchedAt == nil || !first.WatchedAt.Equal(watched) {
+		t.Errorf("unexpected entry %+v", first)
+	}
+	if entries[1].Watched || entries[1].WatchedAt != nil {
+		t.Errorf("expected movie 1 to be unwatched but got %+v", entries[1])
+	}
+
+	err = repo.RemoveFromWatchlist(ctx, user, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := order(); got != "32" {
+		t.Errorf("expected 32 but got %s", got)
+	}
+	err = repo.RemoveFromWatchlist(ctx, user, 1)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows but got %v", err)
+	}
+
+	err = repo.SaveWatchlistEntry(ctx, user, models.WatchlistEntry{MovieID: 999})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for an unknown movie but got %v", err)
+	}
+
+	// deleting a movie takes it off every watchlist
+	err = repo.DeleteMovie(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := order(); got != "2" {
+		t.Errorf("expected 2 but got %s", got)
+	}
+
+	ids, err := repo.WatchlistMovieIDs(ctx, user)
+	if err != nil || len(ids) != 1 || !ids[2] {
+		t.Errorf("expected movie 2 but got %v, %v", ids, err)
+	}
+	ids, err = repo.WatchlistMovieIDs(ctx, user+1)
+	if err != nil || len(ids) != 0 {
+		t.Errorf("expected an empty watchlist for another user but got %v, %v", ids, err)
+	}
+}
+
+func TestMemoryDBRepoWatchlist(t *testing.T) {
+	repo := NewMemoryDBRepo()
+	repo.Seed(Fixtures{
+		Movies: []models.Movie{{Title: "One"}, {Title: "Two"}, {Title: "Three"}},
+		Users:  []models.User{{Email: "a@example.com"}, {Email: "b@example.com"}},
+	})
+
+	testWatchlist(t, repo, 1)
+}
+
+func TestSQLiteDBRepoWatchlist(t *testing.T) {
+	testWatchlist(t, newTestSQLiteDBRepo(t), 1)
+}
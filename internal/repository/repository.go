@@ -1,19 +1,140 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"watch-a-movie/internal/models"
 )
 
+// ErrSessionRotated is returned by RotateSession when the session was
+// already rotated or revoked by someone else.
+var ErrSessionRotated = errors.New("session already rotated")
+
+// ErrDuplicate is returned by inserts and updates that would break a unique
+// constraint, such as registering an email that is already taken.
+var ErrDuplicate = errors.New("duplicate key")
+
 type DatabaseRepo interface {
 	Connection() *sql.DB
-	AllMovies() ([]*models.Movie, error)
-	GetUserByEmail(email string) (*models.User, error)
-	GetUserByID(id int) (*models.User, error)
-	GetMovieByID(id int) (*models.Movie, error)
-	OneMovieForEdit(id int) (*models.Movie, []*models.Genre, error)
-	OneMovie(id int) (*models.Movie, error)
-	AllGenres() ([]*models.Genre, error)
-	InsertMovie(movie models.Movie) (int, error)
-	UpdateMovieGenres(id int, genreIDs []int) error
+
+	// WithTx runs fn with a repository whose methods all share one
+	// transaction, committed only if fn returns nil.
+	WithTx(ctx context.Context, fn func(repo DatabaseRepo) error) error
+
+	AllMovies(ctx context.Context) ([]*models.Movie, error)
+	ListMovies(ctx context.Context, filter models.MovieFilter) ([]*models.Movie, int, error)
+	EachMovie(ctx context.Context, filter models.MovieFilter, fn func(movie *models.Movie) error) error
+	SearchMovies(ctx context.Context, query string, limit int) ([]*models.MovieSearchResult, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	UpdateUserPassword(ctx context.Context, id int, hash string) error
+	InsertUser(ctx context.Context, user models.User) (int, error)
+	SetUserDisabled(ctx context.Context, id int, disabled bool) error
+	AllUsers(ctx context.Context) ([]*models.User, error)
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	SetUserRoles(ctx context.Context, userID int, roles []string) error
+	InsertSession(ctx context.Context, session models.Session) error
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	RotateSession(ctx context.Context, oldID string, next models.Session) error
+	RevokeSessionFamily(ctx context.Context, familyID string) error
+	GetMovieByID(ctx context.Context, id int) (*models.Movie, error)
+	MovieIDByIMDbID(ctx context.Context, imdbID string) (int, error)
+	OneMovieForEdit(ctx context.Context, id int) (*models.Movie, []*models.Genre, error)
+	OneMovie(ctx context.Context, id int) (*models.Movie, error)
+	AllGenres(ctx context.Context) ([]*models.GenreCount, error)
+	InsertGenre(ctx context.Context, genre models.Genre) (int, error)
+	UpdateGenre(ctx context.Context, genre models.Genre) error
+	DeleteGenre(ctx context.Context, id int) error
+	MergeGenres(ctx context.Context, fromID, intoID int) error
+	UnknownGenreIDs(ctx context.Context, ids []int) ([]int, error)
+	// MovieGenreIDs returns the genre ids of every movie in the catalog, in
+	// order, with an empty list for movies without genres.
+	MovieGenreIDs(ctx context.Context) (map[int][]int, error)
+	InsertMovie(ctx context.Context, movie models.Movie) (int, error)
+	UpdateMovieGenres(ctx context.Context, id int, genreIDs []int) error
+	UpdateMovie(ctx context.Context, movie models.Movie) error
+	UpdateMoviePoster(ctx context.Context, id int, poster string) error
+	DeleteMovie(ctx context.Context, id int) error
+
+	// Watchlist returns a user's watchlist in order, with its movies.
+	Watchlist(ctx context.Context, userID int) ([]*models.WatchlistEntry, error)
+	// WatchlistMovieIDs returns the ids of the movies on a user's watchlist.
+	WatchlistMovieIDs(ctx context.Context, userID int) (map[int]bool, error)
+	// SaveWatchlistEntry adds a movie to a user's watchlist or updates it
+	// there, moving it to entry.Position. A zero Position keeps the entry
+	// where it is, or adds it at the end. Unknown movies are sql.ErrNoRows.
+	SaveWatchlistEntry(ctx context.Context, userID int, entry models.WatchlistEntry) error
+	// RemoveFromWatchlist returns sql.ErrNoRows if the movie wasn't on it.
+	RemoveFromWatchlist(ctx context.Context, userID, movieID int) error
+
+	// InsertReview returns ErrDuplicate if the user already reviewed the
+	// movie. UpdateReview and DeleteReview find the review by movie and user,
+	// and return sql.ErrNoRows if there is none. InsertReview and
+	// UpdateReview store the review in the state it comes with.
+	InsertReview(ctx context.Context, review models.Review) (int, error)
+	UpdateReview(ctx context.Context, review models.Review) error
+	DeleteReview(ctx context.Context, movieID, userID int) error
+	GetReview(ctx context.Context, movieID, userID int) (*models.Review, error)
+	// GetReviewByID returns sql.ErrNoRows if there is no such review.
+	GetReviewByID(ctx context.Context, id int) (*models.Review, error)
+	// ListReviews returns a page of reviews and the number of reviews
+	// across all pages.
+	ListReviews(ctx context.Context, filter models.ReviewFilter) ([]*models.Review, int, error)
+	// ModerateReview is ModerateComment for reviews; action.ReviewID says
+	// which.
+	ModerateReview(ctx context.Context, action models.ModerationAction) error
+	// ReviewActions returns the moderation actions on a review, newest
+	// first.
+	ReviewActions(ctx context.Context, reviewID int) ([]*models.ModerationAction, error)
+	// CommunityRatings returns the community rating of each of movieIDs
+	// that has been rated.
+	CommunityRatings(ctx context.Context, movieIDs []int) (map[int]models.CommunityRating, error)
+	// AllRatings returns every rating, by user and then by movie.
+	AllRatings(ctx context.Context) (map[int]map[int]int, error)
+
+	// InsertComment stores a comment in the state it comes with.
+	InsertComment(ctx context.Context, comment models.Comment) (int, error)
+	// GetComment returns sql.ErrNoRows if there is no such comment.
+	GetComment(ctx context.Context, id int) (*models.Comment, error)
+	// ListComments returns a page of comments and the number of comments
+	// across all pages.
+	ListComments(ctx context.Context, filter models.CommentFilter) ([]*models.Comment, int, error)
+	// ReportComment records a report and flags the comment if it was
+	// approved. It returns sql.ErrNoRows if there is no such comment and
+	// ErrDuplicate if the user already reported it.
+	ReportComment(ctx context.Context, report models.CommentReport) error
+	CommentReports(ctx context.Context, commentID int) ([]*models.CommentReport, error)
+	// ModerateComment moves a comment to action.ToStatus and records the
+	// action, with FromStatus filled in, in one go. It returns sql.ErrNoRows
+	// if there is no such comment.
+	ModerateComment(ctx context.Context, action models.ModerationAction) error
+	// ModerationActions returns a page of moderation actions, newest first,
+	// and the number across all pages. A zero commentID means every comment.
+	ModerationActions(ctx context.Context, commentID, page, pageSize int) ([]*models.ModerationAction, int, error)
+
+	InsertPerson(ctx context.Context, person models.Person) (int, error)
+	// UpdatePerson and DeletePerson return sql.ErrNoRows if there is no such
+	// person. Deleting a person deletes their credits.
+	UpdatePerson(ctx context.Context, person models.Person) error
+	DeletePerson(ctx context.Context, id int) error
+	// GetPerson returns sql.ErrNoRows if there is no such person. It leaves
+	// Filmography empty; see PersonCredits.
+	GetPerson(ctx context.Context, id int) (*models.Person, error)
+	// SearchPeople returns up to limit people whose name contains query,
+	// regardless of case, by name.
+	SearchPeople(ctx context.Context, query string, limit int) ([]*models.Person, error)
+	// PersonCredits returns a person's credits, the latest movies first.
+	PersonCredits(ctx context.Context, personID int) ([]*models.Credit, error)
+	// MovieCredits returns a movie's credits in the order of
+	// models.CreditRoles, actors by billing.
+	MovieCredits(ctx context.Context, movieID int) ([]*models.Credit, error)
+	// InsertCredit and UpdateCredit return ErrDuplicate if the person already
+	// has the credit on the movie. UpdateCredit changes the role, character
+	// and billing; it, GetCredit and DeleteCredit return sql.ErrNoRows if
+	// there is no such credit.
+	InsertCredit(ctx context.Context, credit models.Credit) (int, error)
+	UpdateCredit(ctx context.Context, credit models.Credit) error
+	DeleteCredit(ctx context.Context, id int) error
+	GetCredit(ctx context.Context, id int) (*models.Credit, error)
 }
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps files in a directory on disk, such as static/images,
+// which the api already serves.
+type LocalStore struct {
+	Dir string
+}
+
+// Put writes the file to a temporary name first and renames it into place,
+// so that readers never see a partial file.
+func (s LocalStore) Put(ctx context.Context, name string, data []byte, contentType string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	err := os.MkdirAll(s.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0o644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(s.Dir, name))
+}
+
+func (s LocalStore) Get(ctx context.Context, name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s LocalStore) Delete(ctx context.Context, name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	err := os.Remove(filepath.Join(s.Dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
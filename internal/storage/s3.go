@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Store keeps files in a bucket of an S3-compatible service such as AWS
+// S3 or MinIO. Requests use path-style addressing, Endpoint/Bucket/name, and
+// are signed with AWS Signature Version 4.
+type S3Store struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Bucket    string
+	Region    string // us-east-1 if empty
+	AccessKey string
+	SecretKey string
+
+	// Client is http.DefaultClient if nil
+	Client *http.Client
+
+	// now and service are time.Now and s3 when unset; the signing tests
+	// change them to match published examples
+	now     func() time.Time
+	service string
+}
+
+// URL is where an object can be read from, for buckets that allow public
+// reads.
+func (s S3Store) URL(name string) string {
+	return strings.TrimSuffix(s.Endpoint, "/") + "/" + url.PathEscape(s.Bucket) + "/" + url.PathEscape(name)
+}
+
+func (s S3Store) Put(ctx context.Context, name string, data []byte, contentType string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.URL(name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	_, err = s.do(req, data)
+	return err
+}
+
+func (s S3Store) Get(ctx context.Context, name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.do(req, nil)
+	var statusErr *S3Error
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s S3Store) Delete(ctx context.Context, name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.URL(name), nil)
+	if err != nil {
+		return err
+	}
+
+	// S3 answers 204 whether or not the object existed; some compatible
+	// services answer 404 instead
+	_, err = s.do(req, nil)
+	var statusErr *S3Error
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// S3Error is a request the service answered with an error status.
+type S3Error struct {
+	Method     string
+	Name       string
+	StatusCode int
+	Body       string
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("storage: s3 %s %s: %d %s", e.Method, e.Name, e.StatusCode, e.Body)
+}
+
+// do signs and sends req, returning the body of a successful response.
+func (s S3Store) do(req *http.Request, payload []byte) ([]byte, error) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", hashHex(payload))
+	s.sign(req, now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &S3Error{
+			Method:     req.Method,
+			Name:       req.URL.Path,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// sign adds a Signature Version 4 Authorization header to req, signing the
+// host, Content-Type and every X-Amz-* header. The payload hash is taken from
+// X-Amz-Content-Sha256, or is that of an empty body.
+func (s S3Store) sign(req *http.Request, t time.Time) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	service := s.service
+	if service == "" {
+		service = "s3"
+	}
+
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = hashHex(nil)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything but the unreserved characters, as
+// Signature Version 4 requires.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
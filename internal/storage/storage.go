@@ -0,0 +1,33 @@
+// Package storage keeps uploaded files, such as posters, somewhere the api
+// can serve them from: a local directory or an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// PosterStore saves poster images under flat file names. Put overwrites any
+// file with the same name, Get of a missing file returns ErrNotFound, and
+// Delete of a missing file is not an error.
+type PosterStore interface {
+	Put(ctx context.Context, name string, data []byte, contentType string) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// ErrInvalidName is returned for names that aren't a single path element.
+var ErrInvalidName = errors.New("storage: invalid file name")
+
+// ErrNotFound is returned by Get for files that aren't in the store.
+var ErrNotFound = errors.New("storage: file not found")
+
+// checkName rejects names that could escape the store's directory or bucket
+// prefix.
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return ErrInvalidName
+	}
+	return nil
+}
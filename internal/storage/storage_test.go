@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "images")
+	store := LocalStore{Dir: dir}
+
+	err := store.Put(t.Context(), "1-poster.png", []byte("first"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(t.Context(), "1-poster.png", []byte("second"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "1-poster.png"))
+	if err != nil || string(data) != "second" {
+		t.Errorf("expected the file to be overwritten but got %q, %v", data, err)
+	}
+
+	// no temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected 1 file but got %d", len(entries))
+	}
+
+	data, err = store.Get(t.Context(), "1-poster.png")
+	if err != nil || string(data) != "second" {
+		t.Errorf("expected to read the file back but got %q, %v", data, err)
+	}
+
+	err = store.Delete(t.Context(), "1-poster.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(t.Context(), "1-poster.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+	err = store.Delete(t.Context(), "1-poster.png")
+	if err != nil {
+		t.Errorf("expected deleting a missing file to succeed but got %v", err)
+	}
+
+	for _, name := range []string{"", "..", "../escape.png", "a/b.png", `a\b.png`, ".hidden"} {
+		err := store.Put(t.Context(), name, []byte("x"), "")
+		if !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%q: expected ErrInvalidName but got %v", name, err)
+		}
+	}
+}
+
+// TestS3Signature checks the signer against the get-vanilla example of the
+// AWS Signature Version 4 test suite.
+func TestS3Signature(t *testing.T) {
+	store := S3Store{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		service:   "service",
+	}
+
+	req := httptest.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	store.sign(req, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, got)
+	}
+}
+
+// s3Stub is a minimal S3-compatible server holding one bucket in memory. It
+// checks every request's signature the way the real service would.
+type s3Stub struct {
+	bucket string
+	store  S3Store
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		http.Error(w, "missing date", http.StatusForbidden)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if hashHex(body) != r.Header.Get("X-Amz-Content-Sha256") {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+
+	// sign the request as received and compare
+	check := r.Clone(r.Context())
+	check.URL.Host = r.Host
+	check.Header.Del("Authorization")
+	for name := range check.Header {
+		lower := strings.ToLower(name)
+		if lower != "content-type" && !strings.HasPrefix(lower, "x-amz-") {
+			check.Header.Del(name)
+		}
+	}
+	s.store.sign(check, date)
+	if check.Header.Get("Authorization") != auth {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "PUT":
+		s.objects[key] = body
+		s.types[key] = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", s.types[key])
+		w.Write(data)
+	case "DELETE":
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	stub := &s3Stub{
+		bucket:  "posters",
+		objects: make(map[string][]byte),
+		types:   make(map[string]string),
+	}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	store := S3Store{
+		Endpoint:  srv.URL,
+		Bucket:    "posters",
+		Region:    "eu-west-1",
+		AccessKey: "minio",
+		SecretKey: "minio-secret",
+	}
+	stub.store = store
+
+	err := store.Put(t.Context(), "7-abc.png", []byte("png bytes"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stub.objects["7-abc.png"]) != "png bytes" || stub.types["7-abc.png"] != "image/png" {
+		t.Errorf("unexpected object %q of type %q", stub.objects["7-abc.png"], stub.types["7-abc.png"])
+	}
+
+	data, err := store.Get(t.Context(), "7-abc.png")
+	if err != nil || string(data) != "png bytes" {
+		t.Errorf("expected to read the object back but got %q, %v", data, err)
+	}
+
+	if got := store.URL("7-abc.png"); got != srv.URL+"/posters/7-abc.png" {
+		t.Errorf("unexpected url %s", got)
+	}
+
+	err = store.Delete(t.Context(), "7-abc.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stub.objects["7-abc.png"]; ok {
+		t.Error("expected the object to be deleted")
+	}
+	if _, err := store.Get(t.Context(), "7-abc.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	// a wrong secret is refused, and the error says why
+	bad := store
+	bad.SecretKey = "wrong"
+	err = bad.Put(t.Context(), "7-abc.png", []byte("png bytes"), "image/png")
+
+	var s3Err *S3Error
+	if !errors.As(err, &s3Err) || s3Err.StatusCode != http.StatusForbidden || !strings.Contains(s3Err.Body, "SignatureDoesNotMatch") {
+		t.Errorf("expected a signature error but got %v", err)
+	}
+
+	// and so is a missing bucket
+	missing := store
+	missing.Bucket = "nope"
+	err = missing.Put(t.Context(), "7-abc.png", []byte("png bytes"), "image/png")
+	if !errors.As(err, &s3Err) || s3Err.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a 404 but got %v", err)
+	}
+}